@@ -0,0 +1,192 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/router"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Keys of the authorizer context set by the authentication Middleware (or an API Gateway authorizer) for callers authenticated with a token.
+// The permissions are a comma separated list of grants in the format of types.FormatGrant.
+const (
+	authorizerPrincipal   = "principalId"
+	authorizerPermissions = "permissions"
+)
+
+// bearerToken returns the token of the "Authorization: Bearer" header, if the header isn't set an empty string gets returned.
+func bearerToken(request events.APIGatewayProxyRequest) string {
+	header := getHeader(request, "Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// withAuthenticatedCaller returns the request with the principal and the grants of a caller authenticated with a token in the authorizer context.
+// The principal is used as caller identity in the audit log and the history, the AccessControlMiddleware enforces the grants.
+// If grants is nil the caller is authorized by the RoleBindings of the principal.
+func withAuthenticatedCaller(request events.APIGatewayProxyRequest, principal string, grants []string) events.APIGatewayProxyRequest {
+	authorizer := map[string]interface{}{}
+	for key, value := range request.RequestContext.Authorizer {
+		authorizer[key] = value
+	}
+
+	authorizer[authorizerPrincipal] = principal
+	delete(authorizer, authorizerPermissions)
+	if grants != nil {
+		authorizer[authorizerPermissions] = strings.Join(grants, ",")
+	}
+
+	request.RequestContext.Authorizer = authorizer
+	return request
+}
+
+// bodyScopedRoutes are the routes without "name" path parameter whose Repository is taken from the "repository" field of the request body.
+// All other routes without "name" path parameter (e.g. POST /repositories or PUT /role-bindings) require a grant for all Repositories,
+// since the body is chosen by the caller and mustn't widen a grant for a single Repository.
+var bodyScopedRoutes = map[string]bool{
+	http.MethodPost + " /triggers/schedule": true,
+}
+
+// tokenGrants returns true if one of the comma separated grants allows the permission for the repository.
+// Grants for a single Repository don't apply to routes without Repository.
+func tokenGrants(grants string, repository string, permission types.Permission) bool {
+	for _, grant := range strings.Split(grants, ",") {
+		grant = strings.TrimSpace(grant)
+		if grant == types.FormatGrant(permission, types.AllRepositories) || (repository != "" && grant == types.FormatGrant(permission, repository)) {
+			return true
+		}
+	}
+	return false
+}
+
+// AccessControlMiddleware rejects the request with 403 before the Controller runs, if the caller doesn't have the Permission of the route.
+// Callers authenticated with a token (e.g. an APIKey) have the grants from the authorizer context, all other callers need a Role bound to their identity.
+// The Repository is taken from the "name" path parameter, only for the bodyScopedRoutes from the "repository" field of the request body.
+// Routes without Repository require a Role bound for all Repositories. Routes without Permission are passed through, they are secured by the Controller.
+func AccessControlMiddleware(route router.Route, next router.Controller) router.Controller {
+	if route.Permission == "" {
+		return next
+	}
+	bodyScoped := bodyScopedRoutes[route.Method+" "+route.Resource]
+
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		repository := request.PathParameters["name"]
+		if repository == "" && bodyScoped {
+			body := targetRequestBody{}
+			json.Unmarshal([]byte(request.Body), &body)
+			repository = body.Repository
+		}
+
+		identity := requestActor(request)
+		allowed := false
+		if grants, ok := request.RequestContext.Authorizer[authorizerPermissions].(string); ok {
+			allowed = tokenGrants(grants, repository, route.Permission)
+		} else {
+			var err error
+			allowed, err = model.Authorize(identity, repository, route.Permission)
+			if err != nil {
+				return types.InternalServerErrorResponse, nil
+			}
+		}
+		if !allowed {
+			config.Logger.Log(errors.New("Access denied for "+identity), map[string]string{"module": "controller/AccessControlMiddleware", "operation": "authorize/" + string(route.Permission)}, 1)
+			return types.ForbiddenResponse, nil
+		}
+
+		return next(request)
+	}
+}
+
+// GetAllRoleBindingsController is the controller function for the GET /role-bindings endpoint.
+// If the limit or cursor query parameter is set, a single page is returned as RoleBindingList, otherwise all RoleBindings are returned.
+func GetAllRoleBindingsController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllRoleBindingsController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	var obj interface{}
+	if paginated {
+		list := types.RoleBindingList{RoleBindings: []types.RoleBinding{}}
+		list.NextCursor, err = model.GetRoleBindingsPage(&list.RoleBindings, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = list
+	} else {
+		bindings := []types.RoleBinding{}
+		err = model.GetAllRoleBindings(&bindings)
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = bindings
+	}
+
+	body, err := json.Marshal(obj)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllRoleBindingsController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// PutRoleBindingController is the controller function for the PUT /role-bindings endpoint.
+// The RoleBinding gets read from the request body, an existing RoleBinding of the identity for the same repository gets replaced.
+func PutRoleBindingController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	binding := types.RoleBinding{}
+	err := json.Unmarshal([]byte(request.Body), &binding)
+	if err != nil || binding.Identity == "" || binding.Repository == "" {
+		return types.InvalidRequestBodyResponse, nil
+	}
+	if !binding.Role.Valid() {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"role must be one of viewer, repo-maintainer or admin\" }", StatusCode: 400}, nil
+	}
+
+	err = model.PutRoleBinding(&binding)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(binding)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PutRoleBindingController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// DeleteRoleBindingController is the controller function for the DELETE /role-bindings endpoint.
+// The "identity" and "repository" query parameters identify the RoleBinding, since identities (e.g. IAM ARNs) can contain "/".
+func DeleteRoleBindingController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	identity := request.QueryStringParameters["identity"]
+	repository := request.QueryStringParameters["repository"]
+	if identity == "" || repository == "" {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"identity and repository query parameters are required\" }", StatusCode: 400}, nil
+	}
+
+	binding := types.RoleBinding{}
+	err := model.DeleteRoleBinding(&binding, identity, repository)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	if binding.Identity == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// requestActor returns the identity of the caller of the request, it's recorded in the history of the changed items.
+// The principalId of an API Gateway authorizer is preferred, then the IAM identity. Unauthenticated callers are identified by their source IP.
+func requestActor(request events.APIGatewayProxyRequest) string {
+	if principal, ok := request.RequestContext.Authorizer["principalId"].(string); ok && principal != "" {
+		return principal
+	}
+
+	identity := request.RequestContext.Identity
+	switch {
+	case identity.UserArn != "":
+		return identity.UserArn
+	case identity.User != "":
+		return identity.User
+	case identity.Caller != "":
+		return identity.Caller
+	case identity.SourceIP != "":
+		return "anonymous@" + identity.SourceIP
+	}
+
+	return "anonymous"
+}
+
+// gitHubActor returns the actor of a GitHub webhook, it's the GitHub user who triggered the event.
+func gitHubActor(login string) string {
+	return webhookActor("github", login)
+}
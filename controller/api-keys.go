@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/router"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// APIKeyAuthenticationMiddleware authenticates requests with an APIKey in the "Authorization: Bearer" header, invalid or revoked keys are rejected with 401.
+// The caller identity of authenticated requests is "apikey:<id>", the permissions of the APIKey scope are enforced by the AccessControlMiddleware.
+// Requests without APIKey are passed through, as well as all routes without Permission (e.g. the HMAC secured GitHub webhook).
+func APIKeyAuthenticationMiddleware(route router.Route, next router.Controller) router.Controller {
+	if route.Permission == "" {
+		return next
+	}
+
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		token := bearerToken(request)
+		if !strings.HasPrefix(token, model.APIKeyPrefix) {
+			return next(request)
+		}
+
+		key, err := model.AuthenticateAPIKey(token)
+		if err == model.ErrInvalidAPIKey {
+			config.Logger.Log(err, map[string]string{"module": "controller/APIKeyAuthenticationMiddleware", "operation": "authenticate"}, 1)
+			response := types.UnauthorizedResponse
+			response.Headers = map[string]string{"WWW-Authenticate": "Bearer"}
+			return response, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+
+		grants := []string{}
+		for _, permission := range types.APIKeyScopePermissions[key.Scope] {
+			grants = append(grants, types.FormatGrant(permission, key.Repository))
+		}
+		return next(withAuthenticatedCaller(request, "apikey:"+key.ID, grants))
+	}
+}
+
+// GetAllAPIKeysController is the controller function for the GET /api-keys endpoint, the keys and their hashes are never returned.
+// If the limit or cursor query parameter is set, a single page is returned as APIKeyList, otherwise all APIKeys (including revoked ones) are returned.
+func GetAllAPIKeysController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllAPIKeysController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	var obj interface{}
+	if paginated {
+		list := types.APIKeyList{APIKeys: []types.APIKey{}}
+		list.NextCursor, err = model.GetAPIKeysPage(&list.APIKeys, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = list
+	} else {
+		keys := []types.APIKey{}
+		err = model.GetAllAPIKeys(&keys)
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = keys
+	}
+
+	body, err := json.Marshal(obj)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllAPIKeysController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// AddAPIKeyController is the controller function for the POST /api-keys endpoint.
+// The name, scope and optional repository restriction get read from the request body, the response contains the key. It's only returned once.
+func AddAPIKeyController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	post := types.APIKeyPost{}
+	err := json.Unmarshal([]byte(request.Body), &post)
+	if err != nil || post.Name == "" {
+		return types.InvalidRequestBodyResponse, nil
+	}
+	if _, ok := types.APIKeyScopePermissions[post.Scope]; !ok {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"scope must be one of read-only, trigger-only or full\" }", StatusCode: 400}, nil
+	}
+
+	key, secret, err := model.CreateAPIKey(post, requestActor(request))
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(types.APIKeyCreated{APIKey: key, Key: secret})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/AddAPIKeyController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 201}, nil
+}
+
+// RevokeAPIKeyController is the controller function for the DELETE /api-keys/{id} endpoint.
+// The "id" path parameter containing the APIKey id gets read from the APIGatewayProxyRequest struct, the revoked APIKey is kept and returned.
+func RevokeAPIKeyController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	key := types.APIKey{}
+	err := model.RevokeAPIKey(&key, request.PathParameters["id"])
+	if err != nil {
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(key)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/RevokeAPIKeyController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
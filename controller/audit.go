@@ -0,0 +1,357 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/router"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// auditIgnoredFields are computed fields which aren't stored, changes of them are not recorded.
+var auditIgnoredFields = map[string]bool{
+	"allowedActions": true,
+}
+
+// auditSecretFields are fields whose values must not be stored in the audit log, only the fact that they changed is recorded.
+var auditSecretFields = map[string]bool{
+	"webhookSecretToken": true,
+	"webhookSecret":      true,
+	"key":                true,
+}
+
+const auditRedacted = "[redacted]"
+
+// auditTarget is the item changed by an audited call, read returns the current state of the item or nil if it doesn't exist.
+// If afterFromResponse is set the state after the call is taken from the response body, because it can't be read (e.g. the id of a new APIKey isn't known before).
+type auditTarget struct {
+	target            string
+	id                string
+	repository        string
+	branch            string
+	read              func() interface{}
+	afterFromResponse bool
+}
+
+// targetRequestBody contains the fields identifying the target Repository and Environment in the request bodies of the POST endpoints.
+type targetRequestBody struct {
+	Repository string `json:"repository"`
+	Branch     string `json:"branch"`
+}
+
+// verifiedAuditRoutes are the routes secured by a signature in the controller, the body identifying the target and the actor is only trusted after the check.
+// Their controllers record the call with auditCall after the request was verified, so the AuditMiddleware skips them.
+var verifiedAuditRoutes = map[string]bool{
+	"/webhooks/github":    true,
+	"/webhooks/gitlab":    true,
+	"/webhooks/bitbucket": true,
+	"/webhooks/gitea":     true,
+	"/callbacks/builder":  true,
+}
+
+// AuditMiddleware records every call of a route with a mutating method in the audit log.
+// The state of the target gets read before and after the call, the changed fields are recorded together with the actor, the route and the response status code.
+func AuditMiddleware(route router.Route, next router.Controller) router.Controller {
+	if route.Method == http.MethodGet || verifiedAuditRoutes[route.Resource] {
+		return next
+	}
+
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		target, actor := resolveAuditTarget(route, request)
+		return auditCall(route.Method, route.Resource, target, actor, next, request)
+	}
+}
+
+// auditCall executes the controller and records the call of the route with the changes of the target in the audit log.
+func auditCall(method string, resource string, target auditTarget, actor string, next router.Controller, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	before := target.read()
+
+	response, err := next(request)
+
+	entry := types.AuditEntry{
+		Actor:      actor,
+		Method:     method,
+		Route:      resource,
+		Target:     target.target,
+		TargetID:   target.id,
+		Repository: target.repository,
+		Branch:     target.branch,
+		StatusCode: response.StatusCode,
+	}
+	if err == nil && response.StatusCode < 300 {
+		after := target.read()
+		if target.afterFromResponse {
+			fields := map[string]interface{}{}
+			if json.Unmarshal([]byte(response.Body), &fields) == nil {
+				after = fields
+			}
+			if id, ok := fields["id"].(string); ok && entry.TargetID == "" {
+				entry.TargetID = id
+			}
+		}
+		entry.Changes = auditChanges(before, after)
+	}
+	model.RecordAuditEntry(entry)
+
+	return response, err
+}
+
+// resolveAuditTarget returns the target of the call by the route and the actor who executed it.
+func resolveAuditTarget(route router.Route, request events.APIGatewayProxyRequest) (auditTarget, string) {
+	actor := requestActor(request)
+	name := request.PathParameters["name"]
+	branch, err := url.PathUnescape(request.PathParameters["branch"])
+	if err != nil {
+		branch = request.PathParameters["branch"]
+	}
+	body := targetRequestBody{}
+	json.Unmarshal([]byte(request.Body), &body)
+
+	switch route.Resource {
+	case "/configuration":
+		// The configuration is stored in the environment of the Lambda function, so the running instance only knows the new values from the response
+		return auditTarget{target: model.AuditTargetConfiguration, afterFromResponse: true, read: func() interface{} {
+			configuration := types.TowerConfiguration{}
+			if model.GetConfiguration(&configuration) != nil {
+				return nil
+			}
+			return configuration
+		}}, actor
+	case "/repositories/environments":
+		return auditTarget{target: model.AuditTargetGlobalConfig, read: func() interface{} {
+			configuration := types.GeneralConfig{}
+			if model.GetGlobalRepositoryConfiguration(&configuration, request.RequestContext.Stage) != nil {
+				return nil
+			}
+			return configuration
+		}}, actor
+	case "/repositories":
+		return repositoryAuditTarget(body.Repository), actor
+	case "/repositories/{name}":
+		return repositoryAuditTarget(name), actor
+	case "/repositories/{name}/webhook-secret":
+		// The secret isn't part of the Repository returned by the API, it's only recorded that it changed
+		return auditTarget{target: model.AuditTargetRepository, repository: name, read: func() interface{} {
+			repository := types.Repository{}
+			if model.GetSingleRepository(&repository, name) != nil || repository.Repository == "" {
+				return nil
+			}
+			secret := map[string]string{}
+			if repository.WebhookSecret != "" {
+				secret["webhookSecret"] = repository.WebhookSecret
+			}
+			return secret
+		}}, actor
+	case "/repositories/{name}/environments":
+		return environmentAuditTarget(name, body.Branch), actor
+	case "/repositories/{name}/environments/{branch}":
+		return environmentAuditTarget(name, branch), actor
+	case "/role-bindings":
+		binding := types.RoleBinding{}
+		json.Unmarshal([]byte(request.Body), &binding)
+		if request.HTTPMethod == http.MethodDelete {
+			binding.Identity = request.QueryStringParameters["identity"]
+			binding.Repository = request.QueryStringParameters["repository"]
+		}
+		return roleBindingAuditTarget(binding.Identity, binding.Repository), actor
+	case "/api-keys":
+		return auditTarget{target: model.AuditTargetAPIKey, afterFromResponse: true, read: func() interface{} {
+			return nil
+		}}, actor
+	case "/api-keys/{id}":
+		return apiKeyAuditTarget(request.PathParameters["id"]), actor
+	case "/webhooks/deliveries/{id}/replay":
+		// The replayed delivery changes the Environment of its payload, the caller of the replay is the actor
+		delivery := types.WebhookDelivery{}
+		model.GetSingleWebhookDelivery(&delivery, request.PathParameters["id"])
+		target, _ := gitHubAuditTarget(gitHubDeliveryRequest(delivery))
+		return target, actor
+	}
+
+	return environmentAuditTarget(body.Repository, body.Branch), actor
+}
+
+// gitHubAuditTarget returns the Environment of the branch in the GitHub webhook request and the GitHub user who triggered the event,
+// the request must be verified before.
+func gitHubAuditTarget(request events.APIGatewayProxyRequest) (auditTarget, string) {
+	webhook := types.GitHubWebhook{}
+	json.Unmarshal([]byte(request.Body), &webhook)
+	// Push events contain the full Git reference of the branch, pull request events the head branch of the pull request
+	branch := strings.TrimPrefix(webhook.Ref, "refs/heads/")
+	if webhook.PullRequest.Head.Ref != "" {
+		branch = webhook.PullRequest.Head.Ref
+	}
+	return environmentAuditTarget(webhook.Repository.Name, branch), gitHubActor(webhook.Sender.Login)
+}
+
+// webhookAuditTarget returns the Environment of the first webhook event parsed by the provider and the actor of the event,
+// if the request contains no event the provider name is the actor. The request must be verified before.
+func webhookAuditTarget(provider webhookProvider, name string, request events.APIGatewayProxyRequest) (auditTarget, string) {
+	event := webhookEvent{Actor: name}
+	parsed, err := provider.parse(request)
+	if err == nil && len(parsed) > 0 {
+		event = parsed[0]
+	}
+	return environmentAuditTarget(event.Repository, event.Branch), event.Actor
+}
+
+func repositoryAuditTarget(name string) auditTarget {
+	return auditTarget{target: model.AuditTargetRepository, repository: name, read: func() interface{} {
+		repository := types.Repository{}
+		if name == "" || model.GetSingleRepository(&repository, name) != nil || repository.Repository == "" {
+			return nil
+		}
+		return repository
+	}}
+}
+
+func roleBindingAuditTarget(identity string, repository string) auditTarget {
+	return auditTarget{target: model.AuditTargetRoleBinding, id: identity, repository: repository, read: func() interface{} {
+		binding := types.RoleBinding{}
+		if identity == "" || model.GetSingleRoleBinding(&binding, identity, repository) != nil || binding.Identity == "" {
+			return nil
+		}
+		return binding
+	}}
+}
+
+func apiKeyAuditTarget(id string) auditTarget {
+	return auditTarget{target: model.AuditTargetAPIKey, id: id, read: func() interface{} {
+		key := types.APIKey{}
+		if id == "" || model.GetSingleAPIKey(&key, id) != nil || key.ID == "" {
+			return nil
+		}
+		return key
+	}}
+}
+
+func environmentAuditTarget(name string, branch string) auditTarget {
+	return auditTarget{target: model.AuditTargetEnvironment, repository: name, branch: branch, read: func() interface{} {
+		environment := types.Environment{}
+		if name == "" || branch == "" || model.GetSingleEnvironmentForRepository(&environment, name, branch) != nil || environment.Repository == "" {
+			return nil
+		}
+		return environment
+	}}
+}
+
+// auditChanges compares the JSON representation of the target before and after the call and returns the changed top level fields sorted by name.
+// A nil state means the target didn't exist, so all fields of the other state are recorded.
+func auditChanges(before interface{}, after interface{}) []types.AuditChange {
+	beforeFields := auditFields(before)
+	afterFields := auditFields(after)
+
+	names := []string{}
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	changes := []types.AuditChange{}
+	for _, name := range names {
+		if auditIgnoredFields[name] || reflect.DeepEqual(beforeFields[name], afterFields[name]) {
+			continue
+		}
+		change := types.AuditChange{Field: name, Before: beforeFields[name], After: afterFields[name]}
+		if auditSecretFields[name] {
+			if change.Before != nil {
+				change.Before = auditRedacted
+			}
+			if change.After != nil {
+				change.After = auditRedacted
+			}
+		}
+		changes = append(changes, change)
+	}
+
+	return changes
+}
+
+func auditFields(state interface{}) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if state == nil {
+		return fields
+	}
+
+	body, err := json.Marshal(state)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/auditFields", "operation": "marshal"}, 0)
+		return fields
+	}
+	json.Unmarshal(body, &fields)
+	return fields
+}
+
+// GetAuditController is the controller function for the GET /audit endpoint.
+// The query parameters "from" and "to" (RFC 3339 times) restrict the time range, "target", "repository" and "branch" restrict the target of the entries.
+// If the limit or cursor query parameter is set, a single page is returned as AuditList, otherwise all matching entries are returned newest first.
+func GetAuditController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAuditController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	filter := model.AuditFilter{
+		Target:     request.QueryStringParameters["target"],
+		Repository: request.QueryStringParameters["repository"],
+		Branch:     request.QueryStringParameters["branch"],
+	}
+	for parameter, bound := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		value := request.QueryStringParameters[parameter]
+		if value == "" {
+			continue
+		}
+		*bound, err = time.Parse(time.RFC3339, value)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "controller/GetAuditController", "operation": "parseTime"}, 4)
+			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"" + parameter + " must be a RFC 3339 time\" }", StatusCode: 400}, nil
+		}
+	}
+	switch filter.Target {
+	case "", model.AuditTargetConfiguration, model.AuditTargetGlobalConfig, model.AuditTargetRepository, model.AuditTargetEnvironment, model.AuditTargetRoleBinding, model.AuditTargetAPIKey:
+	default:
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unknown target " + filter.Target + "\" }", StatusCode: 400}, nil
+	}
+
+	var obj interface{}
+	if paginated {
+		list := types.AuditList{Entries: []types.AuditEntry{}}
+		list.NextCursor, err = model.GetAuditEntriesPage(&list.Entries, filter, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = list
+	} else {
+		entries := []types.AuditEntry{}
+		err = model.GetAllAuditEntries(&entries, filter)
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = entries
+	}
+
+	body, err := json.Marshal(obj)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAuditController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
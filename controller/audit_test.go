@@ -0,0 +1,53 @@
+package controller
+
+import (
+	"os"
+	"testing"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestWebhookAudit(t *testing.T) {
+	os.Setenv("WEBHOOK_SECRET_TOKEN", "secret")
+	defer os.Unsetenv("WEBHOOK_SECRET_TOKEN")
+
+	tests := []struct {
+		name           string
+		token          string
+		wantStatusCode int
+		wantEntries    int
+	}{
+		{"invalid token", "forged", 400, 0},
+		{"valid token", "secret", 404, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			model.SetStore(model.NewMemoryStore())
+
+			response, err := GitLabWebhookController(events.APIGatewayProxyRequest{
+				Headers: map[string]string{"X-Gitlab-Event": "Push Hook", "X-Gitlab-Token": test.token},
+				Body:    `{"before": "0000000000000000000000000000000000000000", "after": "3c4d", "ref": "refs/heads/main", "user_username": "octocat", "project": {"path_with_namespace": "group/audit"}}`,
+			})
+			if err != nil {
+				t.Fatalf("GitLabWebhookController returned %v", err)
+			}
+			if response.StatusCode != test.wantStatusCode {
+				t.Errorf("status code %d, want %d (%s)", response.StatusCode, test.wantStatusCode, response.Body)
+			}
+
+			entries := []types.AuditEntry{}
+			if err := model.GetAllAuditEntries(&entries, model.AuditFilter{}); err != nil {
+				t.Fatalf("GetAllAuditEntries returned %v", err)
+			}
+			if len(entries) != test.wantEntries {
+				t.Fatalf("%d audit entries, want %d", len(entries), test.wantEntries)
+			}
+			if test.wantEntries > 0 && (entries[0].Actor != "gitlab:octocat" || entries[0].Route != "/webhooks/gitlab") {
+				t.Errorf("audit entry of %q for %q, want gitlab:octocat for /webhooks/gitlab", entries[0].Actor, entries[0].Route)
+			}
+		})
+	}
+}
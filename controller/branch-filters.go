@@ -0,0 +1,101 @@
+package controller
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// branchFilter is a compiled rule of the repository Filters.
+// The rule syntax is "[!][sender:][glob:|regex:]<pattern>", a leading "!" makes it an exclude rule and "sender:" matches the login of the webhook sender instead of the branch.
+// Patterns without syntax prefix are regular expressions, so filters stored before the prefixes existed keep their meaning.
+type branchFilter struct {
+	exclude bool
+	sender  bool
+	pattern *regexp.Regexp
+}
+
+// compileBranchFilters compiles all rules of the Filters, the error of an invalid rule names the rule and the reason.
+func compileBranchFilters(filters []string) ([]branchFilter, error) {
+	compiled := []branchFilter{}
+	for _, filter := range filters {
+		rule := branchFilter{}
+		pattern := filter
+		if strings.HasPrefix(pattern, "!") {
+			rule.exclude = true
+			pattern = strings.TrimPrefix(pattern, "!")
+		}
+		if strings.HasPrefix(pattern, "sender:") {
+			rule.sender = true
+			pattern = strings.TrimPrefix(pattern, "sender:")
+		}
+
+		expression := strings.TrimPrefix(pattern, "regex:")
+		if strings.HasPrefix(pattern, "glob:") {
+			expression = globToRegexp(strings.TrimPrefix(pattern, "glob:"))
+		}
+
+		var err error
+		rule.pattern, err = regexp.Compile(expression)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid filter %q: %v", filter, err)
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+// globToRegexp returns the anchored regular expression of the glob, "*" and "?" don't match "/" while "**" matches any characters.
+func globToRegexp(glob string) string {
+	builder := strings.Builder{}
+	builder.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch {
+		case strings.HasPrefix(glob[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case glob[i] == '*':
+			builder.WriteString("[^/]*")
+		case glob[i] == '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	builder.WriteString("$")
+	return builder.String()
+}
+
+// matchesFilters returns true if the branch and the sender of a webhook event pass the repository Filters.
+// An event passes if no exclude rule matches, the branch matches one of the branch include rules and the sender matches one of the sender include rules.
+// Missing include rules of a kind don't restrict the event, but a repository without Filters doesn't match any branch.
+func matchesFilters(filters []string, branch string, sender string) (bool, error) {
+	compiled, err := compileBranchFilters(filters)
+	if err != nil || len(compiled) == 0 {
+		return false, err
+	}
+
+	branchRules, branchHit := 0, false
+	senderRules, senderHit := 0, false
+	for _, rule := range compiled {
+		value := branch
+		if rule.sender {
+			value = sender
+		}
+		match := rule.pattern.MatchString(value)
+
+		switch {
+		case rule.exclude && match:
+			return false, nil
+		case rule.exclude:
+		case rule.sender:
+			senderRules++
+			senderHit = senderHit || match
+		default:
+			branchRules++
+			branchHit = branchHit || match
+		}
+	}
+
+	return (branchRules == 0 || branchHit) && (senderRules == 0 || senderHit), nil
+}
@@ -0,0 +1,90 @@
+package controller
+
+import "testing"
+
+func TestGlobToRegexp(t *testing.T) {
+	tests := []struct {
+		glob string
+		want string
+	}{
+		{"main", "^main$"},
+		{"feature/*", "^feature/[^/]*$"},
+		{"feature/**", "^feature/.*$"},
+		{"**/hotfix", "^.*/hotfix$"},
+		{"v1.?", `^v1\.[^/]$`},
+		{"release-(1.0)+[rc]", `^release-\(1\.0\)\+\[rc\]$`},
+		{"a|b^c$", `^a\|b\^c\$$`},
+		{"dependabot*", "^dependabot[^/]*$"},
+		{"", "^$"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.glob, func(t *testing.T) {
+			if got := globToRegexp(test.glob); got != test.want {
+				t.Errorf("globToRegexp(%q) = %q, want %q", test.glob, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchesFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters []string
+		branch  string
+		sender  string
+		want    bool
+	}{
+		{"no filters", nil, "feature", "alice", false},
+		{"regular expression without prefix", []string{"^feat"}, "feat-1", "alice", true},
+		{"regular expression without prefix doesn't match", []string{"^feat"}, "fix-1", "alice", false},
+		{"regex prefix", []string{"regex:^fix-[0-9]+$"}, "fix-12", "alice", true},
+		{"regex prefix keeps exclamation mark", []string{"regex:!x"}, "!x", "alice", true},
+		{"glob star", []string{"glob:feature/*"}, "feature/login", "alice", true},
+		{"glob star doesn't match slash", []string{"glob:feature/*"}, "feature/login/form", "alice", false},
+		{"glob double star matches slash", []string{"glob:feature/**"}, "feature/login/form", "alice", true},
+		{"glob question mark", []string{"glob:v1.?"}, "v1.2", "alice", true},
+		{"glob dot is escaped", []string{"glob:v1.?"}, "v102", "alice", false},
+		{"glob is anchored", []string{"glob:feature/*"}, "old-feature/login", "alice", false},
+		{"one of multiple includes", []string{"glob:feature/*", "glob:fix/*"}, "fix/typo", "alice", true},
+		{"only excludes", []string{"!glob:dependabot/**"}, "main", "alice", true},
+		{"exclude", []string{"!glob:dependabot/**"}, "dependabot/npm/lodash", "alice", false},
+		{"exclude before include", []string{"!glob:feature/wip-*", "glob:feature/*"}, "feature/wip-login", "alice", false},
+		{"exclude after include", []string{"glob:feature/*", "!glob:feature/wip-*"}, "feature/wip-login", "alice", false},
+		{"include with exclude not matching", []string{"glob:feature/*", "!glob:feature/wip-*"}, "feature/login", "alice", true},
+		{"sender include", []string{"sender:^alice$"}, "feature", "alice", true},
+		{"sender include doesn't match", []string{"sender:^alice$"}, "feature", "bob", false},
+		{"sender exclude", []string{".*", "!sender:glob:dependabot*"}, "feature", "dependabot[bot]", false},
+		{"sender exclude doesn't match", []string{".*", "!sender:glob:dependabot*"}, "feature", "alice", true},
+		{"branch and sender include", []string{"glob:feature/*", "sender:glob:alice"}, "feature/login", "alice", true},
+		{"branch matches but sender doesn't", []string{"glob:feature/*", "sender:glob:alice"}, "feature/login", "bob", false},
+		{"sender matches but branch doesn't", []string{"glob:feature/*", "sender:glob:alice"}, "main", "alice", false},
+		{"sender rule doesn't match the branch", []string{"sender:glob:feature/*"}, "feature/login", "alice", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := matchesFilters(test.filters, test.branch, test.sender)
+			if err != nil {
+				t.Fatalf("matchesFilters(%q) returned %v", test.filters, err)
+			}
+			if got != test.want {
+				t.Errorf("matchesFilters(%q, %q, %q) = %t, want %t", test.filters, test.branch, test.sender, got, test.want)
+			}
+		})
+	}
+}
+
+func TestMatchesFiltersInvalid(t *testing.T) {
+	tests := [][]string{
+		{"[bad"},
+		{"glob:feature/*", "!regex:(unclosed"},
+		{"sender:regex:*alice"},
+	}
+
+	for _, filters := range tests {
+		if _, err := matchesFilters(filters, "feature/login", "alice"); err == nil {
+			t.Errorf("matchesFilters(%q) returned no error", filters)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// BuilderCallbackController is the controller function for the POST /callbacks/builder endpoint.
+// The Builder reports the result of a CREATE, UPDATE or DELETE operation with the operation ID it received in the BuilderEvent,
+// the result gets applied to the Environment lifecycle.
+// The endpoint is secured through HMAC, the X-Auto-Staging-Signature header must contain the HMAC-SHA256 of the body ("sha256=<hex>")
+// created with the BUILDER_CALLBACK_SECRET. Only verified callbacks are recorded in the audit log, the Builder is the actor.
+func BuilderCallbackController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if !verifyCallbackSignature(request.Body, getHeader(request, "X-Auto-Staging-Signature")) {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Signature validation failed\" }", StatusCode: 401}, nil
+	}
+
+	body := targetRequestBody{}
+	json.Unmarshal([]byte(request.Body), &body)
+	return auditCall(http.MethodPost, "/callbacks/builder", environmentAuditTarget(body.Repository, body.Branch), model.BuilderComponent, applyBuilderCallback, request)
+}
+
+// applyBuilderCallback applies the result of the verified callback to the Environment lifecycle.
+func applyBuilderCallback(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	callback := types.BuilderCallback{}
+	err := json.Unmarshal([]byte(request.Body), &callback)
+	if err != nil || callback.Repository == "" || callback.Branch == "" || callback.OperationID == "" {
+		return types.InvalidRequestBodyResponse, nil
+	}
+
+	err = model.ApplyBuilderCallback(callback)
+	if err != nil {
+		switch {
+		case err == model.ErrUnknownOperation:
+			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"operation must be CREATE, UPDATE or DELETE\" }", StatusCode: 400}, nil
+		case err == model.ErrStatusConflict:
+			config.Logger.Log(errors.New("Ignoring callback for operation "+callback.OperationID), map[string]string{"module": "controller/applyBuilderCallback", "operation": "statusCheck"}, 1)
+			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Operation isn't in progress for the environment\" }", StatusCode: 409}, nil
+		case model.IsConditionalCheckFailed(err):
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
+}
+
+func verifyCallbackSignature(body string, signature string) bool {
+	secret := os.Getenv("BUILDER_CALLBACK_SECRET")
+	if secret == "" {
+		config.Logger.Log(errors.New("BUILDER_CALLBACK_SECRET isn't set"), map[string]string{"module": "controller/verifyCallbackSignature", "operation": "getSecret"}, 0)
+		return false
+	}
+	if !strings.HasPrefix(signature, "sha256=") {
+		return false
+	}
+
+	messageMAC, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/verifyCallbackSignature", "operation": "decodeString"}, 1)
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+
+	return hmac.Equal(messageMAC, mac.Sum(nil))
+}
@@ -14,12 +14,52 @@ import (
 
 // GetAllEnvironmentsForRepositoryController is the controller function for the GET /repositories/{name}/environments endpoint.
 // The "name" path parameter containing the Repository name gets read from the APIGatewayProxyRequest struct
+// The result can be filtered by status, branchPrefix, branch (regular expression), createdAfter and createdBefore and sorted by creationDate or branch (sort and order query parameters).
+// If the limit or cursor query parameter is set, a single page gets returned as EnvironmentList including the cursor for the next page.
+// The filters are applied to the fetched page, so a page can contain fewer items than the limit or even none while the cursor for the next page is still set.
 func GetAllEnvironmentsForRepositoryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllEnvironmentsForRepositoryController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	query, err := readEnvironmentQuery(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllEnvironmentsForRepositoryController", "operation": "readEnvironmentQuery"}, 4)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"" + err.Error() + "\" }", StatusCode: 400}, nil
+	}
+
+	if paginated {
+		if query.sortBy != "" {
+			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"sort can't be combined with limit or cursor\" }", StatusCode: 400}, nil
+		}
+
+		list := types.EnvironmentList{Environments: []types.Environment{}}
+		list.NextCursor, err = model.GetEnvironmentsForRepositoryPage(&list.Environments, request.PathParameters["name"], limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		list.Environments = query.applyToEnvironments(list.Environments)
+
+		body, err := json.Marshal(list)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "controller/GetAllEnvironmentsForRepositoryController", "operation": "marshal"}, 0)
+			return types.InternalServerErrorResponse, nil
+		}
+
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	}
+
 	var obj []types.Environment
-	err := model.GetAllEnvironmentsForRepository(&obj, request.PathParameters["name"])
+	err = model.GetAllEnvironmentsForRepository(&obj, request.PathParameters["name"])
 	if err != nil {
 		return types.InternalServerErrorResponse, nil
 	}
+	obj = query.applyToEnvironments(obj)
 
 	body, err := json.Marshal(obj)
 	if err != nil {
@@ -38,6 +78,10 @@ func AddEnvironmentForRepositoryController(request events.APIGatewayProxyRequest
 	if err != nil {
 		return types.InvalidRequestBodyResponse, nil
 	}
+	if err := validateSchedules(env.StartupSchedules, env.ShutdownSchedules); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/AddEnvironmentForRepositoryController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
 
 	repository := types.Repository{}
 	err = model.GetSingleRepository(&repository, request.PathParameters["name"])
@@ -49,10 +93,10 @@ func AddEnvironmentForRepositoryController(request events.APIGatewayProxyRequest
 	}
 
 	env.Branch = strings.TrimSpace(env.Branch)
-	result, err := model.AddEnvironmentForRepository(env, request.PathParameters["name"])
+	result, err := model.AddEnvironmentForRepository(env, request.PathParameters["name"], requestActor(request))
 
 	if err != nil {
-		if strings.Contains(err.Error(), "ConditionalCheckFailedException") {
+		if model.IsConditionalCheckFailed(err) {
 			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unique constraint violation\" }", StatusCode: 400}, nil
 		}
 		return types.InternalServerErrorResponse, nil
@@ -91,13 +135,19 @@ func GetSingleEnvironmentForRepositoryController(request events.APIGatewayProxyR
 		return types.InternalServerErrorResponse, nil
 	}
 
-	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: map[string]string{"ETag": formatETag(obj.Version)}}, nil
 }
 
 // PutSinglEnvironmentForRepositoryController is the controller function for the PUT /repositories/{name}/environments/{branch} endpoint.
 // The "name" path parameter containing the Repository name, the "branch" path parameter containing the branch name
-// and the request body containing the updated information for the Environment gets read from the APIGatewayProxyRequest struct
+// and the request body containing the updated information for the Environment gets read from the APIGatewayProxyRequest struct.
+// If the If-Match header is set, the Environment only gets updated if the ETag matches the stored version, otherwise 412 gets returned.
 func PutSinglEnvironmentForRepositoryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	expectedVersion, ok := readIfMatch(request)
+	if !ok {
+		return types.PreconditionFailedResponse, nil
+	}
+
 	status := types.EnvironmentStatus{}
 	branch, err := url.PathUnescape(request.PathParameters["branch"])
 	if err != nil {
@@ -109,8 +159,8 @@ func PutSinglEnvironmentForRepositoryController(request events.APIGatewayProxyRe
 		return types.InternalServerErrorResponse, nil
 	}
 
-	if status.Status != "running" && status.Status != "updating failed" {
-		config.Logger.Log(errors.New("Can't update environment in status = "+status.Status), map[string]string{"module": "controller/PutSinglEnvironmentForRepositoryController", "operation": "statusCheck"}, 0)
+	if !status.Status.Allows(types.EnvironmentActionUpdate) {
+		config.Logger.Log(errors.New("Can't update environment in status = "+string(status.Status)), map[string]string{"module": "controller/PutSinglEnvironmentForRepositoryController", "operation": "statusCheck"}, 0)
 		return types.InvalidEnvironmentStatusResponse, nil
 	}
 
@@ -124,11 +174,21 @@ func PutSinglEnvironmentForRepositoryController(request events.APIGatewayProxyRe
 		config.Logger.Log(err, map[string]string{"module": "controller/PutSinglEnvironmentForRepositoryController", "operation": "validateCodeBuildRoleARN"}, 1)
 		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"codeBuildRoleARN is not a valid IAM Role ARN\" }", StatusCode: 400}, nil
 	}
+	if err := validateSchedules(environment.StartupSchedules, environment.ShutdownSchedules); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PutSinglEnvironmentForRepositoryController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
 
-	result, err := model.UpdateEnvironment(&environment, request.PathParameters["name"], branch)
+	result, err := model.UpdateEnvironment(&environment, request.PathParameters["name"], branch, expectedVersion, requestActor(request))
 
 	if err != nil {
-		if strings.Contains(err.Error(), "ConditionalCheckFailedException") {
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
+		if err == model.ErrVersionMismatch {
+			return types.PreconditionFailedResponse, nil
+		}
+		if model.IsConditionalCheckFailed(err) {
 			return types.NotFoundErrorResponse, nil
 		}
 		return types.InternalServerErrorResponse, nil
@@ -140,7 +200,85 @@ func PutSinglEnvironmentForRepositoryController(request events.APIGatewayProxyRe
 		return types.InternalServerErrorResponse, nil
 	}
 
-	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: map[string]string{"ETag": formatETag(result.Version)}}, nil
+}
+
+// PatchSingleEnvironmentForRepositoryController is the controller function for the PATCH /repositories/{name}/environments/{branch} endpoint.
+// The request body containing a JSON merge patch (RFC 7396) gets applied to the stored Environment, fields missing in the patch keep their values.
+// If the If-Match header is set, the Environment only gets updated if the ETag matches the stored version, otherwise 412 gets returned.
+func PatchSingleEnvironmentForRepositoryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	expectedVersion, ok := readIfMatch(request)
+	if !ok {
+		return types.PreconditionFailedResponse, nil
+	}
+	if !isMergePatchRequest(request) {
+		return unsupportedMediaTypeResponse, nil
+	}
+
+	branch, err := url.PathUnescape(request.PathParameters["branch"])
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleEnvironmentForRepositoryController", "operation": "pathUnescape"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	current := types.Environment{}
+	err = model.GetSingleEnvironmentForRepository(&current, request.PathParameters["name"], branch)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+	if current.Repository == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	if !current.Status.Allows(types.EnvironmentActionUpdate) {
+		config.Logger.Log(errors.New("Can't update environment in status = "+string(current.Status)), map[string]string{"module": "controller/PatchSingleEnvironmentForRepositoryController", "operation": "statusCheck"}, 0)
+		return types.InvalidEnvironmentStatusResponse, nil
+	}
+	if expectedVersion != model.AnyVersion && expectedVersion != current.Version {
+		return types.PreconditionFailedResponse, nil
+	}
+
+	environment := types.EnvironmentPut{}
+	err = applyMergePatch(current, []byte(request.Body), &environment)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleEnvironmentForRepositoryController", "operation": "applyMergePatch"}, 4)
+		return types.InvalidRequestBodyResponse, nil
+	}
+	if !validateIAMRoleARN(environment.CodeBuildRoleARN) {
+		config.Logger.Log(errors.New("Invalid codeBuildRoleARN"), map[string]string{"module": "controller/PatchSingleEnvironmentForRepositoryController", "operation": "validateCodeBuildRoleARN"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"codeBuildRoleARN is not a valid IAM Role ARN\" }", StatusCode: 400}, nil
+	}
+	if err := validateSchedules(environment.StartupSchedules, environment.ShutdownSchedules); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleEnvironmentForRepositoryController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
+
+	// The update is based on the version read above, so concurrent updates between reading and writing aren't overwritten
+	result, err := model.UpdateEnvironment(&environment, request.PathParameters["name"], branch, current.Version, requestActor(request))
+
+	if err != nil {
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
+		if err == model.ErrVersionMismatch {
+			if expectedVersion != model.AnyVersion {
+				return types.PreconditionFailedResponse, nil
+			}
+			return concurrentModificationResponse, nil
+		}
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleEnvironmentForRepositoryController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: map[string]string{"ETag": formatETag(result.Version)}}, nil
 }
 
 // DeleteSingleEnvironmentController is the controller function for the DELETE /repositories/{name}/environments/{branch} endpoint.
@@ -161,13 +299,19 @@ func DeleteSingleEnvironmentController(request events.APIGatewayProxyRequest) (e
 		return types.NotFoundErrorResponse, nil
 	}
 
-	if status.Status != "running" && status.Status != "stopped" && status.Status != "initiating failed" && status.Status != "destroying failed" {
-		config.Logger.Log(errors.New("Can't delete environment in status = "+status.Status), map[string]string{"module": "controller/DeleteSingleEnvironmentController", "operation": "statusCheck"}, 0)
+	if !status.Status.Allows(types.EnvironmentActionDestroy) {
+		config.Logger.Log(errors.New("Can't delete environment in status = "+string(status.Status)), map[string]string{"module": "controller/DeleteSingleEnvironmentController", "operation": "statusCheck"}, 0)
 		return types.InvalidEnvironmentStatusResponse, nil
 	}
 
-	err = model.DeleteSingleEnvironment(request.PathParameters["name"], branch)
+	err = model.DeleteSingleEnvironment(request.PathParameters["name"], branch, requestActor(request))
+	if err == model.ErrStatusConflict {
+		return types.EnvironmentStatusConflictResponse, nil
+	}
 	if err != nil {
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
 		return types.InternalServerErrorResponse, nil
 	}
 
@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// getHeader returns the value of the request header matching name case-insensitively, since API Gateway passes the header names as sent by the client.
+func getHeader(request events.APIGatewayProxyRequest, name string) string {
+	if value, ok := request.Headers[name]; ok {
+		return value
+	}
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+	return ""
+}
+
+// formatETag returns the ETag header value for the version of a Repository or Environment.
+func formatETag(version int64) string {
+	return "\"" + strconv.FormatInt(version, 10) + "\""
+}
+
+// readIfMatch returns the version expected by the If-Match header of the request, without header or with "*" the version is model.AnyVersion.
+// If the header contains a weak, malformed or more than one ETag, false gets returned since it can't match the stored version.
+func readIfMatch(request events.APIGatewayProxyRequest) (int64, bool) {
+	ifMatch := strings.TrimSpace(getHeader(request, "If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return model.AnyVersion, true
+	}
+
+	if len(ifMatch) < 2 || !strings.HasPrefix(ifMatch, "\"") || !strings.HasSuffix(ifMatch, "\"") {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(ifMatch[1:len(ifMatch)-1], 10, 64)
+	if err != nil || version < 0 {
+		return 0, false
+	}
+
+	return version, true
+}
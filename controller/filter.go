@@ -0,0 +1,176 @@
+package controller
+
+import (
+	"errors"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// creationDateLayout is the layout of the Environment creationDate, it's written with time.Time.String().
+const creationDateLayout = "2006-01-02 15:04:05.999999999 -0700 MST"
+
+// environmentQuery contains the filter and sort options for environment lists read from the query parameters.
+type environmentQuery struct {
+	statuses      map[string]bool
+	branchPrefix  string
+	branchRegex   *regexp.Regexp
+	createdAfter  time.Time
+	createdBefore time.Time
+	sortBy        string
+	descending    bool
+}
+
+// readEnvironmentQuery reads the filter and sort query parameters from the request:
+//
+// status - comma separated list of status values
+//
+// branchPrefix - only branches starting with the prefix
+//
+// branch - only branches matching the regular expression
+//
+// createdAfter / createdBefore - creation date range as RFC3339 timestamp or date (2006-01-02)
+//
+// sort - creationDate or branch, order - asc (default) or desc
+func readEnvironmentQuery(request events.APIGatewayProxyRequest) (environmentQuery, error) {
+	parameters := request.QueryStringParameters
+	query := environmentQuery{
+		branchPrefix: parameters["branchPrefix"],
+		sortBy:       parameters["sort"],
+	}
+
+	if parameters["status"] != "" {
+		query.statuses = map[string]bool{}
+		for _, status := range strings.Split(parameters["status"], ",") {
+			query.statuses[strings.TrimSpace(status)] = true
+		}
+	}
+
+	if parameters["branch"] != "" {
+		regex, err := regexp.Compile(parameters["branch"])
+		if err != nil {
+			return environmentQuery{}, errors.New("branch is not a valid regular expression")
+		}
+		query.branchRegex = regex
+	}
+
+	var err error
+	if parameters["createdAfter"] != "" {
+		query.createdAfter, err = parseQueryTime(parameters["createdAfter"])
+		if err != nil {
+			return environmentQuery{}, errors.New("createdAfter must be a RFC3339 timestamp or a date")
+		}
+	}
+	if parameters["createdBefore"] != "" {
+		query.createdBefore, err = parseQueryTime(parameters["createdBefore"])
+		if err != nil {
+			return environmentQuery{}, errors.New("createdBefore must be a RFC3339 timestamp or a date")
+		}
+	}
+
+	if query.sortBy != "" && query.sortBy != "creationDate" && query.sortBy != "branch" {
+		return environmentQuery{}, errors.New("sort must be creationDate or branch")
+	}
+
+	switch parameters["order"] {
+	case "", "asc":
+	case "desc":
+		query.descending = true
+	default:
+		return environmentQuery{}, errors.New("order must be asc or desc")
+	}
+
+	return query, nil
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err == nil {
+		return parsed, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// parseCreationDate parses the creationDate of an Environment, invalid or missing dates result in the zero time.
+func parseCreationDate(creationDate string) time.Time {
+	parsed, err := time.Parse(creationDateLayout, creationDate)
+	if err != nil {
+		return time.Time{}
+	}
+	return parsed
+}
+
+func (q environmentQuery) match(branch string, status string, creationDate string) bool {
+	if q.statuses != nil && !q.statuses[status] {
+		return false
+	}
+	if !strings.HasPrefix(branch, q.branchPrefix) {
+		return false
+	}
+	if q.branchRegex != nil && !q.branchRegex.MatchString(branch) {
+		return false
+	}
+	if !q.createdAfter.IsZero() || !q.createdBefore.IsZero() {
+		created := parseCreationDate(creationDate)
+		if created.IsZero() {
+			return false
+		}
+		if !q.createdAfter.IsZero() && created.Before(q.createdAfter) {
+			return false
+		}
+		if !q.createdBefore.IsZero() && created.After(q.createdBefore) {
+			return false
+		}
+	}
+	return true
+}
+
+func (q environmentQuery) less(branchA string, creationDateA string, branchB string, creationDateB string) bool {
+	if q.descending {
+		branchA, creationDateA, branchB, creationDateB = branchB, creationDateB, branchA, creationDateA
+	}
+	if q.sortBy == "creationDate" {
+		return parseCreationDate(creationDateA).Before(parseCreationDate(creationDateB))
+	}
+	return branchA < branchB
+}
+
+// applyToEnvironments returns the Environments matching the filters, sorted if a sort option was set.
+func (q environmentQuery) applyToEnvironments(environments []types.Environment) []types.Environment {
+	result := []types.Environment{}
+	for _, environment := range environments {
+		if q.match(environment.Branch, string(environment.Status), environment.CreationDate) {
+			result = append(result, environment)
+		}
+	}
+
+	if q.sortBy != "" {
+		sort.SliceStable(result, func(i, j int) bool {
+			return q.less(result[i].Branch, result[i].CreationDate, result[j].Branch, result[j].CreationDate)
+		})
+	}
+
+	return result
+}
+
+// applyToStatus returns the EnvironmentStatus entries matching the filters, sorted if a sort option was set.
+func (q environmentQuery) applyToStatus(status []types.EnvironmentStatus) []types.EnvironmentStatus {
+	result := []types.EnvironmentStatus{}
+	for _, entry := range status {
+		if q.match(entry.Branch, string(entry.Status), entry.CreationDate) {
+			result = append(result, entry)
+		}
+	}
+
+	if q.sortBy != "" {
+		sort.SliceStable(result, func(i, j int) bool {
+			return q.less(result[i].Branch, result[i].CreationDate, result[j].Branch, result[j].CreationDate)
+		})
+	}
+
+	return result
+}
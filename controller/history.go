@@ -0,0 +1,57 @@
+package controller
+
+import (
+	"encoding/json"
+	"net/url"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GetEnvironmentHistoryController is the controller function for the GET /repositories/{name}/environments/{branch}/history endpoint.
+// The "name" path parameter containing the Repository name and the "branch" path parameter containing the branch name gets read from the APIGatewayProxyRequest struct.
+// The history is kept after the Environment was removed, so an unknown Environment returns an empty history instead of 404.
+// If the limit or cursor query parameter is set, a single page is returned as EnvironmentHistoryList, otherwise the complete history is returned.
+func GetEnvironmentHistoryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetEnvironmentHistoryController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	branch, err := url.PathUnescape(request.PathParameters["branch"])
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetEnvironmentHistoryController", "operation": "pathUnescape"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	var obj interface{}
+	if paginated {
+		list := types.EnvironmentHistoryList{Entries: []types.EnvironmentHistoryEntry{}}
+		list.NextCursor, err = model.GetEnvironmentHistoryPage(&list.Entries, request.PathParameters["name"], branch, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = list
+	} else {
+		entries := []types.EnvironmentHistoryEntry{}
+		err = model.GetAllEnvironmentHistory(&entries, request.PathParameters["name"], branch)
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		obj = entries
+	}
+
+	body, err := json.Marshal(obj)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetEnvironmentHistoryController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
@@ -0,0 +1,88 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"mime"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// unsupportedMediaTypeResponse is returned by the PATCH endpoints if the request body isn't a JSON merge patch.
+var unsupportedMediaTypeResponse = events.APIGatewayProxyResponse{
+	Body:       "{ \"message\" : \"Content-Type must be application/merge-patch+json\" }",
+	StatusCode: 415,
+}
+
+// concurrentModificationResponse is returned by the PATCH endpoints without If-Match header, if the item was updated between reading and writing it.
+var concurrentModificationResponse = events.APIGatewayProxyResponse{
+	Body:       "{ \"message\" : \"The resource was modified concurrently, please retry the request\" }",
+	StatusCode: 409,
+}
+
+// isMergePatchRequest checks the Content-Type of a PATCH request, application/merge-patch+json and application/json are accepted.
+// Requests without Content-Type are accepted as well, since most clients default to application/json.
+func isMergePatchRequest(request events.APIGatewayProxyRequest) bool {
+	contentType := getHeader(request, "Content-Type")
+	if contentType == "" {
+		return true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mediaType == "application/merge-patch+json" || mediaType == "application/json"
+}
+
+// applyMergePatch applies the JSON merge patch (RFC 7396) from the patch parameter to the JSON representation of target
+// and writes the patched document to result (call by reference).
+// Keys with null values are removed, objects are merged recursively and all other values (including arrays) are replaced.
+func applyMergePatch(target interface{}, patch []byte, result interface{}) error {
+	var patchDocument interface{}
+	err := json.Unmarshal(patch, &patchDocument)
+	if err != nil {
+		return err
+	}
+	if _, ok := patchDocument.(map[string]interface{}); !ok {
+		return errors.New("Merge patch must be a JSON object")
+	}
+
+	body, err := json.Marshal(target)
+	if err != nil {
+		return err
+	}
+	var targetDocument interface{}
+	err = json.Unmarshal(body, &targetDocument)
+	if err != nil {
+		return err
+	}
+
+	body, err = json.Marshal(mergePatch(targetDocument, patchDocument))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(body, result)
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for key, value := range patchObject {
+		if value == nil {
+			delete(targetObject, key)
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], value)
+	}
+
+	return targetObject
+}
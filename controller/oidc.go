@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/router"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// OIDCAuthenticationMiddleware authenticates requests with a JWT in the "Authorization: Bearer" header, invalid or expired tokens are rejected with 401.
+// The verified subject is the caller identity of the request, it's recorded in the audit log and the history. If the groups of the token are mapped to Roles
+// their permissions are enforced by the AccessControlMiddleware, otherwise the RoleBindings of the subject apply.
+// Requests without JWT (e.g. with an APIKey) are passed through, as well as all routes without Permission (e.g. the HMAC secured GitHub webhook).
+func OIDCAuthenticationMiddleware(route router.Route, next router.Controller) router.Controller {
+	if route.Permission == "" {
+		return next
+	}
+
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		token := bearerToken(request)
+		if token == "" || strings.HasPrefix(token, model.APIKeyPrefix) {
+			return next(request)
+		}
+
+		identity, err := model.VerifyToken(token)
+		if err == model.ErrInvalidToken {
+			config.Logger.Log(err, map[string]string{"module": "controller/OIDCAuthenticationMiddleware", "operation": "verifyToken"}, 1)
+			response := types.UnauthorizedResponse
+			response.Headers = map[string]string{"WWW-Authenticate": "Bearer error=\"invalid_token\""}
+			return response, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+
+		return next(withAuthenticatedCaller(request, identity.Subject, identity.Grants))
+	}
+}
@@ -0,0 +1,33 @@
+package controller
+
+import (
+	"errors"
+	"strconv"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const maxPageLimit = 1000
+
+var invalidLimitResponse = events.APIGatewayProxyResponse{Body: "{ \"message\" : \"limit must be a number between 1 and " + strconv.Itoa(maxPageLimit) + "\" }", StatusCode: 400}
+
+var invalidCursorResponse = events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Invalid cursor\" }", StatusCode: 400}
+
+// readPagination reads the limit and cursor query parameters from the request, paginated is true if at least one of them is set.
+// Without a limit the page size is left to the Store.
+func readPagination(request events.APIGatewayProxyRequest) (limit int64, cursor string, paginated bool, err error) {
+	limitParameter, limitSet := request.QueryStringParameters["limit"]
+	cursor, cursorSet := request.QueryStringParameters["cursor"]
+	if !limitSet && !cursorSet {
+		return 0, "", false, nil
+	}
+
+	if limitSet {
+		limit, err = strconv.ParseInt(limitParameter, 10, 64)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			return 0, "", true, errors.New("Invalid limit " + limitParameter)
+		}
+	}
+
+	return limit, cursor, true, nil
+}
@@ -35,6 +35,11 @@ func PutGlobalRepositoryConfigController(request events.APIGatewayProxyRequest)
 		config.Logger.Log(err, map[string]string{"module": "controller/PutGlobalRepositoryConfigController", "operation": "unmarshal"}, 4)
 		return types.InvalidRequestBodyResponse, nil
 	}
+	err = validateSchedules(configuration.StartupSchedules, configuration.ShutdownSchedules)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PutGlobalRepositoryConfigController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
 
 	err = model.UpdateGlobalRepositoryConfiguration(&configuration, request.RequestContext.Stage)
 
@@ -50,3 +55,43 @@ func PutGlobalRepositoryConfigController(request events.APIGatewayProxyRequest)
 
 	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
 }
+
+// PatchGlobalRepositoryConfigController is the controller function for the PATCH /repositories/environments endpoint.
+// The request body containing a JSON merge patch (RFC 7396) gets applied to the stored global repository configuration, fields missing in the patch keep their values.
+func PatchGlobalRepositoryConfigController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if !isMergePatchRequest(request) {
+		return unsupportedMediaTypeResponse, nil
+	}
+
+	current := types.GeneralConfig{}
+	err := model.GetGlobalRepositoryConfiguration(&current, request.RequestContext.Stage)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	configuration := types.GeneralConfig{}
+	err = applyMergePatch(current, []byte(request.Body), &configuration)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchGlobalRepositoryConfigController", "operation": "applyMergePatch"}, 4)
+		return types.InvalidRequestBodyResponse, nil
+	}
+	err = validateSchedules(configuration.StartupSchedules, configuration.ShutdownSchedules)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchGlobalRepositoryConfigController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
+
+	err = model.UpdateGlobalRepositoryConfiguration(&configuration, request.RequestContext.Stage)
+
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(configuration)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchGlobalRepositoryConfigController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
@@ -2,7 +2,7 @@ package controller
 
 import (
 	"encoding/json"
-	"strings"
+	"errors"
 
 	"github.com/auto-staging/tower/config"
 	"github.com/auto-staging/tower/model"
@@ -11,10 +11,35 @@ import (
 )
 
 // GetAllRepositoriesController is the controller function for the GET /repositories endpoint.
+// If the limit or cursor query parameter is set, a single page gets returned as RepositoryList including the cursor for the next page.
 func GetAllRepositoriesController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllRepositoriesController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	if paginated {
+		list := types.RepositoryList{Repositories: []types.Repository{}}
+		list.NextCursor, err = model.GetRepositoriesPage(&list.Repositories, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+
+		body, err := json.Marshal(list)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "controller/GetAllRepositoriesController", "operation": "marshal"}, 0)
+			return types.InternalServerErrorResponse, nil
+		}
+
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	}
 
 	var obj []types.Repository
-	err := model.GetAllRepositories(&obj)
+	err = model.GetAllRepositories(&obj)
 	if err != nil {
 		return types.InternalServerErrorResponse, nil
 	}
@@ -40,11 +65,23 @@ func AddRepositoryController(request events.APIGatewayProxyRequest) (events.APIG
 		config.Logger.Log(err, map[string]string{"module": "controller/AddRepositoryController", "operation": "validateCodeBuildRoleARN"}, 1)
 		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"codeBuildRoleARN is not a valid IAM Role ARN\" }", StatusCode: 400}, nil
 	}
+	if !validatePullRequestActions(repo.PullRequestActions) {
+		config.Logger.Log(errors.New("Invalid pullRequestActions"), map[string]string{"module": "controller/AddRepositoryController", "operation": "validatePullRequestActions"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"pullRequestActions must only contain create, update or destroy\" }", StatusCode: 400}, nil
+	}
+	if _, err := compileBranchFilters(repo.Filters); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/AddRepositoryController", "operation": "compileBranchFilters"}, 1)
+		return invalidValueResponse(err), nil
+	}
+	if err := validateSchedules(repo.StartupSchedules, repo.ShutdownSchedules); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/AddRepositoryController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
 
 	err = model.AddRepository(&repo, request.RequestContext.Stage)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "ConditionalCheckFailedException") {
+		if model.IsConditionalCheckFailed(err) {
 			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unique constraint violation\" }", StatusCode: 400}, nil
 		}
 		return types.InternalServerErrorResponse, nil
@@ -78,12 +115,18 @@ func GetSingleRepositoryController(request events.APIGatewayProxyRequest) (event
 		return types.InternalServerErrorResponse, nil
 	}
 
-	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: map[string]string{"ETag": formatETag(obj.Version)}}, nil
 }
 
 // PutSingleRepositoryController is the controller function for the PUT /repositories/{name} endpoint.
-// The request body containing the information for the new Repository gets read from the APIGatewayProxyRequest struct
+// The request body containing the information for the new Repository gets read from the APIGatewayProxyRequest struct.
+// If the If-Match header is set, the Repository only gets updated if the ETag matches the stored version, otherwise 412 gets returned.
 func PutSingleRepositoryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	expectedVersion, ok := readIfMatch(request)
+	if !ok {
+		return types.PreconditionFailedResponse, nil
+	}
+
 	repository := types.Repository{}
 	err := json.Unmarshal([]byte(request.Body), &repository)
 	if err != nil {
@@ -94,11 +137,26 @@ func PutSingleRepositoryController(request events.APIGatewayProxyRequest) (event
 		config.Logger.Log(err, map[string]string{"module": "controller/PutSingleRepositoryController", "operation": "validateCodeBuildRoleARN"}, 1)
 		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"codeBuildRoleARN is not a valid IAM Role ARN\" }", StatusCode: 400}, nil
 	}
+	if !validatePullRequestActions(repository.PullRequestActions) {
+		config.Logger.Log(errors.New("Invalid pullRequestActions"), map[string]string{"module": "controller/PutSingleRepositoryController", "operation": "validatePullRequestActions"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"pullRequestActions must only contain create, update or destroy\" }", StatusCode: 400}, nil
+	}
+	if _, err := compileBranchFilters(repository.Filters); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PutSingleRepositoryController", "operation": "compileBranchFilters"}, 1)
+		return invalidValueResponse(err), nil
+	}
+	if err := validateSchedules(repository.StartupSchedules, repository.ShutdownSchedules); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PutSingleRepositoryController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
 
-	err = model.UpdateSingleRepository(&repository, request.PathParameters["name"])
+	err = model.UpdateSingleRepository(&repository, request.PathParameters["name"], expectedVersion)
 
 	if err != nil {
-		if strings.Contains(err.Error(), "ConditionalCheckFailedException") {
+		if err == model.ErrVersionMismatch {
+			return types.PreconditionFailedResponse, nil
+		}
+		if model.IsConditionalCheckFailed(err) {
 			return types.NotFoundErrorResponse, nil
 		}
 		return types.InternalServerErrorResponse, nil
@@ -110,7 +168,79 @@ func PutSingleRepositoryController(request events.APIGatewayProxyRequest) (event
 		return types.InternalServerErrorResponse, nil
 	}
 
-	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: map[string]string{"ETag": formatETag(repository.Version)}}, nil
+}
+
+// PatchSingleRepositoryController is the controller function for the PATCH /repositories/{name} endpoint.
+// The request body containing a JSON merge patch (RFC 7396) gets applied to the stored Repository, fields missing in the patch keep their values.
+// If the If-Match header is set, the Repository only gets updated if the ETag matches the stored version, otherwise 412 gets returned.
+func PatchSingleRepositoryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	expectedVersion, ok := readIfMatch(request)
+	if !ok {
+		return types.PreconditionFailedResponse, nil
+	}
+	if !isMergePatchRequest(request) {
+		return unsupportedMediaTypeResponse, nil
+	}
+
+	current := types.Repository{}
+	err := model.GetSingleRepository(&current, request.PathParameters["name"])
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+	if current.Repository == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+	if expectedVersion != model.AnyVersion && expectedVersion != current.Version {
+		return types.PreconditionFailedResponse, nil
+	}
+
+	repository := types.Repository{}
+	err = applyMergePatch(current, []byte(request.Body), &repository)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleRepositoryController", "operation": "applyMergePatch"}, 4)
+		return types.InvalidRequestBodyResponse, nil
+	}
+	if !validateIAMRoleARN(repository.CodeBuildRoleARN) {
+		config.Logger.Log(errors.New("Invalid codeBuildRoleARN"), map[string]string{"module": "controller/PatchSingleRepositoryController", "operation": "validateCodeBuildRoleARN"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"codeBuildRoleARN is not a valid IAM Role ARN\" }", StatusCode: 400}, nil
+	}
+	if !validatePullRequestActions(repository.PullRequestActions) {
+		config.Logger.Log(errors.New("Invalid pullRequestActions"), map[string]string{"module": "controller/PatchSingleRepositoryController", "operation": "validatePullRequestActions"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"pullRequestActions must only contain create, update or destroy\" }", StatusCode: 400}, nil
+	}
+	if _, err := compileBranchFilters(repository.Filters); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleRepositoryController", "operation": "compileBranchFilters"}, 1)
+		return invalidValueResponse(err), nil
+	}
+	if err := validateSchedules(repository.StartupSchedules, repository.ShutdownSchedules); err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleRepositoryController", "operation": "validateSchedules"}, 1)
+		return invalidValueResponse(err), nil
+	}
+
+	// The update is based on the version read above, so concurrent updates between reading and writing aren't overwritten
+	err = model.UpdateSingleRepository(&repository, request.PathParameters["name"], current.Version)
+
+	if err != nil {
+		if err == model.ErrVersionMismatch {
+			if expectedVersion != model.AnyVersion {
+				return types.PreconditionFailedResponse, nil
+			}
+			return concurrentModificationResponse, nil
+		}
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(repository)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/PatchSingleRepositoryController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200, Headers: map[string]string{"ETag": formatETag(repository.Version)}}, nil
 }
 
 // DeleteSingleRepositoryController is the controller function for the DELETE /repositories/{name} endpoint.
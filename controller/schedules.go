@@ -0,0 +1,67 @@
+package controller
+
+import (
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	defaultScheduleRuns = 5
+	maxScheduleRuns     = 100
+)
+
+var invalidCountResponse = events.APIGatewayProxyResponse{Body: "{ \"message\" : \"count must be a number between 1 and " + strconv.Itoa(maxScheduleRuns) + "\" }", StatusCode: 400}
+
+// GetEnvironmentSchedulesController is the controller function for the GET /repositories/{name}/environments/{branch}/schedules endpoint.
+// The "name" path parameter containing the Repository name and the "branch" path parameter containing the branch name gets read from the APIGatewayProxyRequest struct.
+// The response contains the next startup and shutdown times of the schedules of the Environment, the times are formatted in the time zone of their schedule (UTC if the schedule has none)
+// including the UTC offset. The "count" query parameter sets the number of times per type (default 5).
+func GetEnvironmentSchedulesController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	count := defaultScheduleRuns
+	if parameter, ok := request.QueryStringParameters["count"]; ok {
+		var err error
+		count, err = strconv.Atoi(parameter)
+		if err != nil || count < 1 || count > maxScheduleRuns {
+			config.Logger.Log(errors.New("Invalid count "+parameter), map[string]string{"module": "controller/GetEnvironmentSchedulesController", "operation": "readCount"}, 4)
+			return invalidCountResponse, nil
+		}
+	}
+
+	branch, err := url.PathUnescape(request.PathParameters["branch"])
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetEnvironmentSchedulesController", "operation": "pathUnescape"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	environment := types.Environment{}
+	err = model.GetSingleEnvironmentForRepository(&environment, request.PathParameters["name"], branch)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+	if environment.Repository == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	preview := types.SchedulePreview{}
+	err = model.GetEnvironmentSchedulePreview(&preview, environment, time.Now(), count)
+	if err != nil {
+		// Schedules stored before the validation existed can be invalid, they have to be fixed with an update of the Environment
+		return invalidValueResponse(err), nil
+	}
+
+	body, err := json.Marshal(preview)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetEnvironmentSchedulesController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
@@ -11,12 +11,52 @@ import (
 )
 
 // GetAllEnvironmentsStatusInformationController is the controller function for the GET /repositories/environments/status endpoint.
+// The result can be filtered by status, branchPrefix, branch (regular expression), createdAfter and createdBefore and sorted by creationDate or branch (sort and order query parameters).
+// If the limit or cursor query parameter is set, a single page gets returned as EnvironmentStatusList including the cursor for the next page.
+// The filters are applied to the fetched page, so a page can contain fewer items than the limit or even none while the cursor for the next page is still set.
 func GetAllEnvironmentsStatusInformationController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllEnvironmentsStatusInformationController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	query, err := readEnvironmentQuery(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllEnvironmentsStatusInformationController", "operation": "readEnvironmentQuery"}, 4)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"" + err.Error() + "\" }", StatusCode: 400}, nil
+	}
+
+	if paginated {
+		if query.sortBy != "" {
+			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"sort can't be combined with limit or cursor\" }", StatusCode: 400}, nil
+		}
+
+		list := types.EnvironmentStatusList{Environments: []types.EnvironmentStatus{}}
+		list.NextCursor, err = model.GetEnvironmentsStatusInformationPage(&list.Environments, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+		list.Environments = query.applyToStatus(list.Environments)
+
+		body, err := json.Marshal(list)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "controller/GetAllEnvironmentsStatusInformationController", "operation": "marshal"}, 0)
+			return types.InternalServerErrorResponse, nil
+		}
+
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+	}
+
 	var obj []types.EnvironmentStatus
-	err := model.GetAllEnvironmentsStatusInformation(&obj)
+	err = model.GetAllEnvironmentsStatusInformation(&obj)
 	if err != nil {
 		return types.InternalServerErrorResponse, nil
 	}
+	obj = query.applyToStatus(obj)
 
 	body, err := json.Marshal(obj)
 	if err != nil {
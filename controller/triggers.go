@@ -11,8 +11,14 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
+// alreadyInStatusResponse returns the 200 response of a trigger for an Environment which is already in the requested status.
+func alreadyInStatusResponse(status types.EnvironmentState) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Environment is already " + string(status) + "\" }", StatusCode: 200}
+}
+
 // TriggerEnvironemtStatusChangeController is the controller function for the POST /triggers/schedule endpoint.
-// The request body containing the desired status for the Environment gets read from the APIGatewayProxyRequest struct
+// The request body containing the desired status for the Environment gets read from the APIGatewayProxyRequest struct.
+// Starting a running or stopping a stopped Environment is answered with 200 OK without invoking the Scheduler, so repeated triggers (e.g. by overlapping schedules) don't fail.
 func TriggerEnvironemtStatusChangeController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	trigger := types.TriggerSchedulePost{}
 	err := json.Unmarshal([]byte(request.Body), &trigger)
@@ -33,24 +39,36 @@ func TriggerEnvironemtStatusChangeController(request events.APIGatewayProxyReque
 
 	switch trigger.Action {
 	case "start":
-		if status.Status != "running" && status.Status != "stopped" {
-			config.Logger.Log(errors.New("Can't start environment in status = "+status.Status), map[string]string{"module": "controller/TriggerEnvironemtStatusChangeController", "operation": "statusCheck"}, 0)
+		if status.Status.Reached(types.EnvironmentActionStart) {
+			return alreadyInStatusResponse(status.Status), nil
+		}
+		if !status.Status.Allows(types.EnvironmentActionStart) {
+			config.Logger.Log(errors.New("Can't start environment in status = "+string(status.Status)), map[string]string{"module": "controller/TriggerEnvironemtStatusChangeController", "operation": "statusCheck"}, 0)
 			return types.InvalidEnvironmentStatusResponse, nil
 		}
 
-		result, err := model.TriggerSchedulerLambdaForEnvironment(trigger.Repository, trigger.Branch, "start")
+		result, err := model.TriggerSchedulerLambdaForEnvironment(trigger.Repository, branch, "start", requestActor(request))
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
 		if err != nil {
 			return types.InternalServerErrorResponse, nil
 		}
 		return events.APIGatewayProxyResponse{Body: result, StatusCode: 200}, nil
 
 	case "stop":
-		if status.Status != "running" && status.Status != "stopped" {
-			config.Logger.Log(errors.New("Can't stop environment in status = "+status.Status), map[string]string{"module": "controller/TriggerEnvironemtStatusChangeController", "operation": "statusCheck"}, 0)
+		if status.Status.Reached(types.EnvironmentActionStop) {
+			return alreadyInStatusResponse(status.Status), nil
+		}
+		if !status.Status.Allows(types.EnvironmentActionStop) {
+			config.Logger.Log(errors.New("Can't stop environment in status = "+string(status.Status)), map[string]string{"module": "controller/TriggerEnvironemtStatusChangeController", "operation": "statusCheck"}, 0)
 			return types.InvalidEnvironmentStatusResponse, nil
 		}
 
-		result, err := model.TriggerSchedulerLambdaForEnvironment(trigger.Repository, trigger.Branch, "stop")
+		result, err := model.TriggerSchedulerLambdaForEnvironment(trigger.Repository, branch, "stop", requestActor(request))
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
 		if err != nil {
 			return types.InternalServerErrorResponse, nil
 		}
@@ -60,3 +78,23 @@ func TriggerEnvironemtStatusChangeController(request events.APIGatewayProxyReque
 
 	return types.InvalidRequestBodyResponse, nil
 }
+
+// TriggerTimeZoneRefreshController is the controller function for the POST /triggers/time-zones endpoint.
+// The schedules of all Environments whose time zones changed their UTC offset since the schedules were last sent get translated and sent to the Builder again,
+// the endpoint should be called at least hourly (e.g. by a CloudWatch Events rule) so the schedules follow daylight saving time changes.
+// The response contains the refreshed Environments.
+func TriggerTimeZoneRefreshController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	refreshed := []types.TimeZoneRefresh{}
+	err := model.RefreshScheduleTimeZones(&refreshed)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(refreshed)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/TriggerTimeZoneRefreshController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
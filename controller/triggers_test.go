@@ -0,0 +1,58 @@
+package controller
+
+import (
+	"testing"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestTriggerEnvironemtStatusChangeController(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         types.EnvironmentState
+		action         string
+		wantStatusCode int
+		wantInvoked    bool
+		wantStatus     types.EnvironmentState
+	}{
+		{"start stopped", types.EnvironmentStateStopped, "start", 200, true, types.EnvironmentStateStarting},
+		{"stop running", types.EnvironmentStateRunning, "stop", 200, true, types.EnvironmentStateStopping},
+		{"start running", types.EnvironmentStateRunning, "start", 200, false, types.EnvironmentStateRunning},
+		{"stop stopped", types.EnvironmentStateStopped, "stop", 200, false, types.EnvironmentStateStopped},
+		{"start updating", types.EnvironmentStateUpdating, "start", 400, false, types.EnvironmentStateUpdating},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := model.NewMemoryStore()
+			model.SetStore(store)
+			recorder := model.NewRecordingInvoker()
+			recorder.Responses[model.SchedulerComponent] = []byte(`"Scheduler invoked"`)
+			model.SetInvoker(recorder)
+			if err := store.AddEnvironment(&types.Environment{Repository: "triggers", Branch: "main", Status: test.status}); err != nil {
+				t.Fatalf("AddEnvironment returned %v", err)
+			}
+
+			response, err := TriggerEnvironemtStatusChangeController(events.APIGatewayProxyRequest{
+				Body: `{"repository": "triggers", "branch": "main", "action": "` + test.action + `"}`,
+			})
+			if err != nil {
+				t.Fatalf("TriggerEnvironemtStatusChangeController returned %v", err)
+			}
+			if response.StatusCode != test.wantStatusCode {
+				t.Errorf("status code %d, want %d (%s)", response.StatusCode, test.wantStatusCode, response.Body)
+			}
+			if invoked := len(recorder.Invocations) > 0; invoked != test.wantInvoked {
+				t.Errorf("Scheduler invoked %t, want %t", invoked, test.wantInvoked)
+			}
+
+			status := types.EnvironmentStatus{}
+			model.GetSingleEnvironmentStatusInformation(&status, "triggers", "main")
+			if status.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", status.Status, test.wantStatus)
+			}
+		})
+	}
+}
@@ -1,8 +1,44 @@
 package controller
 
-import "regexp"
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
 
 func validateIAMRoleARN(arn string) bool {
 	regex := regexp.MustCompile(`arn:aws:iam::\d{12}:role/?[a-zA-Z_0-9+=,.@\-_/]+`)
 	return regex.MatchString(arn)
 }
+
+// validatePullRequestActions returns true if all actions can be executed by pull request events, these are create, update and destroy.
+func validatePullRequestActions(actions []types.EnvironmentAction) bool {
+	for _, action := range actions {
+		if action != types.EnvironmentActionCreate && action != types.EnvironmentActionUpdate && action != types.EnvironmentActionDestroy {
+			return false
+		}
+	}
+	return true
+}
+
+// validateSchedules returns the error of the first schedule whose cron expression isn't valid in the AWS cron dialect,
+// whose time zone is unknown or which can't be translated into UTC for the offsets of its time zone.
+func validateSchedules(schedules ...[]types.TimeSchedule) error {
+	for _, list := range schedules {
+		for _, schedule := range list {
+			if err := model.ValidateTimeSchedule(schedule); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// invalidValueResponse returns the 400 response for the validation error, the message is escaped since it contains the invalid value.
+func invalidValueResponse(err error) events.APIGatewayProxyResponse {
+	message, _ := json.Marshal(err.Error())
+	return events.APIGatewayProxyResponse{Body: "{ \"message\" : " + string(message) + " }", StatusCode: 400}
+}
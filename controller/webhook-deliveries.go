@@ -0,0 +1,135 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// webhookDeliveryRequests rebuilds the request of a recorded WebhookDelivery by provider, together with the dispatcher executing it without verification.
+var webhookDeliveryRequests = map[string]struct {
+	request  func(delivery types.WebhookDelivery) events.APIGatewayProxyRequest
+	dispatch func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+}{
+	"github": {request: gitHubDeliveryRequest, dispatch: dispatchGitHubWebhook},
+}
+
+// GetAllWebhookDeliveriesController is the controller function for the GET /webhooks/deliveries endpoint, the payloads of the deliveries are omitted.
+// If the limit or cursor query parameter is set, a single page is returned as WebhookDeliveryList, otherwise all recorded WebhookDeliveries are returned.
+func GetAllWebhookDeliveriesController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	limit, cursor, paginated, err := readPagination(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllWebhookDeliveriesController", "operation": "readPagination"}, 4)
+		return invalidLimitResponse, nil
+	}
+
+	deliveries := []types.WebhookDelivery{}
+	nextCursor := ""
+	if paginated {
+		nextCursor, err = model.GetWebhookDeliveriesPage(&deliveries, limit, cursor)
+		if err == model.ErrInvalidCursor {
+			return invalidCursorResponse, nil
+		}
+	} else {
+		err = model.GetAllWebhookDeliveries(&deliveries)
+	}
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	for i := range deliveries {
+		deliveries[i].Payload = ""
+	}
+
+	var obj interface{} = deliveries
+	if paginated {
+		obj = types.WebhookDeliveryList{Deliveries: deliveries, NextCursor: nextCursor}
+	}
+
+	body, err := json.Marshal(obj)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetAllWebhookDeliveriesController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// GetSingleWebhookDeliveryController is the controller function for the GET /webhooks/deliveries/{id} endpoint.
+// The "id" path parameter containing the delivery id of the provider gets read from the APIGatewayProxyRequest struct, the response includes the payload.
+func GetSingleWebhookDeliveryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	delivery := types.WebhookDelivery{}
+	err := model.GetSingleWebhookDelivery(&delivery, request.PathParameters["id"])
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	if delivery.ID == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/GetSingleWebhookDeliveryController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// ReplayWebhookDeliveryController is the controller function for the POST /webhooks/deliveries/{id}/replay endpoint.
+// The stored payload of the delivery gets processed again like a new request of the provider, but without signature verification since it was verified when it was received.
+// Only failed deliveries and deliveries processing longer than the processing timeout (crashed) can be replayed, the delivery is claimed before the replay,
+// so it isn't processed concurrently by another replay or a redelivery. The response contains the delivery with the result of the replay.
+func ReplayWebhookDeliveryController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	delivery := types.WebhookDelivery{}
+	err := model.GetSingleWebhookDelivery(&delivery, request.PathParameters["id"])
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	if delivery.ID == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	replay, ok := webhookDeliveryRequests[delivery.Provider]
+	if !ok {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Deliveries of " + delivery.Provider + " can't be replayed\" }", StatusCode: 400}, nil
+	}
+
+	if delivery.Payload == "" {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"The payload of the delivery wasn't stored\" }", StatusCode: 400}, nil
+	}
+
+	err = model.ClaimWebhookDeliveryReplay(&delivery)
+	if err == model.ErrDuplicateWebhookDelivery {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Only failed deliveries can be replayed\" }", StatusCode: 400}, nil
+	}
+	if err == model.ErrWebhookDeliveryInProgress {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Delivery is already being processed\" }", StatusCode: 409}, nil
+	}
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	response, err := replay.dispatch(replay.request(delivery))
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	err = model.CompleteWebhookDeliveryReplay(&delivery, response.StatusCode, response.Body)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(delivery)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/ReplayWebhookDeliveryController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
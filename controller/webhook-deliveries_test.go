@@ -0,0 +1,84 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestReplayWebhookDeliveryController(t *testing.T) {
+	tests := []struct {
+		name           string
+		outcome        types.WebhookDeliveryOutcome
+		received       time.Duration
+		wantStatusCode int
+		wantReplays    int
+	}{
+		{"failed", types.WebhookDeliveryFailed, time.Minute, 200, 1},
+		{"crashed", types.WebhookDeliveryProcessing, model.WebhookDeliveryProcessingTimeout + time.Minute, 200, 1},
+		{"processing", types.WebhookDeliveryProcessing, time.Minute, 409, 0},
+		{"succeeded", types.WebhookDeliverySucceeded, time.Minute, 400, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store := model.NewMemoryStore()
+			model.SetStore(store)
+			err := store.AddWebhookDelivery(&types.WebhookDelivery{
+				ID:       "delivery",
+				Provider: "github",
+				Event:    "ping",
+				Time:     time.Now().UTC().Add(-test.received).Format("2006-01-02T15:04:05.000000000Z07:00"),
+				Outcome:  test.outcome,
+				Payload:  "{}",
+			})
+			if err != nil {
+				t.Fatalf("AddWebhookDelivery returned %v", err)
+			}
+
+			response, err := ReplayWebhookDeliveryController(events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "delivery"}})
+			if err != nil {
+				t.Fatalf("ReplayWebhookDeliveryController returned %v", err)
+			}
+			if response.StatusCode != test.wantStatusCode {
+				t.Errorf("status code %d, want %d (%s)", response.StatusCode, test.wantStatusCode, response.Body)
+			}
+
+			stored := types.WebhookDelivery{}
+			model.GetSingleWebhookDelivery(&stored, "delivery")
+			if stored.Replays != test.wantReplays {
+				t.Errorf("%d replays, want %d", stored.Replays, test.wantReplays)
+			}
+			if test.wantReplays > 0 {
+				delivery := types.WebhookDelivery{}
+				json.Unmarshal([]byte(response.Body), &delivery)
+				if delivery.Outcome != types.WebhookDeliverySucceeded || stored.Outcome != types.WebhookDeliverySucceeded {
+					t.Errorf("outcome %q (stored %q), want %q", delivery.Outcome, stored.Outcome, types.WebhookDeliverySucceeded)
+				}
+			}
+		})
+	}
+}
+
+func TestReplayWebhookDeliveryClaim(t *testing.T) {
+	store := model.NewMemoryStore()
+	model.SetStore(store)
+	failed := types.WebhookDelivery{ID: "claimed", Provider: "github", Event: "ping", Time: "2026-10-18T10:00:00.000000000Z", Outcome: types.WebhookDeliveryFailed, Payload: "{}"}
+	if err := store.AddWebhookDelivery(&failed); err != nil {
+		t.Fatalf("AddWebhookDelivery returned %v", err)
+	}
+
+	first := failed
+	if err := model.ClaimWebhookDeliveryReplay(&first); err != nil {
+		t.Fatalf("first claim returned %v", err)
+	}
+	// A concurrent replay read the delivery before the first claim was stored
+	second := failed
+	if err := model.ClaimWebhookDeliveryReplay(&second); err != model.ErrWebhookDeliveryInProgress {
+		t.Errorf("second claim returned %v, want %v", err, model.ErrWebhookDeliveryInProgress)
+	}
+}
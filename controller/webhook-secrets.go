@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"encoding/json"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// PutRepositoryWebhookSecretController is the controller function for the PUT /repositories/{name}/webhook-secret endpoint.
+// The secret gets read from the request body, webhooks of the Repository are verified with it instead of the global secrets.
+// The replaced secret stays valid during the rotation window, the secret is never returned.
+func PutRepositoryWebhookSecretController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	put := types.WebhookSecretPut{}
+	err := json.Unmarshal([]byte(request.Body), &put)
+	if err != nil || put.Secret == "" {
+		return types.InvalidRequestBodyResponse, nil
+	}
+
+	err = model.SetRepositoryWebhookSecret(request.PathParameters["name"], put.Secret)
+	if err != nil {
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
+}
+
+// DeleteRepositoryWebhookSecretController is the controller function for the DELETE /repositories/{name}/webhook-secret endpoint.
+// The secrets of the Repository get removed, so its webhooks are verified with the global secrets again.
+func DeleteRepositoryWebhookSecretController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	err := model.DeleteRepositoryWebhookSecret(request.PathParameters["name"])
+	if err != nil {
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
+}
@@ -0,0 +1,65 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// BitbucketWebhookController is the controller function for the POST /webhooks/bitbucket endpoint.
+// Bitbucket Cloud sends the repo:push event after references were pushed, every created branch adds an Environment and every deleted (closed) branch destroys it.
+// The Bitbucket Webhook endpoint is secured through HMAC-SHA256 in the X-Hub-Signature header.
+func BitbucketWebhookController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return webhookController(bitbucketProvider{}, "bitbucket", request)
+}
+
+// bitbucketProvider is the webhookProvider for the repo:push events of Bitbucket Cloud.
+type bitbucketProvider struct{}
+
+func (bitbucketProvider) repository(request events.APIGatewayProxyRequest) string {
+	webhook := types.BitbucketWebhook{}
+	json.Unmarshal([]byte(request.Body), &webhook)
+	return webhook.Repository.Name
+}
+
+func (bitbucketProvider) verify(request events.APIGatewayProxyRequest, secrets []string) error {
+	signature := getHeader(request, "X-Hub-Signature")
+	if !strings.HasPrefix(signature, "sha256=") || !validHMAC(sha256.New, request.Body, secrets, strings.TrimPrefix(signature, "sha256=")) {
+		return errors.New("HMAC validation failed")
+	}
+	return nil
+}
+
+func (bitbucketProvider) parse(request events.APIGatewayProxyRequest) ([]webhookEvent, error) {
+	if getHeader(request, "X-Event-Key") != "repo:push" {
+		return nil, errUnsupportedWebhookEvent
+	}
+
+	webhook := types.BitbucketWebhook{}
+	err := json.Unmarshal([]byte(request.Body), &webhook)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := []webhookEvent{}
+	for _, change := range webhook.Push.Changes {
+		event := webhookEvent{Repository: webhook.Repository.Name, Sender: webhook.Actor.Nickname, Actor: webhookActor("bitbucket", webhook.Actor.Nickname)}
+		switch {
+		case change.Old == nil && change.New != nil && change.New.Type == "branch":
+			event.Action = types.EnvironmentActionCreate
+			event.Branch = change.New.Name
+		case change.New == nil && change.Old != nil && change.Old.Type == "branch":
+			event.Action = types.EnvironmentActionDestroy
+			event.Branch = change.Old.Name
+		default:
+			// Pushes to existing branches and tags don't create or delete an Environment
+			continue
+		}
+		parsed = append(parsed, event)
+	}
+	return parsed, nil
+}
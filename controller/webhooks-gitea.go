@@ -0,0 +1,64 @@
+package controller
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GiteaWebhookController is the controller function for the POST /webhooks/gitea endpoint, it handles the webhooks of Gitea and Forgejo.
+// Gitea sends the create event after a new Git branch was created and the delete event after a Git branch was deleted.
+// The Gitea Webhook endpoint is secured through HMAC-SHA256 in the X-Gitea-Signature (or X-Forgejo-Signature) header.
+func GiteaWebhookController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return webhookController(giteaProvider{}, "gitea", request)
+}
+
+// giteaProvider is the webhookProvider for the create and delete events of Gitea and Forgejo.
+type giteaProvider struct{}
+
+// giteaHeader returns the Gitea header with the name, Forgejo sends its own X-Forgejo headers next to the X-Gitea headers (older versions only the latter).
+func giteaHeader(request events.APIGatewayProxyRequest, name string) string {
+	if value := getHeader(request, "X-Gitea-"+name); value != "" {
+		return value
+	}
+	return getHeader(request, "X-Forgejo-"+name)
+}
+
+func (giteaProvider) repository(request events.APIGatewayProxyRequest) string {
+	webhook := types.GiteaWebhook{}
+	json.Unmarshal([]byte(request.Body), &webhook)
+	return webhook.Repository.Name
+}
+
+func (giteaProvider) verify(request events.APIGatewayProxyRequest, secrets []string) error {
+	if !validHMAC(sha256.New, request.Body, secrets, giteaHeader(request, "Signature")) {
+		return errors.New("HMAC validation failed")
+	}
+	return nil
+}
+
+func (giteaProvider) parse(request events.APIGatewayProxyRequest) ([]webhookEvent, error) {
+	action := types.EnvironmentAction("")
+	switch giteaHeader(request, "Event") {
+	case "create":
+		action = types.EnvironmentActionCreate
+	case "delete":
+		action = types.EnvironmentActionDestroy
+	default:
+		return nil, errUnsupportedWebhookEvent
+	}
+
+	webhook := types.GiteaWebhook{}
+	err := json.Unmarshal([]byte(request.Body), &webhook)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.RefType != "branch" {
+		return nil, errors.New("Invalid ref_type " + webhook.RefType)
+	}
+
+	return []webhookEvent{{Action: action, Repository: webhook.Repository.Name, Branch: webhook.Ref, Sender: webhook.Sender.Login, Actor: webhookActor("gitea", webhook.Sender.Login)}}, nil
+}
@@ -1,13 +1,12 @@
 package controller
 
 import (
-	"crypto/hmac"
 	"crypto/sha1"
-	"encoding/hex"
+	"crypto/sha256"
 	"encoding/json"
 	"errors"
+	"net/http"
 	"os"
-	"regexp"
 	"strings"
 
 	"github.com/auto-staging/tower/config"
@@ -16,26 +15,157 @@ import (
 	"github.com/aws/aws-lambda-go/events"
 )
 
-// GitHubWebhookPingController is the controller function for the POST /webhooks/github endpoint with X-GitHub-Event = ping.
-// GitHub sends the ping event after the Webhook was successfully added to GitHub.
-// The GitHub Webhook endpoint is secured through HMAC.
-func GitHubWebhookPingController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+// GitHubWebhookController is the controller function for the POST /webhooks/github endpoint, it verifies the request and dispatches it
+// to the matching event controller by the X-GitHub-Event header.
+// Verified requests are recorded as WebhookDelivery by the X-GitHub-Delivery header together with the response of the event controller.
+// A redelivery of a delivery which already succeeded isn't processed again and gets the stored status code, failed and crashed deliveries are processed again.
+// The GitHub Webhook endpoint is secured through HMAC-SHA256 (HMAC-SHA1 if allowed), only verified requests are recorded in the audit log.
+func GitHubWebhookController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	if response, ok := verifyGitHubWebhook(request); !ok {
+		return response, nil
+	}
+
+	target, actor := gitHubAuditTarget(request)
+	return auditCall(http.MethodPost, "/webhooks/github", target, actor, receiveGitHubWebhook, request)
+}
+
+// receiveGitHubWebhook records the verified request as WebhookDelivery and dispatches it, unless it's a duplicate.
+func receiveGitHubWebhook(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	id := getHeader(request, "X-GitHub-Delivery")
+	if id == "" {
+		return dispatchGitHubWebhook(request)
+	}
+
+	delivery := types.WebhookDelivery{
+		ID:         id,
+		Provider:   "github",
+		Event:      getHeader(request, "X-GitHub-Event"),
+		Repository: gitHubProvider{}.repository(request),
+		Payload:    request.Body,
+	}
+	err := model.RecordWebhookDelivery(&delivery)
+	if err == model.ErrDuplicateWebhookDelivery {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Duplicate delivery\" }", StatusCode: delivery.StatusCode}, nil
+	}
+	if err == model.ErrWebhookDeliveryInProgress {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Delivery is already being processed\" }", StatusCode: 409}, nil
+	}
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	response, err := dispatchGitHubWebhook(request)
+	model.CompleteWebhookDelivery(&delivery, response.StatusCode, response.Body)
+	return response, err
+}
+
+// dispatchGitHubWebhook executes the already verified request with the event controller matching the X-GitHub-Event header.
+func dispatchGitHubWebhook(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	switch getHeader(request, "X-GitHub-Event") {
+	case "ping":
+		return gitHubPingController(request)
+	case "create", "delete":
+		return executeWebhook(gitHubProvider{}, request)
+	case "push":
+		return gitHubPushController(request)
+	case "pull_request":
+		return gitHubPullRequestController(request)
+	}
+
+	return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unsupported GitHub event\" }", StatusCode: 400}, nil
+}
+
+// ignoredWebhookResponse returns the 200 response of a GitHub event which is skipped on purpose, the reason is part of the message.
+func ignoredWebhookResponse(reason string) events.APIGatewayProxyResponse {
+	return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Event is ignored: " + reason + "\" }", StatusCode: 200}
+}
+
+// gitHubDeliveryRequest returns the request of the recorded GitHub WebhookDelivery, it isn't signed so it must be dispatched without verification.
+func gitHubDeliveryRequest(delivery types.WebhookDelivery) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Headers: map[string]string{
+			"X-GitHub-Event":    delivery.Event,
+			"X-GitHub-Delivery": delivery.ID,
+		},
+		Body: delivery.Payload,
+	}
+}
+
+// verifyGitHubWebhook checks the signature of the request with the webhook secrets of the repository, if the check fails the error response is returned.
+func verifyGitHubWebhook(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, bool) {
+	secrets, err := model.GetWebhookSecrets(gitHubProvider{}.repository(request))
+	if err != nil {
+		return types.InternalServerErrorResponse, false
+	}
+
+	err = gitHubProvider{}.verify(request, secrets)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/verifyGitHubWebhook", "operation": "verify"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"" + err.Error() + "\" }", StatusCode: 400}, false
+	}
+	return events.APIGatewayProxyResponse{}, true
+}
+
+// gitHubPingController executes the ping event, GitHub sends it after the Webhook was successfully added to GitHub.
+func gitHubPingController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return events.APIGatewayProxyResponse{Body: "{\"message\": \"Pong\"}", StatusCode: 200}, nil
 }
 
-// GitHubWebhookCreateController is the controller function for the POST /webhooks/github endpoint with X-GitHub-Event = create.
-// GitHub sends the create event after a new Git branch was created.
-// The GitHub Webhook endpoint is secured through HMAC.
-func GitHubWebhookCreateController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	if !verifyHMAC(request.Body, request.Headers["X-Hub-Signature"]) {
-		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"HMAC validation failed\" }", StatusCode: 400}, nil
+// gitHubProvider is the webhookProvider for the create and delete events of GitHub, GitHub sends them after a Git branch was created or deleted.
+type gitHubProvider struct{}
+
+func (gitHubProvider) repository(request events.APIGatewayProxyRequest) string {
+	webhook := types.GitHubWebhook{}
+	json.Unmarshal([]byte(request.Body), &webhook)
+	return webhook.Repository.Name
+}
+
+func (gitHubProvider) verify(request events.APIGatewayProxyRequest, secrets []string) error {
+	if !validGitHubSignature(request, secrets) {
+		return errors.New("HMAC validation failed")
 	}
+	return nil
+}
 
+func (gitHubProvider) parse(request events.APIGatewayProxyRequest) ([]webhookEvent, error) {
 	webhook := types.GitHubWebhook{}
 	err := json.Unmarshal([]byte(request.Body), &webhook)
-	if err != nil || webhook.RefType != "branch" {
+	if err != nil {
+		return nil, err
+	}
+	if webhook.RefType != "branch" {
+		return nil, errors.New("Invalid ref_type " + webhook.RefType)
+	}
+
+	event := webhookEvent{Repository: webhook.Repository.Name, Branch: webhook.Ref, Sender: webhook.Sender.Login, Actor: gitHubActor(webhook.Sender.Login)}
+	switch getHeader(request, "X-GitHub-Event") {
+	case "create":
+		event.Action = types.EnvironmentActionCreate
+	case "delete":
+		event.Action = types.EnvironmentActionDestroy
+	default:
+		return nil, errUnsupportedWebhookEvent
+	}
+	return []webhookEvent{event}, nil
+}
+
+// gitHubPushController executes the already verified push event.
+// GitHub sends the push event after commits were pushed to a Git branch, the Environment of the branch gets updated to the pushed commit.
+// Pushes to unregistered repositories, to branches without Environment, to branches not matching the Filters of the repository and pushes deleting the branch are ignored.
+// Environments which are stopped or in a transitional state are skipped, so a push never interrupts a running operation (e.g. the push GitHub sends with a new branch
+// while its Environment is initiating). Ignored and skipped pushes are answered with 200 OK and the reason, so GitHub doesn't show the delivery as failed.
+func gitHubPushController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	webhook := types.GitHubWebhook{}
+	err := json.Unmarshal([]byte(request.Body), &webhook)
+	if err != nil || !strings.HasPrefix(webhook.Ref, "refs/heads/") {
 		return types.InvalidRequestBodyResponse, nil
 	}
+	branch := strings.TrimPrefix(webhook.Ref, "refs/heads/")
+
+	if webhook.Deleted {
+		// Deleted branches are handled by the delete event
+		return ignoredWebhookResponse("branch was deleted"), nil
+	}
 
 	repository := types.Repository{}
 	err = model.GetSingleRepository(&repository, webhook.Repository.Name)
@@ -44,103 +174,208 @@ func GitHubWebhookCreateController(request events.APIGatewayProxyRequest) (event
 	}
 
 	if repository.Repository == "" {
-		return types.NotFoundErrorResponse, nil
+		return ignoredWebhookResponse("repository isn't registered"), nil
 	}
 
 	if !repository.Webhook {
 		return types.InvalidWebhookIsDeactivatedResponse, nil
 	}
 
-	hit := false
-	for _, filter := range repository.Filters {
-		match, err := regexp.MatchString(filter, webhook.Ref)
-		if err != nil {
-			config.Logger.Log(err, map[string]string{"module": "controller/GitHubWebhookCreateController", "operation": "regexpMatchstring"}, 0)
-			return types.InternalServerErrorResponse, nil
-		}
-		if match {
-			hit = true
-			break
-		}
+	hit, err := matchesFilters(repository.Filters, branch, webhook.Sender.Login)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/gitHubPushController", "operation": "matchesFilters"}, 0)
+		return types.InternalServerErrorResponse, nil
 	}
 
 	if !hit {
-		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"No filter match\" }", StatusCode: 400}, nil
+		return ignoredWebhookResponse("no filter match"), nil
+	}
+
+	status := types.EnvironmentStatus{}
+	err = model.GetSingleEnvironmentStatusInformation(&status, repository.Repository, branch)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+	if status.Repository == "" {
+		return ignoredWebhookResponse("branch has no environment"), nil
+	}
+	if !status.Status.Allows(types.EnvironmentActionUpdate) {
+		config.Logger.Log(errors.New("Skipping push to environment in status = "+string(status.Status)), map[string]string{"module": "controller/gitHubPushController", "operation": "statusCheck"}, 4)
+		return ignoredWebhookResponse("environment in status " + string(status.Status)), nil
 	}
 
-	result, err := model.AddEnvironmentForRepository(types.EnvironmentPost{Branch: webhook.Ref}, repository.Repository)
+	result, err := model.DeployEnvironmentCommit(repository.Repository, branch, webhook.After, gitHubActor(webhook.Sender.Login))
 	if err != nil {
-		if strings.Contains(err.Error(), "ConditionalCheckFailedException") {
-			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unique constraint violation\" }", StatusCode: 400}, nil
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
 		}
 		return types.InternalServerErrorResponse, nil
 	}
 
 	body, err := json.Marshal(result)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "controller/GitHubWebhookCreateController", "operation": "marshal"}, 0)
+		config.Logger.Log(err, map[string]string{"module": "controller/gitHubPushController", "operation": "marshal"}, 0)
 		return types.InternalServerErrorResponse, nil
 	}
 
-	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 201}, nil
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
 }
 
-// GitHubWebhookDeleteController is the controller function for the POST /webhooks/github endpoint with X-GitHub-Event = delete.
-// GitHub sends the delete event after a Git branch was deleted.
-// The GitHub Webhook endpoint is secured through HMAC.
-func GitHubWebhookDeleteController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-	if !verifyHMAC(request.Body, request.Headers["X-Hub-Signature"]) {
-		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"HMAC validation failed\" }", StatusCode: 400}, nil
-	}
+// pullRequestActions maps the actions of the GitHub pull_request event to the actions of the Environment lifecycle.
+var pullRequestActions = map[string]types.EnvironmentAction{
+	"opened":      types.EnvironmentActionCreate,
+	"reopened":    types.EnvironmentActionCreate,
+	"closed":      types.EnvironmentActionDestroy,
+	"synchronize": types.EnvironmentActionUpdate,
+}
 
+// gitHubPullRequestController executes the already verified pull_request event.
+// GitHub sends the pull_request event after a pull request was opened, reopened, closed or new commits were pushed to its head branch (synchronize).
+// Opened and reopened pull requests create an Environment for the head branch storing the number and the author of the pull request,
+// if the head branch already has an Environment (e.g. created by the create event) the number and the author are added to it.
+// closed pull requests destroy it and synchronized pull requests update it to the head commit. Only the actions listed in the pullRequestActions
+// of the repository are executed, all other pull request actions and pull requests from forks are ignored.
+// Like pushes, pull requests of unregistered repositories, of branches not matching the Filters or without Environment and of Environments whose status doesn't
+// allow the action are answered with 200 OK and the reason.
+func gitHubPullRequestController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	webhook := types.GitHubWebhook{}
 	err := json.Unmarshal([]byte(request.Body), &webhook)
-	if err != nil || webhook.RefType != "branch" {
+	if err != nil || webhook.PullRequest.Head.Ref == "" {
 		return types.InvalidRequestBodyResponse, nil
 	}
+	branch := webhook.PullRequest.Head.Ref
 
 	repository := types.Repository{}
 	err = model.GetSingleRepository(&repository, webhook.Repository.Name)
 	if err != nil {
 		return types.InternalServerErrorResponse, nil
 	}
+
+	if repository.Repository == "" {
+		return ignoredWebhookResponse("repository isn't registered"), nil
+	}
+
 	if !repository.Webhook {
 		return types.InvalidWebhookIsDeactivatedResponse, nil
 	}
 
-	status := types.EnvironmentStatus{}
-	err = model.GetSingleEnvironmentStatusInformation(&status, webhook.Repository.Name, webhook.Ref)
+	action, ok := pullRequestActions[webhook.Action]
+	enabled := false
+	for _, enabledAction := range repository.PullRequestActions {
+		if enabledAction == action {
+			enabled = true
+		}
+	}
+	if !ok || !enabled {
+		return ignoredWebhookResponse("pull request action " + webhook.Action), nil
+	}
+
+	if webhook.PullRequest.Head.Repo.FullName != webhook.Repository.FullName {
+		return ignoredWebhookResponse("pull request from a fork"), nil
+	}
+
+	hit, err := matchesFilters(repository.Filters, branch, webhook.Sender.Login)
 	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/gitHubPullRequestController", "operation": "matchesFilters"}, 0)
 		return types.InternalServerErrorResponse, nil
 	}
-	if status.Status != "running" && status.Status != "stopped" && status.Status != "initiating failed" && status.Status != "destroying failed" {
-		config.Logger.Log(errors.New("Can't delete environment in status = "+status.Status), map[string]string{"module": "controller/GitHubWebhookDeleteController", "operation": "statusCheck"}, 0)
-		return types.InvalidEnvironmentStatusResponse, nil
+
+	if !hit {
+		return ignoredWebhookResponse("no filter match"), nil
 	}
 
-	err = model.DeleteSingleEnvironment(webhook.Repository.Name, webhook.Ref)
+	actor := gitHubActor(webhook.Sender.Login)
+	if action == types.EnvironmentActionCreate {
+		result, err := model.AddEnvironmentForRepository(types.EnvironmentPost{
+			Branch:            branch,
+			PullRequest:       webhook.PullRequest.Number,
+			PullRequestAuthor: webhook.PullRequest.User.Login,
+		}, repository.Repository, actor)
+		statusCode := 201
+		if model.IsConditionalCheckFailed(err) {
+			// The Environment was already created for the branch (e.g. by the create event), it's attached to the pull request
+			result, err = model.SetEnvironmentPullRequest(repository.Repository, branch, webhook.PullRequest.Number, webhook.PullRequest.User.Login)
+			statusCode = 200
+		}
+		if err != nil {
+			if err == model.ErrVersionMismatch {
+				return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Environment changed concurrently\" }", StatusCode: 409}, nil
+			}
+			if model.IsConditionalCheckFailed(err) {
+				return types.NotFoundErrorResponse, nil
+			}
+			return types.InternalServerErrorResponse, nil
+		}
+
+		body, err := json.Marshal(result)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "controller/gitHubPullRequestController", "operation": "marshal"}, 0)
+			return types.InternalServerErrorResponse, nil
+		}
+
+		return events.APIGatewayProxyResponse{Body: string(body), StatusCode: statusCode}, nil
+	}
+
+	status := types.EnvironmentStatus{}
+	err = model.GetSingleEnvironmentStatusInformation(&status, repository.Repository, branch)
 	if err != nil {
 		return types.InternalServerErrorResponse, nil
 	}
+	if status.Repository == "" {
+		return ignoredWebhookResponse("branch has no environment"), nil
+	}
+	if !status.Status.Allows(action) {
+		config.Logger.Log(errors.New("Can't "+string(action)+" environment in status = "+string(status.Status)), map[string]string{"module": "controller/gitHubPullRequestController", "operation": "statusCheck"}, 4)
+		return ignoredWebhookResponse("environment in status " + string(status.Status)), nil
+	}
 
-	return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
-}
+	if action == types.EnvironmentActionDestroy {
+		err = model.DeleteSingleEnvironment(repository.Repository, branch, actor)
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
+		if err != nil {
+			return types.InternalServerErrorResponse, nil
+		}
+
+		return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
+	}
 
-func verifyHMAC(body string, githubHash string) bool {
-	messageMAC := githubHash[5:] // first 5 chars are sha1=
-	messageMACBuf, err := hex.DecodeString(messageMAC)
+	result, err := model.DeployEnvironmentCommit(repository.Repository, branch, webhook.PullRequest.Head.SHA, actor)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "controller/verifyHMAC", "operation": "deocdeString"}, 0)
-		return false
+		if err == model.ErrStatusConflict {
+			return types.EnvironmentStatusConflictResponse, nil
+		}
+		if model.IsConditionalCheckFailed(err) {
+			return types.NotFoundErrorResponse, nil
+		}
+		return types.InternalServerErrorResponse, nil
 	}
 
-	mac := hmac.New(sha1.New, []byte(os.Getenv("WEBHOOK_SECRET_TOKEN")))
-	_, err = mac.Write([]byte(body))
+	body, err := json.Marshal(result)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "controller/verifyHMAC", "operation": "deocdeString"}, 0)
-		return false
+		config.Logger.Log(err, map[string]string{"module": "controller/gitHubPullRequestController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
 	}
-	expectedMAC := mac.Sum(nil)
 
-	return hmac.Equal(messageMACBuf, expectedMAC)
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// validGitHubSignature returns true if the request is signed with one of the secrets.
+// The HMAC-SHA256 signature of the X-Hub-Signature-256 header is preferred, the legacy HMAC-SHA1 signature of the X-Hub-Signature header
+// is only accepted if WEBHOOK_ALLOW_SHA1 is true and the request has no SHA256 signature.
+func validGitHubSignature(request events.APIGatewayProxyRequest, secrets []string) bool {
+	if signature := getHeader(request, "X-Hub-Signature-256"); signature != "" {
+		return strings.HasPrefix(signature, "sha256=") && validHMAC(sha256.New, request.Body, secrets, strings.TrimPrefix(signature, "sha256="))
+	}
+
+	if os.Getenv("WEBHOOK_ALLOW_SHA1") != "true" {
+		config.Logger.Log(errors.New("Missing X-Hub-Signature-256 header"), map[string]string{"module": "controller/validGitHubSignature", "operation": "getHeader"}, 1)
+		return false
+	}
+	signature := getHeader(request, "X-Hub-Signature")
+	return strings.HasPrefix(signature, "sha1=") && validHMAC(sha1.New, request.Body, secrets, strings.TrimPrefix(signature, "sha1="))
 }
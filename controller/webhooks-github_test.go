@@ -0,0 +1,60 @@
+package controller
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestGitHubPullRequestForCreatedBranch(t *testing.T) {
+	store := model.NewMemoryStore()
+	model.SetStore(store)
+	model.SetInvoker(model.NewRecordingInvoker())
+	repository := types.Repository{
+		Repository:         "pulls",
+		Webhook:            true,
+		Filters:            []string{"feature/*"},
+		PullRequestActions: []types.EnvironmentAction{types.EnvironmentActionCreate},
+	}
+	if err := store.AddRepository(&repository); err != nil {
+		t.Fatalf("AddRepository returned %v", err)
+	}
+
+	response, err := dispatchGitHubWebhook(events.APIGatewayProxyRequest{
+		Headers: map[string]string{"X-GitHub-Event": "create"},
+		Body:    `{"ref": "feature/login", "ref_type": "branch", "repository": {"name": "pulls", "full_name": "octo/pulls"}, "sender": {"login": "octocat"}}`,
+	})
+	if err != nil || response.StatusCode != 201 {
+		t.Fatalf("create event returned %d, %v (%s)", response.StatusCode, err, response.Body)
+	}
+
+	response, err = dispatchGitHubWebhook(events.APIGatewayProxyRequest{
+		Headers: map[string]string{"X-GitHub-Event": "pull_request"},
+		Body: `{"action": "opened", "pull_request": {"number": 7, "user": {"login": "hubot"}, "head": {"ref": "feature/login", "repo": {"full_name": "octo/pulls"}}},
+			"repository": {"name": "pulls", "full_name": "octo/pulls"}, "sender": {"login": "hubot"}}`,
+	})
+	if err != nil {
+		t.Fatalf("pull_request event returned %v", err)
+	}
+	if response.StatusCode != 200 {
+		t.Fatalf("status code %d, want 200 (%s)", response.StatusCode, response.Body)
+	}
+
+	environment := types.Environment{}
+	if err := json.Unmarshal([]byte(response.Body), &environment); err != nil {
+		t.Fatalf("Unmarshal returned %v", err)
+	}
+	stored := types.Environment{}
+	model.GetSingleEnvironmentForRepository(&stored, "pulls", "feature/login")
+	for _, got := range []types.Environment{environment, stored} {
+		if got.PullRequest != 7 || got.PullRequestAuthor != "hubot" {
+			t.Errorf("pull request %d of %q, want 7 of hubot", got.PullRequest, got.PullRequestAuthor)
+		}
+	}
+	if stored.Version != 2 {
+		t.Errorf("version %d, want 2", stored.Version)
+	}
+}
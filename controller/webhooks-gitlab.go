@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GitLabWebhookController is the controller function for the POST /webhooks/gitlab endpoint.
+// GitLab sends the Push Hook event after commits were pushed, a push creating a branch adds the Environment and a push deleting a branch destroys it.
+// The GitLab Webhook endpoint is secured through the X-Gitlab-Token header, it must contain the webhook secret token.
+func GitLabWebhookController(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	return webhookController(gitLabProvider{}, "gitlab", request)
+}
+
+// gitLabProvider is the webhookProvider for the Push Hook events of GitLab.
+type gitLabProvider struct{}
+
+func (gitLabProvider) repository(request events.APIGatewayProxyRequest) string {
+	webhook := types.GitLabWebhook{}
+	json.Unmarshal([]byte(request.Body), &webhook)
+	path := strings.Split(webhook.Project.PathWithNamespace, "/")
+	return path[len(path)-1]
+}
+
+func (gitLabProvider) verify(request events.APIGatewayProxyRequest, secrets []string) error {
+	token := getHeader(request, "X-Gitlab-Token")
+	for _, secret := range secrets {
+		if secret != "" && subtle.ConstantTimeCompare([]byte(token), []byte(secret)) == 1 {
+			return nil
+		}
+	}
+	return errors.New("Token validation failed")
+}
+
+func (gitLabProvider) parse(request events.APIGatewayProxyRequest) ([]webhookEvent, error) {
+	if getHeader(request, "X-Gitlab-Event") != "Push Hook" {
+		return nil, errUnsupportedWebhookEvent
+	}
+
+	webhook := types.GitLabWebhook{}
+	err := json.Unmarshal([]byte(request.Body), &webhook)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(webhook.Ref, "refs/heads/") {
+		return nil, errors.New("Invalid ref " + webhook.Ref)
+	}
+
+	event := webhookEvent{
+		Repository: gitLabProvider{}.repository(request),
+		Branch:     strings.TrimPrefix(webhook.Ref, "refs/heads/"),
+		Sender:     webhook.UserUsername,
+		Actor:      webhookActor("gitlab", webhook.UserUsername),
+	}
+	switch {
+	case isZeroSHA(webhook.Before):
+		event.Action = types.EnvironmentActionCreate
+	case isZeroSHA(webhook.After):
+		event.Action = types.EnvironmentActionDestroy
+	default:
+		// Pushes to existing branches don't create or delete an Environment
+		return []webhookEvent{}, nil
+	}
+	return []webhookEvent{event}, nil
+}
+
+// isZeroSHA returns true if the commit SHA consists of zeros, Git hosting providers send it for branches which don't exist before or after a push.
+func isZeroSHA(sha string) bool {
+	return sha != "" && strings.Trim(sha, "0") == ""
+}
@@ -0,0 +1,204 @@
+package controller
+
+import (
+	"crypto/hmac"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"hash"
+	"net/http"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// errUnsupportedWebhookEvent is returned by a webhookProvider if the event type isn't handled by the Tower.
+var errUnsupportedWebhookEvent = errors.New("Unsupported webhook event")
+
+// webhookEvent is a branch event of a Git hosting provider in a provider independent format.
+//
+// Action is create if the branch was created and destroy if it was deleted.
+//
+// Sender is the login of the user on the provider, it's matched by the sender rules of the repository Filters.
+// Actor is the identity recorded in the history and the audit log, e.g. "gitlab:<username>".
+type webhookEvent struct {
+	Action     types.EnvironmentAction
+	Repository string
+	Branch     string
+	Sender     string
+	Actor      string
+}
+
+// webhookProvider verifies and parses the webhooks of a Git hosting provider, the events are executed by webhookController independent of the provider.
+type webhookProvider interface {
+	// repository returns the name of the repository the request was sent for, it selects the webhook secrets the request is verified with
+	repository(request events.APIGatewayProxyRequest) string
+	// verify returns an error describing the failed check if the request wasn't sent by the provider with one of the webhook secrets
+	verify(request events.APIGatewayProxyRequest, secrets []string) error
+	// parse returns the webhookEvents of the request, a single request can contain multiple or no branch events (e.g. a push without new or deleted branches).
+	// If the event type isn't handled errUnsupportedWebhookEvent gets returned
+	parse(request events.APIGatewayProxyRequest) ([]webhookEvent, error)
+}
+
+// webhookActor returns the actor of a webhook event, it's the user of the provider who triggered the event.
+func webhookActor(provider string, login string) string {
+	if login == "" {
+		return provider
+	}
+	return provider + ":" + login
+}
+
+// webhookController verifies the request with the webhookProvider and executes its events, name is the provider in the route and the actor.
+// The request is verified with the webhook secrets of the repository, or the global secrets if the repository has no own secret.
+// Only verified requests are recorded in the audit log, with the actor of the first event.
+func webhookController(provider webhookProvider, name string, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	secrets, err := model.GetWebhookSecrets(provider.repository(request))
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	err = provider.verify(request, secrets)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/webhookController", "operation": "verify"}, 1)
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"" + err.Error() + "\" }", StatusCode: 400}, nil
+	}
+
+	target, actor := webhookAuditTarget(provider, name, request)
+	return auditCall(http.MethodPost, "/webhooks/"+name, target, actor, func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return executeWebhook(provider, request)
+	}, request)
+}
+
+// executeWebhook parses the already verified request with the webhookProvider and executes the events.
+// Created branches matching the Filters of the repository get an Environment, the Environment of deleted branches gets destroyed.
+// If the request contains a single event its response gets returned, for multiple events a list with the status code of each event gets returned.
+func executeWebhook(provider webhookProvider, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	parsed, err := provider.parse(request)
+	if err == errUnsupportedWebhookEvent {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unsupported webhook event\" }", StatusCode: 400}, nil
+	}
+	if err != nil {
+		return types.InvalidRequestBodyResponse, nil
+	}
+
+	switch len(parsed) {
+	case 0:
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Event is ignored\" }", StatusCode: 200}, nil
+	case 1:
+		return executeWebhookEvent(parsed[0])
+	}
+
+	results := []types.WebhookEventResult{}
+	for _, event := range parsed {
+		response, _ := executeWebhookEvent(event)
+		results = append(results, types.WebhookEventResult{Branch: event.Branch, Action: event.Action, StatusCode: response.StatusCode})
+	}
+
+	body, err := json.Marshal(results)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/webhookController", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 200}, nil
+}
+
+// executeWebhookEvent creates or destroys the Environment of the branch of the webhookEvent, if webhooks are activated for the repository.
+func executeWebhookEvent(event webhookEvent) (events.APIGatewayProxyResponse, error) {
+	if event.Repository == "" || event.Branch == "" {
+		return types.InvalidRequestBodyResponse, nil
+	}
+
+	repository := types.Repository{}
+	err := model.GetSingleRepository(&repository, event.Repository)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	if repository.Repository == "" {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	if !repository.Webhook {
+		return types.InvalidWebhookIsDeactivatedResponse, nil
+	}
+
+	if event.Action == types.EnvironmentActionDestroy {
+		return deleteWebhookEnvironment(event)
+	}
+	return createWebhookEnvironment(repository, event)
+}
+
+// validHMAC returns true if the hex encoded signature is the HMAC of the body with one of the secrets, empty secrets are never valid.
+func validHMAC(newHash func() hash.Hash, body string, secrets []string, signature string) bool {
+	messageMAC, err := hex.DecodeString(signature)
+	if err != nil || len(messageMAC) == 0 {
+		return false
+	}
+
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		mac := hmac.New(newHash, []byte(secret))
+		mac.Write([]byte(body))
+		if hmac.Equal(messageMAC, mac.Sum(nil)) {
+			return true
+		}
+	}
+	return false
+}
+
+// createWebhookEnvironment adds the Environment for the created branch, if the branch and the sender pass the Filters of the repository.
+func createWebhookEnvironment(repository types.Repository, event webhookEvent) (events.APIGatewayProxyResponse, error) {
+	hit, err := matchesFilters(repository.Filters, event.Branch, event.Sender)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/createWebhookEnvironment", "operation": "matchesFilters"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	if !hit {
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"No filter match\" }", StatusCode: 400}, nil
+	}
+
+	result, err := model.AddEnvironmentForRepository(types.EnvironmentPost{Branch: event.Branch}, repository.Repository, event.Actor)
+	if err != nil {
+		if model.IsConditionalCheckFailed(err) {
+			return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Unique constraint violation\" }", StatusCode: 400}, nil
+		}
+		return types.InternalServerErrorResponse, nil
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "controller/createWebhookEnvironment", "operation": "marshal"}, 0)
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: string(body), StatusCode: 201}, nil
+}
+
+// deleteWebhookEnvironment destroys the Environment of the deleted branch, if its status allows the destruction.
+func deleteWebhookEnvironment(event webhookEvent) (events.APIGatewayProxyResponse, error) {
+	status := types.EnvironmentStatus{}
+	err := model.GetSingleEnvironmentStatusInformation(&status, event.Repository, event.Branch)
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+	if !status.Status.Allows(types.EnvironmentActionDestroy) {
+		config.Logger.Log(errors.New("Can't delete environment in status = "+string(status.Status)), map[string]string{"module": "controller/deleteWebhookEnvironment", "operation": "statusCheck"}, 0)
+		return types.InvalidEnvironmentStatusResponse, nil
+	}
+
+	err = model.DeleteSingleEnvironment(event.Repository, event.Branch, event.Actor)
+	if err == model.ErrStatusConflict {
+		return types.EnvironmentStatusConflictResponse, nil
+	}
+	if err != nil {
+		return types.InternalServerErrorResponse, nil
+	}
+
+	return events.APIGatewayProxyResponse{Body: "", StatusCode: 204}, nil
+}
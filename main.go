@@ -1,108 +1,120 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"net/http"
+	"os"
 
 	"github.com/auto-staging/tower/config"
 	"github.com/auto-staging/tower/controller"
+	"github.com/auto-staging/tower/model"
+	"github.com/auto-staging/tower/router"
+	"github.com/auto-staging/tower/server"
+	"github.com/auto-staging/tower/types"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
+// routes is the route table of the Tower API, every request gets dispatched by resource and http method to the matching controller.
+var routes = []router.Route{
+	{Method: http.MethodGet, Resource: "/configuration", Controller: controller.GetConfigurationController, Permission: types.PermissionAdmin, Description: "Get the Tower configuration"},
+	{Method: http.MethodPut, Resource: "/configuration", Controller: controller.PutConfigurationController, Permission: types.PermissionAdmin, Description: "Update the Tower configuration"},
+	{Method: http.MethodGet, Resource: "/repositories", Controller: controller.GetAllRepositoriesController, Permission: types.PermissionRead, Description: "List all repositories"},
+	{Method: http.MethodPost, Resource: "/repositories", Controller: controller.AddRepositoryController, Permission: types.PermissionWrite, Description: "Add a repository"},
+	{Method: http.MethodGet, Resource: "/repositories/{name}", Controller: controller.GetSingleRepositoryController, Permission: types.PermissionRead, Description: "Get a single repository"},
+	{Method: http.MethodPut, Resource: "/repositories/{name}", Controller: controller.PutSingleRepositoryController, Permission: types.PermissionWrite, Description: "Update a repository"},
+	{Method: http.MethodPatch, Resource: "/repositories/{name}", Controller: controller.PatchSingleRepositoryController, Permission: types.PermissionWrite, Description: "Update single fields of a repository (JSON merge patch)"},
+	{Method: http.MethodDelete, Resource: "/repositories/{name}", Controller: controller.DeleteSingleRepositoryController, Permission: types.PermissionWrite, Description: "Delete a repository without environments"},
+	{Method: http.MethodPut, Resource: "/repositories/{name}/webhook-secret", Controller: controller.PutRepositoryWebhookSecretController, Permission: types.PermissionWrite, Description: "Set the webhook secret of a repository, the previous secret stays valid for 24 hours"},
+	{Method: http.MethodDelete, Resource: "/repositories/{name}/webhook-secret", Controller: controller.DeleteRepositoryWebhookSecretController, Permission: types.PermissionWrite, Description: "Remove the webhook secret of a repository, the global secret is used again"},
+	{Method: http.MethodGet, Resource: "/repositories/{name}/environments", Controller: controller.GetAllEnvironmentsForRepositoryController, Permission: types.PermissionRead, Description: "List all environments of a repository"},
+	{Method: http.MethodPost, Resource: "/repositories/{name}/environments", Controller: controller.AddEnvironmentForRepositoryController, Permission: types.PermissionWrite, Description: "Add an environment to a repository"},
+	{Method: http.MethodGet, Resource: "/repositories/{name}/environments/{branch}", Controller: controller.GetSingleEnvironmentForRepositoryController, Permission: types.PermissionRead, Description: "Get a single environment"},
+	{Method: http.MethodPut, Resource: "/repositories/{name}/environments/{branch}", Controller: controller.PutSinglEnvironmentForRepositoryController, Permission: types.PermissionWrite, Description: "Update an environment"},
+	{Method: http.MethodPatch, Resource: "/repositories/{name}/environments/{branch}", Controller: controller.PatchSingleEnvironmentForRepositoryController, Permission: types.PermissionWrite, Description: "Update single fields of an environment (JSON merge patch)"},
+	{Method: http.MethodDelete, Resource: "/repositories/{name}/environments/{branch}", Controller: controller.DeleteSingleEnvironmentController, Permission: types.PermissionWrite, Description: "Destroy an environment"},
+	{Method: http.MethodGet, Resource: "/repositories/environments/status", Controller: controller.GetAllEnvironmentsStatusInformationController, Permission: types.PermissionRead, Description: "Get the status of all environments"},
+	{Method: http.MethodGet, Resource: "/repositories/{name}/environments/{branch}/status", Controller: controller.GetSingleEnvironmentStatusInformationController, Permission: types.PermissionRead, Description: "Get the status of a single environment"},
+	{Method: http.MethodGet, Resource: "/repositories/{name}/environments/{branch}/history", Controller: controller.GetEnvironmentHistoryController, Permission: types.PermissionRead, Description: "Get the status history of a single environment"},
+	{Method: http.MethodGet, Resource: "/repositories/{name}/environments/{branch}/schedules", Controller: controller.GetEnvironmentSchedulesController, Permission: types.PermissionRead, Description: "Preview the next startup and shutdown times of an environment"},
+	{Method: http.MethodGet, Resource: "/repositories/environments", Controller: controller.GetGlobalRepositoryConfigController, Permission: types.PermissionRead, Description: "Get the global repository configuration"},
+	{Method: http.MethodPut, Resource: "/repositories/environments", Controller: controller.PutGlobalRepositoryConfigController, Permission: types.PermissionAdmin, Description: "Update the global repository configuration"},
+	{Method: http.MethodPatch, Resource: "/repositories/environments", Controller: controller.PatchGlobalRepositoryConfigController, Permission: types.PermissionAdmin, Description: "Update single fields of the global repository configuration (JSON merge patch)"},
+	{Method: http.MethodPost, Resource: "/webhooks/github", Controller: controller.GitHubWebhookController, Description: "GitHub webhook for the ping, create, delete, push and pull_request events (HMAC secured, deduplicated by X-GitHub-Delivery)"},
+	{Method: http.MethodPost, Resource: "/webhooks/gitlab", Controller: controller.GitLabWebhookController, Description: "GitLab webhook for Push Hook events creating or deleting branches (secured by X-Gitlab-Token)"},
+	{Method: http.MethodPost, Resource: "/webhooks/bitbucket", Controller: controller.BitbucketWebhookController, Description: "Bitbucket Cloud webhook for repo:push events creating or deleting branches (HMAC-SHA256 secured)"},
+	{Method: http.MethodPost, Resource: "/webhooks/gitea", Controller: controller.GiteaWebhookController, Description: "Gitea and Forgejo webhook for the create and delete events (HMAC-SHA256 secured)"},
+	{Method: http.MethodGet, Resource: "/webhooks/deliveries", Controller: controller.GetAllWebhookDeliveriesController, Permission: types.PermissionRead, Description: "List the recorded GitHub webhook deliveries"},
+	{Method: http.MethodGet, Resource: "/webhooks/deliveries/{id}", Controller: controller.GetSingleWebhookDeliveryController, Permission: types.PermissionRead, Description: "Get a single webhook delivery including its payload"},
+	{Method: http.MethodPost, Resource: "/webhooks/deliveries/{id}/replay", Controller: controller.ReplayWebhookDeliveryController, Permission: types.PermissionWrite, Description: "Process a failed webhook delivery again from its stored payload"},
+	{Method: http.MethodPost, Resource: "/callbacks/builder", Controller: controller.BuilderCallbackController, Description: "Builder callback with the result of an operation (HMAC secured)"},
+	{Method: http.MethodPost, Resource: "/triggers/schedule", Controller: controller.TriggerEnvironemtStatusChangeController, Permission: types.PermissionTrigger, Description: "Start or stop an environment"},
+	{Method: http.MethodPost, Resource: "/triggers/time-zones", Controller: controller.TriggerTimeZoneRefreshController, Permission: types.PermissionTrigger, Description: "Send the schedules of environments again whose time zones changed their UTC offset (call hourly)"},
+	{Method: http.MethodGet, Resource: "/audit", Controller: controller.GetAuditController, Permission: types.PermissionAdmin, Description: "Get the audit log of all mutating calls"},
+	{Method: http.MethodGet, Resource: "/role-bindings", Controller: controller.GetAllRoleBindingsController, Permission: types.PermissionAdmin, Description: "List all role bindings"},
+	{Method: http.MethodPut, Resource: "/role-bindings", Controller: controller.PutRoleBindingController, Permission: types.PermissionAdmin, Description: "Bind a role to an identity for a repository"},
+	{Method: http.MethodDelete, Resource: "/role-bindings", Controller: controller.DeleteRoleBindingController, Permission: types.PermissionAdmin, Description: "Remove a role binding"},
+	{Method: http.MethodGet, Resource: "/api-keys", Controller: controller.GetAllAPIKeysController, Permission: types.PermissionAdmin, Description: "List all API keys"},
+	{Method: http.MethodPost, Resource: "/api-keys", Controller: controller.AddAPIKeyController, Permission: types.PermissionAdmin, Description: "Create an API key, the key is only returned once"},
+	{Method: http.MethodDelete, Resource: "/api-keys/{id}", Controller: controller.RevokeAPIKeyController, Permission: types.PermissionAdmin, Description: "Revoke an API key"},
+	{Method: http.MethodGet, Resource: "/versions", Controller: controller.GetVersionsController, Permission: types.PermissionRead, Description: "Get the versions of all auto-staging components"},
+}
+
+var apiRouter = router.New(routes)
+
 // Handler is the main function called by lambda.Start, it redirects the request to the matching controller by resource and http method.
 // Since the Lambda function is called through API Gateway it uses APIGatewayProxyRequest as parameter
 // to get information about the request (containing ressource, method and much more) and APIGatewayProxyResponse as return value (including http code and response message)
 func Handler(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-
-	if request.Resource == "/configuration" && request.HTTPMethod == http.MethodGet {
-		return controller.GetConfigurationController(request)
-	}
-
-	if request.Resource == "/configuration" && request.HTTPMethod == http.MethodPut {
-		return controller.PutConfigurationController(request)
-	}
-
-	if request.Resource == "/repositories" && request.HTTPMethod == http.MethodGet {
-		return controller.GetAllRepositoriesController(request)
-	}
-
-	if request.Resource == "/repositories" && request.HTTPMethod == http.MethodPost {
-		return controller.AddRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}" && request.HTTPMethod == http.MethodGet {
-		return controller.GetSingleRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}" && request.HTTPMethod == http.MethodPut {
-		return controller.PutSingleRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}" && request.HTTPMethod == http.MethodDelete {
-		return controller.DeleteSingleRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}/environments" && request.HTTPMethod == http.MethodGet {
-		return controller.GetAllEnvironmentsForRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}/environments" && request.HTTPMethod == http.MethodPost {
-		return controller.AddEnvironmentForRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}/environments/{branch}" && request.HTTPMethod == http.MethodGet {
-		return controller.GetSingleEnvironmentForRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}/environments/{branch}" && request.HTTPMethod == http.MethodPut {
-		return controller.PutSinglEnvironmentForRepositoryController(request)
-	}
-
-	if request.Resource == "/repositories/{name}/environments/{branch}" && request.HTTPMethod == http.MethodDelete {
-		return controller.DeleteSingleEnvironmentController(request)
-	}
-
-	if request.Resource == "/repositories/environments/status" && request.HTTPMethod == http.MethodGet {
-		return controller.GetAllEnvironmentsStatusInformationController(request)
-	}
-
-	if request.Resource == "/repositories/{name}/environments/{branch}/status" && request.HTTPMethod == http.MethodGet {
-		return controller.GetSingleEnvironmentStatusInformationController(request)
-	}
-
-	if request.Resource == "/repositories/environments" && request.HTTPMethod == http.MethodGet {
-		return controller.GetGlobalRepositoryConfigController(request)
-	}
-
-	if request.Resource == "/repositories/environments" && request.HTTPMethod == http.MethodPut {
-		return controller.PutGlobalRepositoryConfigController(request)
-	}
-
-	if request.Resource == "/webhooks/github" && request.HTTPMethod == http.MethodPost && request.Headers["X-GitHub-Event"] == "ping" {
-		return controller.GitHubWebhookPingController(request)
-	}
-
-	if request.Resource == "/webhooks/github" && request.HTTPMethod == http.MethodPost && request.Headers["X-GitHub-Event"] == "create" {
-		return controller.GitHubWebhookCreateController(request)
-	}
-
-	if request.Resource == "/webhooks/github" && request.HTTPMethod == http.MethodPost && request.Headers["X-GitHub-Event"] == "delete" {
-		return controller.GitHubWebhookDeleteController(request)
-	}
-
-	if request.Resource == "/triggers/schedule" && request.HTTPMethod == http.MethodPost {
-		return controller.TriggerEnvironemtStatusChangeController(request)
-	}
-
-	if request.Resource == "/versions" && request.HTTPMethod == http.MethodGet {
-		return controller.GetVersionsController(request)
-	}
-
-	return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"No controller for requested resource and method found\" }", StatusCode: 400}, nil
+	return apiRouter.Handle(request)
 }
 
 func main() {
+	// Prints the route documentation generated from the route table
+	if len(os.Args) > 1 && os.Args[1] == "routes" {
+		fmt.Print(apiRouter.Documentation())
+		return
+	}
+
 	config.Init()
+	model.InitStore(os.Getenv("STORAGE_BACKEND"))
+	model.InitInvoker(os.Getenv("INVOKER_BACKEND"), os.Getenv("BUILDER_ENDPOINT"), os.Getenv("SCHEDULER_ENDPOINT"))
+
+	// Authenticates API keys and OIDC tokens before the call gets audited, so the key or the token subject is recorded as actor
+	apiRouter.Use(controller.APIKeyAuthenticationMiddleware)
+	if os.Getenv("OIDC_ISSUER") != "" {
+		groupRoles, err := model.ParseGroupRoles(os.Getenv("OIDC_GROUP_ROLES"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		err = model.InitOIDC(model.OIDCConfig{
+			Issuer:      os.Getenv("OIDC_ISSUER"),
+			Audience:    os.Getenv("OIDC_AUDIENCE"),
+			JWKSURL:     os.Getenv("OIDC_JWKS_URL"),
+			JWKSFile:    os.Getenv("OIDC_JWKS_FILE"),
+			GroupsClaim: os.Getenv("OIDC_GROUPS_CLAIM"),
+			GroupRoles:  groupRoles,
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+		apiRouter.Use(controller.OIDCAuthenticationMiddleware)
+	}
+	// Enforces the permissions of API keys and the role bindings before anything else reads the Store, denied calls are only logged
+	model.InitAccessControl(os.Getenv("ACCESS_CONTROL_ENABLED") == "true", os.Getenv("ACCESS_CONTROL_ADMINS"))
+	apiRouter.Use(controller.AccessControlMiddleware)
+	// Records all permitted mutating calls in the audit log
+	apiRouter.Use(controller.AuditMiddleware)
+
+	// Standalone mode, serves the API over HTTP instead of running as Lambda function
+	if len(os.Args) > 1 && os.Args[1] == "serve" {
+		address := os.Getenv("SERVER_ADDRESS")
+		if address == "" {
+			address = ":8080"
+		}
+		log.Fatal(server.ListenAndServe(address, os.Getenv("SERVER_STAGE"), apiRouter.Resources(), Handler))
+	}
 
 	lambda.Start(Handler)
 }
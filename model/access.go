@@ -0,0 +1,113 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/auto-staging/tower/types"
+)
+
+// accessControlEnabled is true if the RoleBindings are enforced.
+var accessControlEnabled = false
+
+// accessAdmins contains the identities which are admin for all Repositories independent of the stored RoleBindings.
+var accessAdmins = map[string]bool{}
+
+// InitAccessControl enables the enforcement of the RoleBindings and sets the comma separated identities which are admin for all Repositories
+// independent of the stored RoleBindings, they are needed to add the first RoleBindings.
+func InitAccessControl(enabled bool, admins string) {
+	accessControlEnabled = enabled
+	accessAdmins = map[string]bool{}
+	for _, admin := range strings.Split(admins, ",") {
+		admin = strings.TrimSpace(admin)
+		if admin != "" {
+			accessAdmins[admin] = true
+		}
+	}
+}
+
+// Authorize returns true if a Role bound to the identity grants the permission for the repository.
+// RoleBindings for all Repositories ("*") apply to every repository, an empty repository means the permission is required for all Repositories,
+// so only RoleBindings for all Repositories are taken into account. If access control isn't enabled every identity is authorized.
+// If an error occurs the error gets logged and then returned.
+func Authorize(identity string, repository string, permission types.Permission) (bool, error) {
+	if !accessControlEnabled || accessAdmins[identity] {
+		return true, nil
+	}
+
+	bindings := []types.RoleBinding{}
+	err := store.GetRoleBindingsForIdentity(&bindings, identity)
+	if err != nil {
+		return false, err
+	}
+
+	for _, binding := range bindings {
+		if binding.Repository != types.AllRepositories && (repository == "" || binding.Repository != repository) {
+			continue
+		}
+		if binding.Role.Grants(permission) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetSingleRoleBinding reads the RoleBinding of the identity for the repository from the Store and writes it into the RoleBinding struct given in the parameters (call by reference).
+// If the RoleBinding doesn't exist the struct stays untouched.
+// If an error occurs the error gets logged and then returned.
+func GetSingleRoleBinding(binding *types.RoleBinding, identity string, repository string) error {
+	bindings := []types.RoleBinding{}
+	err := store.GetRoleBindingsForIdentity(&bindings, identity)
+	if err != nil {
+		return err
+	}
+
+	for _, stored := range bindings {
+		if stored.Repository == repository {
+			*binding = stored
+		}
+	}
+	return nil
+}
+
+// GetAllRoleBindings reads all RoleBindings from the Store and writes them into the array of RoleBinding structs given in the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func GetAllRoleBindings(bindings *[]types.RoleBinding) error {
+	all := []types.RoleBinding{}
+	cursor := ""
+	for {
+		page := []types.RoleBinding{}
+		next, err := store.GetRoleBindingsPage(&page, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	*bindings = all
+	return nil
+}
+
+// GetRoleBindingsPage reads up to limit RoleBindings starting at the cursor from the Store and writes them into the array of RoleBinding structs given in the parameters (call by reference).
+// The cursor for the next page gets returned, if no more RoleBindings exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetRoleBindingsPage(bindings *[]types.RoleBinding, limit int64, cursor string) (string, error) {
+	return store.GetRoleBindingsPage(bindings, limit, cursor)
+}
+
+// PutRoleBinding stores the RoleBinding given in the parameters (call by reference), an existing RoleBinding of the identity for the same repository gets replaced.
+// If an error occurs the error gets logged and then returned.
+func PutRoleBinding(binding *types.RoleBinding) error {
+	return store.PutRoleBinding(binding)
+}
+
+// DeleteRoleBinding deletes the RoleBinding of the identity for the repository, the deleted RoleBinding gets written into the RoleBinding struct given in the parameters (call by reference).
+// If the RoleBinding didn't exist the struct stays untouched.
+// If an error occurs the error gets logged and then returned.
+func DeleteRoleBinding(binding *types.RoleBinding, identity string, repository string) error {
+	return store.DeleteRoleBinding(binding, identity, repository)
+}
@@ -0,0 +1,120 @@
+package model
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// APIKeyPrefix is the prefix of all APIKeys, it distinguishes them from other bearer tokens.
+const APIKeyPrefix = "tower_"
+
+// ErrInvalidAPIKey is returned if a key doesn't match a stored APIKey or the APIKey was revoked.
+var ErrInvalidAPIKey = errors.New("Invalid API key")
+
+func hashAPIKeySecret(secret string) string {
+	hash := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(hash[:])
+}
+
+// CreateAPIKey creates a new APIKey with the values from the APIKeyPost struct, the actor is stored as creator.
+// The key has the format "tower_<id>_<secret>", only the hash of the secret gets stored. The created APIKey and the key get returned.
+// If an error occurs the error gets logged and then returned.
+func CreateAPIKey(post types.APIKeyPost, actor string) (types.APIKey, string, error) {
+	id, err := randomHex(8)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/CreateAPIKey", "operation": "randomID"}, 0)
+		return types.APIKey{}, "", err
+	}
+	secret, err := randomHex(32)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/CreateAPIKey", "operation": "randomSecret"}, 0)
+		return types.APIKey{}, "", err
+	}
+
+	key := types.APIKey{
+		ID:           id,
+		Name:         post.Name,
+		Scope:        post.Scope,
+		Repository:   post.Repository,
+		Hash:         hashAPIKeySecret(secret),
+		CreationDate: time.Now().UTC().String(),
+		CreatedBy:    actor,
+	}
+	err = store.AddAPIKey(&key)
+	if err != nil {
+		return types.APIKey{}, "", err
+	}
+
+	return key, APIKeyPrefix + id + "_" + secret, nil
+}
+
+// AuthenticateAPIKey returns the APIKey matching the key, if the key is malformed, doesn't match a stored APIKey or the APIKey was revoked ErrInvalidAPIKey gets returned.
+// Other errors get logged and then returned.
+func AuthenticateAPIKey(key string) (types.APIKey, error) {
+	parts := strings.SplitN(strings.TrimPrefix(key, APIKeyPrefix), "_", 2)
+	if !strings.HasPrefix(key, APIKeyPrefix) || len(parts) != 2 || parts[0] == "" {
+		return types.APIKey{}, ErrInvalidAPIKey
+	}
+
+	stored := types.APIKey{}
+	err := store.GetSingleAPIKey(&stored, parts[0])
+	if err != nil {
+		return types.APIKey{}, err
+	}
+
+	if stored.ID == "" || stored.Revoked || subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(hashAPIKeySecret(parts[1]))) != 1 {
+		return types.APIKey{}, ErrInvalidAPIKey
+	}
+
+	return stored, nil
+}
+
+// GetAllAPIKeys reads all APIKeys (including revoked ones) from the Store and writes them into the array of APIKey structs given in the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func GetAllAPIKeys(keys *[]types.APIKey) error {
+	all := []types.APIKey{}
+	cursor := ""
+	for {
+		page := []types.APIKey{}
+		next, err := store.GetAPIKeysPage(&page, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	*keys = all
+	return nil
+}
+
+// GetAPIKeysPage reads up to limit APIKeys starting at the cursor from the Store and writes them into the array of APIKey structs given in the parameters (call by reference).
+// The cursor for the next page gets returned, if no more APIKeys exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetAPIKeysPage(keys *[]types.APIKey, limit int64, cursor string) (string, error) {
+	return store.GetAPIKeysPage(keys, limit, cursor)
+}
+
+// GetSingleAPIKey reads the APIKey where id matches the id given in the parameters from the Store and writes it into the APIKey struct given in the parameters (call by reference).
+// If the APIKey doesn't exist the struct stays untouched.
+// If an error occurs the error gets logged and then returned.
+func GetSingleAPIKey(key *types.APIKey, id string) error {
+	return store.GetSingleAPIKey(key, id)
+}
+
+// RevokeAPIKey revokes the APIKey where id matches the id given in the parameters, revoked APIKeys are kept so they stay visible in the list of APIKeys.
+// The revoked APIKey gets written into the APIKey struct given in the parameters (call by reference).
+// If the APIKey doesn't exist the condition fails with a ConditionalCheckFailedException, errors get logged and then returned.
+func RevokeAPIKey(key *types.APIKey, id string) error {
+	return store.RevokeAPIKey(key, id, time.Now().UTC().String())
+}
@@ -0,0 +1,127 @@
+package model
+
+import (
+	"sort"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// Target types of the AuditEntry, they name the kind of item changed by the audited call.
+const (
+	AuditTargetConfiguration = "configuration"
+	AuditTargetGlobalConfig  = "globalConfig"
+	AuditTargetRepository    = "repository"
+	AuditTargetEnvironment   = "environment"
+	AuditTargetRoleBinding   = "roleBinding"
+	AuditTargetAPIKey        = "apiKey"
+)
+
+// AuditFilter restricts the audit entries read from the Store, empty fields don't restrict the result.
+// From and To are inclusive bounds of the time of the entries, Target is one of the AuditTarget types.
+type AuditFilter struct {
+	From       time.Time
+	To         time.Time
+	Target     string
+	Repository string
+	Branch     string
+}
+
+// auditScope returns the key of the audit entries of a target, all entries of a Repository and its Environments share one scope so they can be queried together.
+// Repository names can't contain "/", so the scope can't collide with the other target types.
+func auditScope(target string, repository string) string {
+	if repository == "" {
+		return target
+	}
+	return AuditTargetRepository + "/" + repository
+}
+
+// timeBounds returns the From and To times of the filter in the format of the stored times, unset bounds are replaced by values below or above all stored times.
+func (f AuditFilter) timeBounds() (string, string) {
+	from := "0"
+	if !f.From.IsZero() {
+		from = f.From.UTC().Format(historyTimeLayout)
+	}
+	to := "9"
+	if !f.To.IsZero() {
+		to = f.To.UTC().Format(historyTimeLayout)
+	}
+	return from, to
+}
+
+// scope returns the single scope the filter is restricted to, an empty scope means entries of all scopes match.
+func (f AuditFilter) scope() string {
+	if f.Repository != "" {
+		return auditScope(AuditTargetRepository, f.Repository)
+	}
+	if f.Target == AuditTargetConfiguration || f.Target == AuditTargetGlobalConfig || f.Target == AuditTargetAPIKey {
+		return f.Target
+	}
+	return ""
+}
+
+// matches returns true if the AuditEntry matches all restrictions of the filter.
+func (f AuditFilter) matches(entry types.AuditEntry) bool {
+	from, to := f.timeBounds()
+	switch {
+	case entry.Time < from || entry.Time > to:
+		return false
+	case f.Target != "" && entry.Target != f.Target:
+		return false
+	case f.Repository != "" && entry.Repository != f.Repository:
+		return false
+	case f.Branch != "" && entry.Branch != f.Branch:
+		return false
+	}
+	return true
+}
+
+// RecordAuditEntry adds the AuditEntry to the audit log, the scope and the time get set to now.
+// The audited call already happened, so errors are only logged.
+func RecordAuditEntry(entry types.AuditEntry) {
+	entry.Scope = auditScope(entry.Target, entry.Repository)
+	entry.Time = time.Now().UTC().Format(historyTimeLayout)
+
+	err := store.AddAuditEntry(&entry)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/RecordAuditEntry", "operation": "store/add"}, 0)
+	}
+}
+
+// GetAllAuditEntries reads all audit entries matching the AuditFilter from the Store, newest entries first.
+// The entries are written into the array of AuditEntry structs given in the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func GetAllAuditEntries(entries *[]types.AuditEntry, filter AuditFilter) error {
+	all := []types.AuditEntry{}
+	cursor := ""
+	for {
+		page := []types.AuditEntry{}
+		next, err := store.GetAuditEntriesPage(&page, filter, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	// Pages of entries from all scopes aren't ordered by time
+	sort.SliceStable(all, func(i, j int) bool {
+		return all[i].Time > all[j].Time
+	})
+
+	*entries = all
+	return nil
+}
+
+// GetAuditEntriesPage reads up to limit audit entries matching the AuditFilter starting at the cursor from the Store.
+// The entries are written into the array of AuditEntry structs given in the parameters (call by reference).
+// Entries of a single scope (Repository, configuration or global configuration) are returned newest first, the order of entries from all scopes depends on the Store.
+// The cursor for the next page gets returned, if no more entries exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetAuditEntriesPage(entries *[]types.AuditEntry, filter AuditFilter, limit int64, cursor string) (string, error) {
+	return store.GetAuditEntriesPage(entries, filter, limit, cursor)
+}
@@ -0,0 +1,58 @@
+package model
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// AnyVersion can be used as expected version for updates, the update gets applied independent of the stored version.
+const AnyVersion int64 = -1
+
+// ErrVersionMismatch is returned by updates with an expected version, if the item exists but the stored version has moved on.
+var ErrVersionMismatch = errors.New("Version mismatch")
+
+// versionUpdateExpression is appended to the UpdateExpression of versioned items, every update increments the version by one.
+// Items stored before versioning was introduced have no version attribute, they are treated as version 0.
+const versionUpdateExpression = "#version = if_not_exists(#version, :zeroVersion) + :oneVersion"
+
+// addVersionUpdate adds the attribute names and values required by versionUpdateExpression and the version condition to the update maps.
+// The returned condition must be combined with the exists condition of the update, it's empty if expectedVersion is AnyVersion.
+func addVersionUpdate(names map[string]*string, values map[string]*dynamodb.AttributeValue, expectedVersion int64) string {
+	names["#version"] = aws.String("version")
+	values[":zeroVersion"] = &dynamodb.AttributeValue{N: aws.String("0")}
+	values[":oneVersion"] = &dynamodb.AttributeValue{N: aws.String("1")}
+
+	switch {
+	case expectedVersion == AnyVersion:
+		return ""
+	case expectedVersion == 0:
+		return " AND attribute_not_exists(#version)"
+	default:
+		values[":expectedVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(expectedVersion, 10))}
+		return " AND #version = :expectedVersion"
+	}
+}
+
+// itemVersion returns the version attribute of a stored item, items without version attribute have version 0.
+func itemVersion(item map[string]*dynamodb.AttributeValue) int64 {
+	if item["version"] == nil {
+		return 0
+	}
+	version, _ := strconv.ParseInt(aws.StringValue(item["version"].N), 10, 64)
+	return version
+}
+
+// incrementItemVersion sets the version attribute of a stored item to the next version, like versionUpdateExpression.
+func incrementItemVersion(item map[string]*dynamodb.AttributeValue) {
+	item["version"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(itemVersion(item)+1, 10))}
+}
+
+// IsConditionalCheckFailed returns true if the error was caused by a failed condition of a conditional write of the Store,
+// e.g. because an item with the same key exists (unique constraint) or the item to update doesn't exist.
+func IsConditionalCheckFailed(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "ConditionalCheckFailedException")
+}
@@ -0,0 +1,273 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronMinYear and cronMaxYear are the bounds of the year field of AWS cron expressions.
+const (
+	cronMinYear = 1970
+	cronMaxYear = 2199
+)
+
+var cronMonthNames = map[string]int{"JAN": 1, "FEB": 2, "MAR": 3, "APR": 4, "MAY": 5, "JUN": 6, "JUL": 7, "AUG": 8, "SEP": 9, "OCT": 10, "NOV": 11, "DEC": 12}
+
+var cronWeekdayNames = map[string]int{"SUN": 1, "MON": 2, "TUE": 3, "WED": 4, "THU": 5, "FRI": 6, "SAT": 7}
+
+// CronSchedule is a parsed cron expression of the AWS dialect used by the CloudWatch Events rules of the Builder.
+// The six fields are Minutes, Hours, Day-of-month, Month, Day-of-week and Year, Next evaluates them in UTC and NextIn in the local time of a location.
+type CronSchedule struct {
+	minutes []bool
+	hours   []bool
+	months  []bool
+	years   []bool
+	day     func(t time.Time) bool
+}
+
+// ParseCron parses and validates the cron expression, it's the content of the "cron(...)" schedule expression of the rule without the surrounding "cron(" and ")".
+// Day-of-month supports "L" (last day) and "<day>W" (nearest weekday), Day-of-week supports "L" (Saturday), "<weekday>L" (last weekday of the month) and "<weekday>#<n>" (n-th weekday of the month).
+// Exactly one of Day-of-month and Day-of-week must be "?". If the expression is invalid the returned error describes the invalid field.
+func ParseCron(expression string) (CronSchedule, error) {
+	fields := strings.Fields(expression)
+	if len(fields) != 6 {
+		return CronSchedule{}, fmt.Errorf("Invalid cron %q: expected 6 fields (minutes hours day-of-month month day-of-week year), got %d", expression, len(fields))
+	}
+
+	schedule := CronSchedule{}
+	var err error
+	for _, field := range []struct {
+		name   string
+		value  string
+		target *[]bool
+		min    int
+		max    int
+		names  map[string]int
+	}{
+		{"minutes", fields[0], &schedule.minutes, 0, 59, nil},
+		{"hours", fields[1], &schedule.hours, 0, 23, nil},
+		{"month", fields[3], &schedule.months, 1, 12, cronMonthNames},
+		{"year", fields[5], &schedule.years, cronMinYear, cronMaxYear, nil},
+	} {
+		*field.target, err = parseCronField(field.value, field.min, field.max, field.names, true)
+		if err != nil {
+			return CronSchedule{}, fmt.Errorf("Invalid cron %q: %s %v", expression, field.name, err)
+		}
+	}
+
+	schedule.day, err = parseCronDays(fields[2], fields[4])
+	if err != nil {
+		return CronSchedule{}, fmt.Errorf("Invalid cron %q: %v", expression, err)
+	}
+
+	return schedule, nil
+}
+
+// Next returns the first time after the time given in the parameters the schedule fires in UTC, if it never fires again the zero time gets returned.
+func (c CronSchedule) Next(after time.Time) time.Time {
+	return c.NextIn(after, time.UTC)
+}
+
+// NextIn returns the first time after the time given in the parameters the schedule fires, if the fields are interpreted as local time of the location.
+// Local times skipped by a daylight saving time change don't fire. If the schedule never fires again the zero time gets returned.
+func (c CronSchedule) NextIn(after time.Time, location *time.Location) time.Time {
+	t := after.In(location).Truncate(time.Minute).Add(time.Minute)
+	for t.Year() <= cronMaxYear {
+		var next time.Time
+		switch {
+		case t.Year() < cronMinYear || !c.years[t.Year()]:
+			next = time.Date(t.Year()+1, time.January, 1, 0, 0, 0, 0, location)
+		case !c.months[int(t.Month())]:
+			next = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, location)
+		case !c.day(t):
+			next = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, location)
+		case !c.hours[t.Hour()]:
+			next = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, location)
+		case !c.minutes[t.Minute()]:
+			next = t.Add(time.Minute)
+		default:
+			return t
+		}
+		// A local time skipped by a daylight saving time change can be normalized to a time before t, continue minute by minute until the change is passed
+		if !next.After(t) {
+			next = t.Add(time.Minute)
+		}
+		t = next
+	}
+	return time.Time{}
+}
+
+// parseCronField returns the values of the field as lookup table, the field is a list of values, ranges ("1-5"), wildcards ("*") and steps ("*/5", "10/5" or "1-30/5").
+func parseCronField(field string, min int, max int, names map[string]int, allowStep bool) ([]bool, error) {
+	values := make([]bool, max+1)
+	for _, item := range strings.Split(field, ",") {
+		base, step, stepped := item, 1, false
+		if i := strings.Index(item, "/"); i >= 0 {
+			if !allowStep {
+				return nil, errors.New("doesn't support steps")
+			}
+			var err error
+			base, stepped = item[:i], true
+			step, err = strconv.Atoi(item[i+1:])
+			if err != nil || step < 1 {
+				return nil, fmt.Errorf("has invalid step %q", item[i+1:])
+			}
+		}
+
+		start, end := min, max
+		var err error
+		switch {
+		case base == "*":
+		case strings.Contains(base, "-"):
+			parts := strings.SplitN(base, "-", 2)
+			if start, err = parseCronValue(parts[0], min, max, names); err != nil {
+				return nil, err
+			}
+			if end, err = parseCronValue(parts[1], min, max, names); err != nil {
+				return nil, err
+			}
+			if start > end {
+				return nil, fmt.Errorf("has invalid range %q", base)
+			}
+		default:
+			if start, err = parseCronValue(base, min, max, names); err != nil {
+				return nil, err
+			}
+			if !stepped {
+				end = start
+			}
+		}
+
+		for value := start; value <= end; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+func parseCronValue(value string, min int, max int, names map[string]int) (int, error) {
+	if number, ok := names[strings.ToUpper(value)]; ok {
+		return number, nil
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < min || number > max {
+		return 0, fmt.Errorf("has invalid value %q (allowed %d-%d)", value, min, max)
+	}
+	return number, nil
+}
+
+// parseCronDays returns the matcher of the days the schedule fires on, exactly one of the day fields must be "?".
+func parseCronDays(dayOfMonth string, dayOfWeek string) (func(t time.Time) bool, error) {
+	switch {
+	case dayOfMonth == "?" && dayOfWeek == "?":
+		return nil, errors.New("day-of-month and day-of-week can't both be ?")
+	case dayOfMonth != "?" && dayOfWeek != "?":
+		return nil, errors.New("one of day-of-month and day-of-week must be ?")
+	case dayOfWeek == "?":
+		day, err := parseCronDayOfMonth(dayOfMonth)
+		if err != nil {
+			return nil, fmt.Errorf("day-of-month %v", err)
+		}
+		return day, nil
+	}
+
+	day, err := parseCronDayOfWeek(dayOfWeek)
+	if err != nil {
+		return nil, fmt.Errorf("day-of-week %v", err)
+	}
+	return day, nil
+}
+
+func parseCronDayOfMonth(field string) (func(t time.Time) bool, error) {
+	if field == "L" {
+		return func(t time.Time) bool {
+			return t.Day() == lastDayOfMonth(t)
+		}, nil
+	}
+
+	if strings.HasSuffix(field, "W") {
+		day, err := parseCronValue(strings.TrimSuffix(field, "W"), 1, 31, nil)
+		if err != nil {
+			return nil, err
+		}
+		return func(t time.Time) bool {
+			return t.Day() == nearestWeekday(t, day)
+		}, nil
+	}
+
+	days, err := parseCronField(field, 1, 31, nil, true)
+	if err != nil {
+		return nil, err
+	}
+	return func(t time.Time) bool {
+		return days[t.Day()]
+	}, nil
+}
+
+func parseCronDayOfWeek(field string) (func(t time.Time) bool, error) {
+	if field == "L" {
+		return func(t time.Time) bool {
+			return t.Weekday() == time.Saturday
+		}, nil
+	}
+
+	if parts := strings.SplitN(field, "#", 2); len(parts) == 2 {
+		weekday, err := parseCronValue(parts[0], 1, 7, cronWeekdayNames)
+		if err != nil {
+			return nil, err
+		}
+		n, err := parseCronValue(parts[1], 1, 5, nil)
+		if err != nil {
+			return nil, err
+		}
+		return func(t time.Time) bool {
+			return int(t.Weekday())+1 == weekday && (t.Day()-1)/7+1 == n
+		}, nil
+	}
+
+	if strings.HasSuffix(field, "L") {
+		weekday, err := parseCronValue(strings.TrimSuffix(field, "L"), 1, 7, cronWeekdayNames)
+		if err != nil {
+			return nil, err
+		}
+		return func(t time.Time) bool {
+			return int(t.Weekday())+1 == weekday && t.Day()+7 > lastDayOfMonth(t)
+		}, nil
+	}
+
+	weekdays, err := parseCronField(field, 1, 7, cronWeekdayNames, false)
+	if err != nil {
+		return nil, err
+	}
+	return func(t time.Time) bool {
+		return weekdays[int(t.Weekday())+1]
+	}, nil
+}
+
+func lastDayOfMonth(t time.Time) int {
+	return time.Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// nearestWeekday returns the weekday of the month closest to the day, without leaving the month. If the month is shorter than the day -1 gets returned.
+func nearestWeekday(t time.Time, day int) int {
+	last := lastDayOfMonth(t)
+	if day > last {
+		return -1
+	}
+	switch time.Date(t.Year(), t.Month(), day, 0, 0, 0, 0, time.UTC).Weekday() {
+	case time.Saturday:
+		if day == 1 {
+			return 3
+		}
+		return day - 1
+	case time.Sunday:
+		if day == last {
+			return day - 2
+		}
+		return day + 1
+	}
+	return day
+}
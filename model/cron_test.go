@@ -0,0 +1,119 @@
+package model
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCronNext(t *testing.T) {
+	after := time.Date(2026, time.October, 18, 11, 30, 0, 0, time.UTC) // Sunday
+
+	tests := []struct {
+		name       string
+		expression string
+		want       string
+	}{
+		{"weekday range", "0 18 ? * MON-FRI *", "2026-10-19T18:00:00Z"},
+		{"numeric weekday range", "30 7 ? * 2-6 *", "2026-10-19T07:30:00Z"},
+		{"same day", "0 12 ? * SUN *", "2026-10-18T12:00:00Z"},
+		{"minute step", "0/15 * * * ? *", "2026-10-18T11:45:00Z"},
+		{"wildcard step", "*/20 * * * ? *", "2026-10-18T11:40:00Z"},
+		{"range step, month names and years", "0 8 1-5/2 jan,mar ? 2027-2030", "2027-01-01T08:00:00Z"},
+		{"last day of month", "0 0 L * ? *", "2026-10-31T00:00:00Z"},
+		{"last day of february", "0 0 L 2 ? *", "2027-02-28T00:00:00Z"},
+		{"leap day", "0 0 29 2 ? *", "2028-02-29T00:00:00Z"},
+		{"nearest weekday of sunday first", "0 9 1W * ? *", "2026-11-02T09:00:00Z"},
+		{"nearest weekday of sunday", "0 9 15W * ? *", "2026-11-16T09:00:00Z"},
+		{"nearest weekday of saturday", "0 9 31W * ? *", "2026-10-30T09:00:00Z"},
+		{"nearest weekday of weekday", "0 9 20W * ? *", "2026-10-20T09:00:00Z"},
+		{"saturday as last weekday", "0 12 ? * L *", "2026-10-24T12:00:00Z"},
+		{"last friday", "0 9 ? * 6L *", "2026-10-30T09:00:00Z"},
+		{"second monday", "0 9 ? * MON#2 *", "2026-11-09T09:00:00Z"},
+		{"fifth sunday", "0 9 ? * 1#5 *", "2026-11-29T09:00:00Z"},
+		{"past year", "0 0 1 1 ? 2020", "0001-01-01T00:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) returned %v", test.expression, err)
+			}
+			if got := schedule.Next(after).Format(time.RFC3339); got != test.want {
+				t.Errorf("Next of %q = %s, want %s", test.expression, got, test.want)
+			}
+		})
+	}
+}
+
+func TestParseCronInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+	}{
+		{"empty", ""},
+		{"five fields", "* * * * ?"},
+		{"no question mark", "0 18 * * MON-FRI *"},
+		{"two question marks", "0 18 ? * ? *"},
+		{"minute out of range", "60 18 ? * MON *"},
+		{"hour out of range", "0 24 ? * MON *"},
+		{"day out of range", "0 18 32 * ? *"},
+		{"month out of range", "0 18 ? 13 MON *"},
+		{"year out of range", "0 18 1 * ? 2200"},
+		{"reversed range", "0 18 5-1 * ? *"},
+		{"zero step", "0 */0 * * ? *"},
+		{"unknown name", "0 18 ? * FOO *"},
+		{"step with weekday", "0 18 ? * MON/2 *"},
+		{"weekday out of range", "0 18 ? * 8#1 *"},
+		{"sixth weekday", "0 18 ? * 2#6 *"},
+		{"nearest weekday out of range", "0 18 32W * ? *"},
+		{"last weekday out of range", "0 18 ? * 9L *"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := ParseCron(test.expression); err == nil {
+				t.Errorf("ParseCron(%q) returned no error", test.expression)
+			}
+		})
+	}
+}
+
+func TestCronNextIn(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("time zone database isn't available")
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("time zone database isn't available")
+	}
+
+	tests := []struct {
+		name       string
+		expression string
+		location   *time.Location
+		after      time.Time
+		want       string
+	}{
+		{"summer time", "0 7 ? * MON-FRI *", berlin, time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC), "2026-10-19T07:00:00+02:00"},
+		{"winter time", "0 7 ? * MON-FRI *", berlin, time.Date(2026, time.November, 1, 12, 0, 0, 0, time.UTC), "2026-11-02T07:00:00+01:00"},
+		{"local day differs from UTC day", "0 20 ? * FRI *", newYork, time.Date(2026, time.October, 17, 1, 0, 0, 0, time.UTC), "2026-10-23T20:00:00-04:00"},
+		{"skipped by spring forward", "30 2 * * ? *", berlin, time.Date(2027, time.March, 27, 12, 0, 0, 0, time.UTC), "2027-03-29T02:30:00+02:00"},
+		{"hour after spring forward", "30 3 * * ? *", berlin, time.Date(2027, time.March, 27, 12, 0, 0, 0, time.UTC), "2027-03-28T03:30:00+02:00"},
+		{"skipped by spring forward in new york", "15 2 14 3 ? *", newYork, time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC), "2028-03-14T02:15:00-04:00"},
+		{"utc location", "0 7 ? * MON-FRI *", time.UTC, time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC), "2026-10-19T07:00:00Z"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			schedule, err := ParseCron(test.expression)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) returned %v", test.expression, err)
+			}
+			if got := schedule.NextIn(test.after, test.location).Format(time.RFC3339); got != test.want {
+				t.Errorf("NextIn of %q = %s, want %s", test.expression, got, test.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// ErrInvalidCursor is returned by the paginated functions if the cursor can't be decoded.
+var ErrInvalidCursor = errors.New("Invalid cursor")
+
+// encodeCursor converts the LastEvaluatedKey of a DynamoDB Query or Scan into an opaque cursor string, an empty key results in an empty cursor.
+// All key attributes of the auto-staging Tables are strings, so only the string values are stored in the cursor.
+func encodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	if len(key) == 0 {
+		return "", nil
+	}
+
+	values := map[string]string{}
+	for name, value := range key {
+		values[name] = aws.StringValue(value.S)
+	}
+
+	body, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(body), nil
+}
+
+// decodeCursor converts a cursor created by encodeCursor back into the ExclusiveStartKey for a DynamoDB Query or Scan.
+// An empty cursor results in a nil key, if the cursor is invalid ErrInvalidCursor gets returned.
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	values := map[string]string{}
+	err = json.Unmarshal(body, &values)
+	if err != nil || len(values) == 0 {
+		return nil, ErrInvalidCursor
+	}
+
+	key := map[string]*dynamodb.AttributeValue{}
+	for name, value := range values {
+		key[name] = &dynamodb.AttributeValue{S: aws.String(value)}
+	}
+
+	return key, nil
+}
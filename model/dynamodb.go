@@ -2,11 +2,15 @@ package model
 
 import (
 	"os"
+	"strconv"
+	"strings"
 
 	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
 func getDynamoDbClient() *dynamodb.DynamoDB {
@@ -20,3 +24,1435 @@ func getDynamoDbClient() *dynamodb.DynamoDB {
 
 	return dynamodb.New(sess)
 }
+
+// DynamoDBStore is the Store implementation backed by the auto-staging DynamoDB Tables.
+type DynamoDBStore struct {
+	RepositoriesTable string
+	EnvironmentsTable string
+	GlobalConfigTable string
+	HistoryTable      string
+	AuditTable        string
+	RoleBindingsTable string
+	APIKeysTable      string
+	DeliveriesTable   string
+}
+
+// NewDynamoDBStore returns a DynamoDBStore configured with the default auto-staging Table names.
+func NewDynamoDBStore() *DynamoDBStore {
+	return &DynamoDBStore{
+		RepositoriesTable: "auto-staging-repositories",
+		EnvironmentsTable: "auto-staging-environments",
+		GlobalConfigTable: "auto-staging-repositories-global-config",
+		HistoryTable:      "auto-staging-environments-history",
+		AuditTable:        "auto-staging-tower-audit",
+		RoleBindingsTable: "auto-staging-tower-role-bindings",
+		APIKeysTable:      "auto-staging-tower-api-keys",
+		DeliveriesTable:   "auto-staging-tower-webhook-deliveries",
+	}
+}
+
+// GetRepositoriesPage reads one page of Repositories from the DynamoDB Table and unmarshals them into the array of Repository structs from the parameters (call by reference).
+// A limit of 0 reads as many Repositories as DynamoDB returns for a single Scan, the cursor of the previous page continues the Scan.
+// The cursor for the next page gets returned, if no more Repositories exist the cursor is empty.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetRepositoriesPage(repositories *[]types.Repository, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetRepositoriesPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(s.RepositoriesTable),
+		ExclusiveStartKey: startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Scan(input)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetRepositoriesPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, repositories)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetRepositoriesPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// GetSingleRepository reads a single Repository entry from the DynamoDB Table where repository matches the namen given in the parameters.
+// The response gets unmarshaled into the Repository struct from the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetSingleRepository(repository *types.Repository, name string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.RepositoriesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+		},
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleRepository", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, repository)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleRepository", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// AddRepository adds a new Repository to the DynamoDB Table, the put is conditional on no Repository with the same name existing.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) AddRepository(repository *types.Repository) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(repository)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddRepository", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(s.RepositoriesTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(repository)"),
+	}
+
+	_, err = svc.PutItem(input)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddRepository", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateSingleRepository updates an existing Repository in DynamoDB where repository matches the given name with the values from the Repository struct
+// in the parameters. To check the updated values, all values in the Repository struct are overwritten with the response of the AWS SDK command (call by reference).
+// The version of the Repository gets incremented, if expectedVersion isn't AnyVersion the update is only applied if the stored version matches.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) UpdateSingleRepository(repository *types.Repository, name string, expectedVersion int64) error {
+	svc := getDynamoDbClient()
+
+	updateStruct := types.RepositoryUpdate{
+		Webhook:               repository.Webhook,
+		Filters:               repository.Filters,
+		PullRequestActions:    repository.PullRequestActions,
+		ShutdownSchedules:     repository.ShutdownSchedules,
+		StartupSchedules:      repository.StartupSchedules,
+		EnvironmentVariables:  repository.EnvironmentVariables,
+		InfrastructureRepoURL: repository.InfrastructureRepoURL,
+		CodeBuildRoleARN:      repository.CodeBuildRoleARN,
+	}
+
+	update, err := dynamodbattribute.MarshalMap(updateStruct)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/marshalUpdateMap"}, 0)
+		return err
+	}
+
+	names := map[string]*string{}
+	versionCondition := addVersionUpdate(names, update, expectedVersion)
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.RepositoriesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+		},
+		UpdateExpression:          aws.String("SET webhook = :webhook, filters = :filters, pullRequestActions = :pullRequestActions, shutdownSchedules = :shutdownSchedules, startupSchedules = :startupSchedules, environmentVariables = :environmentVariables, infrastructureRepoURL = :infrastructureRepoURL, codeBuildRoleARN = :codeBuildRoleARN, " + versionUpdateExpression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: update,
+		ConditionExpression:       aws.String("attribute_exists(repository)" + versionCondition),
+		ReturnValues:              aws.String("ALL_NEW"),
+	}
+
+	result, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, repository)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// SetRepositoryWebhookSecret sets the webhook secrets of the Repository in DynamoDB where repository matches the given name, empty secrets get removed.
+// The version of the Repository isn't changed, since the secrets aren't part of the Repository returned by the API.
+// If the Repository doesn't exist the condition fails with a ConditionalCheckFailedException, errors get logged and then returned.
+func (s *DynamoDBStore) SetRepositoryWebhookSecret(name string, secret string, previousSecret string, previousSecretExpiry string) error {
+	svc := getDynamoDbClient()
+
+	values := map[string]*dynamodb.AttributeValue{}
+	set := []string{}
+	remove := []string{}
+	attributes := []struct{ name, value string }{
+		{"webhookSecret", secret},
+		{"previousWebhookSecret", previousSecret},
+		{"previousSecretExpiry", previousSecretExpiry},
+	}
+	for _, attribute := range attributes {
+		if attribute.value == "" {
+			remove = append(remove, attribute.name)
+			continue
+		}
+		values[":"+attribute.name] = &dynamodb.AttributeValue{S: aws.String(attribute.value)}
+		set = append(set, attribute.name+" = :"+attribute.name)
+	}
+
+	update := ""
+	if len(set) > 0 {
+		update = "SET " + strings.Join(set, ", ")
+	}
+	if len(remove) > 0 {
+		update += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.RepositoriesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+		},
+		UpdateExpression:    aws.String(strings.TrimSpace(update)),
+		ConditionExpression: aws.String("attribute_exists(repository)"),
+	}
+	if len(values) > 0 {
+		input.ExpressionAttributeValues = values
+	}
+
+	_, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/SetRepositoryWebhookSecret", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteSingleRepository deletes an existing Repository in DynamoDB where repository matches the given name from the parameters.
+// To check the deleted repository, all values in the Repository struct are overwritten with the response of the AWS SDK command (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) DeleteSingleRepository(repository *types.Repository, name string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.RepositoriesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+		},
+		ReturnValues: aws.String("ALL_OLD"),
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/DeleteSingleRepository", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, repository)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/DeleteSingleRepository", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// GetEnvironmentsForRepositoryPage reads one page of Environments where the repository matches name (parameter) from DynamoDB, the received Environments are unmarshaled into
+// the array of Environments given in the parameters (call by reference).
+// A limit of 0 reads as many Environments as DynamoDB returns for a single Query, the cursor of the previous page continues the Query.
+// The cursor for the next page gets returned, if no more Environments exist the cursor is empty.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetEnvironmentsForRepositoryPage(environments *[]types.Environment, name string, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentsForRepositoryPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.EnvironmentsTable),
+		KeyConditionExpression: aws.String("#repository = :repository"),
+		ExpressionAttributeNames: map[string]*string{
+			"#repository": aws.String("repository"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":repository": {
+				S: aws.String(name),
+			},
+		},
+		ExclusiveStartKey: startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Query(input)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentsForRepositoryPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, environments)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentsForRepositoryPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// GetSingleEnvironmentForRepository gets the Environment where repository equals name and branch equals branch from DynamoDB,
+// the received Environment gets unmarshaled into the Environment struct given in the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetSingleEnvironmentForRepository(environment *types.Environment, name string, branch string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentForRepository", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, environment)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentForRepository", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// AddEnvironment adds a new Environment to the DynamoDB Table, the put is conditional on no Environment with the same repository and branch existing.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) AddEnvironment(environment *types.Environment) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(environment)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	input := &dynamodb.PutItemInput{
+		TableName:           aws.String(s.EnvironmentsTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(repository) AND attribute_not_exists(branch)"),
+	}
+
+	_, err = svc.PutItem(input)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateEnvironment updates an existing Environment in DynamoDB where repository equals name and branch equals branch, the updated values for the
+// Environment are in the EnvironmentPut struct.
+// The version of the Environment gets incremented, if expectedVersion isn't AnyVersion the update is only applied if the stored version matches.
+// If an error occurs the error gets logged and then returned. If no error occurs the updated Environment gets returned.
+func (s *DynamoDBStore) UpdateEnvironment(environment *types.EnvironmentPut, name string, branch string, expectedVersion int64) (types.Environment, error) {
+	svc := getDynamoDbClient()
+
+	updateStruct := types.EnvironmentUpdate{
+		InfrastructureRepoURL: environment.InfrastructureRepoURL,
+		ShutdownSchedules:     environment.ShutdownSchedules,
+		StartupSchedules:      environment.StartupSchedules,
+		CodeBuildRoleARN:      environment.CodeBuildRoleARN,
+		EnvironmentVariables:  environment.EnvironmentVariables,
+	}
+
+	update, err := dynamodbattribute.MarshalMap(updateStruct)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/marshalUpdateMap"}, 0)
+		return types.Environment{}, err
+	}
+
+	names := map[string]*string{}
+	versionCondition := addVersionUpdate(names, update, expectedVersion)
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+		UpdateExpression:          aws.String("SET shutdownSchedules = :shutdownSchedules, startupSchedules = :startupSchedules, environmentVariables = :environmentVariables, infrastructureRepoURL = :infrastructureRepoURL, codeBuildRoleARN = :codeBuildRoleARN, " + versionUpdateExpression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: update,
+		ConditionExpression:       aws.String("attribute_exists(repository) AND attribute_exists(branch)" + versionCondition),
+		ReturnValues:              aws.String("ALL_NEW"),
+	}
+
+	result, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "dynamodb/exec"}, 0)
+		return types.Environment{}, err
+	}
+
+	response := types.Environment{}
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, &response)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "dynamodb/unmarshalMap"}, 0)
+		return types.Environment{}, err
+	}
+
+	return response, nil
+}
+
+// SetEnvironmentPullRequest sets the pull request number and its author of the Environment in DynamoDB where repository equals name and branch equals branch.
+// The version of the Environment gets incremented, if expectedVersion isn't AnyVersion the update is only applied if the stored version matches.
+// If an error occurs the error gets logged and then returned. If no error occurs the updated Environment gets returned.
+func (s *DynamoDBStore) SetEnvironmentPullRequest(name string, branch string, pullRequest int, author string, expectedVersion int64) (types.Environment, error) {
+	svc := getDynamoDbClient()
+
+	names := map[string]*string{}
+	values := map[string]*dynamodb.AttributeValue{
+		":pullRequest": {
+			N: aws.String(strconv.Itoa(pullRequest)),
+		},
+		":pullRequestAuthor": {
+			S: aws.String(author),
+		},
+	}
+	versionCondition := addVersionUpdate(names, values, expectedVersion)
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+		UpdateExpression:          aws.String("SET pullRequest = :pullRequest, pullRequestAuthor = :pullRequestAuthor, " + versionUpdateExpression),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("attribute_exists(repository) AND attribute_exists(branch)" + versionCondition),
+		ReturnValues:              aws.String("ALL_NEW"),
+	}
+
+	result, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/SetEnvironmentPullRequest", "operation": "dynamodb/exec"}, 0)
+		return types.Environment{}, err
+	}
+
+	response := types.Environment{}
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, &response)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/SetEnvironmentPullRequest", "operation": "dynamodb/unmarshalMap"}, 0)
+		return types.Environment{}, err
+	}
+
+	return response, nil
+}
+
+// SetEnvironmentScheduleOffsets stores the time zone offsets the schedules of the Environment where repository equals name and branch equals branch were last sent to the Builder with.
+// The version of the Environment isn't changed, since the offsets aren't part of the Environment returned by the API.
+// If the Environment doesn't exist the condition fails with a ConditionalCheckFailedException, errors get logged and then returned.
+func (s *DynamoDBStore) SetEnvironmentScheduleOffsets(name string, branch string, offsets string) error {
+	svc := getDynamoDbClient()
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+		UpdateExpression:    aws.String("REMOVE scheduleOffsets"),
+		ConditionExpression: aws.String("attribute_exists(repository) AND attribute_exists(branch)"),
+	}
+	if offsets != "" {
+		input.UpdateExpression = aws.String("SET scheduleOffsets = :scheduleOffsets")
+		input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{
+			":scheduleOffsets": {
+				S: aws.String(offsets),
+			},
+		}
+	}
+
+	_, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/SetEnvironmentScheduleOffsets", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// TransitionEnvironmentStatus applies the StatusTransition to the Environment where repository equals name and branch equals branch,
+// the update is only applied if the current status is one of the From states (and the operation ID and the version match, if set in the StatusTransition).
+// The status information before the update gets returned.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) TransitionEnvironmentStatus(name string, branch string, transition StatusTransition) (types.EnvironmentStatus, error) {
+	svc := getDynamoDbClient()
+
+	values := map[string]*dynamodb.AttributeValue{
+		":to": {
+			S: aws.String(string(transition.To)),
+		},
+	}
+	set := []string{"#status = :to"}
+	remove := []string{}
+
+	condition := "attribute_exists(repository) AND attribute_exists(branch) AND #status IN ("
+	for i, state := range transition.From {
+		placeholder := ":from" + strconv.Itoa(i)
+		values[placeholder] = &dynamodb.AttributeValue{S: aws.String(string(state))}
+		if i > 0 {
+			condition += ", "
+		}
+		condition += placeholder
+	}
+	condition += ")"
+	if transition.OperationID != "" {
+		values[":operationId"] = &dynamodb.AttributeValue{S: aws.String(transition.OperationID)}
+		condition += " AND operationId = :operationId"
+	}
+	names := map[string]*string{
+		"#status": aws.String("status"),
+	}
+	if transition.CheckVersion {
+		names["#version"] = aws.String("version")
+		if transition.ExpectedVersion == 0 {
+			condition += " AND attribute_not_exists(#version)"
+		} else {
+			values[":expectedVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.FormatInt(transition.ExpectedVersion, 10))}
+			condition += " AND #version = :expectedVersion"
+		}
+	}
+
+	if transition.NewOperationID != "" {
+		values[":newOperationId"] = &dynamodb.AttributeValue{S: aws.String(transition.NewOperationID)}
+		set = append(set, "operationId = :newOperationId")
+	} else {
+		remove = append(remove, "operationId")
+	}
+	if transition.FailureReason != "" {
+		values[":failureReason"] = &dynamodb.AttributeValue{S: aws.String(transition.FailureReason)}
+		set = append(set, "failureReason = :failureReason")
+	} else {
+		remove = append(remove, "failureReason")
+	}
+	if transition.CommitSHA != "" {
+		values[":commitSha"] = &dynamodb.AttributeValue{S: aws.String(transition.CommitSHA)}
+		set = append(set, "commitSha = :commitSha")
+	}
+
+	update := "SET " + strings.Join(set, ", ")
+	if len(remove) > 0 {
+		update += " REMOVE " + strings.Join(remove, ", ")
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+		UpdateExpression:          aws.String(update),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String(condition),
+		ReturnValues:              aws.String("ALL_OLD"),
+	}
+
+	result, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/TransitionEnvironmentStatus", "operation": "dynamodb/exec"}, 0)
+		return types.EnvironmentStatus{}, err
+	}
+
+	previous := types.EnvironmentStatus{}
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, &previous)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/TransitionEnvironmentStatus", "operation": "dynamodb/unmarshalMap"}, 0)
+		return types.EnvironmentStatus{}, err
+	}
+
+	return previous, nil
+}
+
+// DeleteEnvironment removes the Environment where repository equals name and branch equals branch from DynamoDB, the item is only removed
+// if the current status is one of the from states and the stored operation ID matches operationID.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) DeleteEnvironment(name string, branch string, from []types.EnvironmentState, operationID string) error {
+	svc := getDynamoDbClient()
+
+	values := map[string]*dynamodb.AttributeValue{
+		":operationId": {
+			S: aws.String(operationID),
+		},
+	}
+	placeholders := []string{}
+	for i, state := range from {
+		placeholder := ":from" + strconv.Itoa(i)
+		values[placeholder] = &dynamodb.AttributeValue{S: aws.String(string(state))}
+		placeholders = append(placeholders, placeholder)
+	}
+
+	_, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"),
+		},
+		ExpressionAttributeValues: values,
+		ConditionExpression:       aws.String("#status IN (" + strings.Join(placeholders, ", ") + ") AND operationId = :operationId"),
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/DeleteEnvironment", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// CheckIfEnvironmentsForRepositoryExist checks if Environments in DynamoDB exist where repository equals name from the parameters. If Environments
+// were found then true gets returned, otherwise false.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) CheckIfEnvironmentsForRepositoryExist(name string) (bool, error) {
+	svc := getDynamoDbClient()
+
+	result, err := svc.Query(&dynamodb.QueryInput{
+		TableName:              aws.String(s.EnvironmentsTable),
+		KeyConditionExpression: aws.String("repository = :repository"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":repository": {
+				S: aws.String(name),
+			},
+		},
+		Limit: aws.Int64(1),
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/CheckIfEnvironmentsForRepositoryExist", "operation": "dynamodb/exec"}, 0)
+		return false, err
+	}
+
+	var environments []types.Environment
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &environments)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/CheckIfEnvironmentsForRepositoryExist", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return false, err
+	}
+
+	if len(environments) > 0 {
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// GetEnvironmentsStatusInformationPage reads the repository, branch, status and creationDate columns from one page of rows of the environments DynamoDB Table and writes
+// them to the Array of EnvironmentStatus structs given in the parameters (call by reference).
+// A limit of 0 reads as many rows as DynamoDB returns for a single Scan, the cursor of the previous page continues the Scan.
+// The cursor for the next page gets returned, if no more rows exist the cursor is empty.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetEnvironmentsStatusInformationPage(status *[]types.EnvironmentStatus, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentsStatusInformationPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"), // Workaround reserved keywoard issue
+		},
+		ProjectionExpression: aws.String("repository, branch, #status, creationDate, operationId, failureReason, commitSha"),
+		ExclusiveStartKey:    startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Scan(input)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentsStatusInformationPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, status)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentsStatusInformationPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// GetSingleEnvironmentStatusInformation reads the repository, branch, status and creationDate columns from the row of the environments DynamoDB Table where
+// repository and branch match the values given in the parameters. The result is written to the EnvironmentStatus struct from the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetSingleEnvironmentStatusInformation(status *types.EnvironmentStatus, name string, branch string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.EnvironmentsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"repository": {
+				S: aws.String(name),
+			},
+			"branch": {
+				S: aws.String(branch),
+			},
+		},
+		ExpressionAttributeNames: map[string]*string{
+			"#status": aws.String("status"), // Workaround reserved keywoard issue
+		},
+		ProjectionExpression: aws.String("repository, branch, #status, creationDate, operationId, failureReason, commitSha"),
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentStatusInformation", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, status)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentStatusInformation", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// GetGlobalRepositoryConfiguration reads the current global repository configuration from the DynamoDB Table and unmarshals it to the
+// GeneralConfig struct from the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetGlobalRepositoryConfiguration(configuration *types.GeneralConfig, stage string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.GlobalConfigTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"stage": {
+				S: aws.String(stage),
+			},
+		},
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetGlobalRepositoryConfiguration", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, configuration)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetGlobalRepositoryConfiguration", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// UpdateGlobalRepositoryConfiguration updates the global repository configuration in DynamoDB by using the AWS SDK with the values
+// from the GeneralConfig struct in the parameters, after the update all values in the GeneralConfig struct are overwritten with the AWS command results.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) UpdateGlobalRepositoryConfiguration(configuration *types.GeneralConfig, stage string) error {
+	svc := getDynamoDbClient()
+
+	updateStruct := types.GeneralConfigUpdate{
+		ShutdownSchedules:    configuration.ShutdownSchedules,
+		StartupSchedules:     configuration.StartupSchedules,
+		EnvironmentVariables: configuration.EnvironmentVariables,
+	}
+
+	update, err := dynamodbattribute.MarshalMap(updateStruct)
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateGlobalRepositoryConfiguration", "operation": "dynamodb/marshalUpdateMap"}, 0)
+		return err
+	}
+
+	input := &dynamodb.UpdateItemInput{
+		TableName: aws.String(s.GlobalConfigTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"stage": {
+				S: aws.String(stage),
+			},
+		},
+		UpdateExpression:          aws.String("SET shutdownSchedules = :shutdownSchedules, startupSchedules = :startupSchedules, environmentVariables = :environmentVariables"),
+		ExpressionAttributeValues: update,
+		ReturnValues:              aws.String("ALL_NEW"),
+	}
+
+	result, err := svc.UpdateItem(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateGlobalRepositoryConfiguration", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, configuration)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetGlobalRepositoryConfiguration", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// AddEnvironmentHistoryEntry stores the EnvironmentHistoryEntry from the parameters in the DynamoDB history Table.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) AddEnvironmentHistoryEntry(entry *types.EnvironmentHistoryEntry) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentHistoryEntry", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.HistoryTable),
+		Item:      av,
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentHistoryEntry", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// GetEnvironmentHistoryPage reads one page of the history of the Environment where repository equals name and branch equals branch from DynamoDB,
+// newest entries first. The entries are unmarshaled into the array of EnvironmentHistoryEntry structs from the parameters (call by reference).
+// The cursor for the next page gets returned, if no more entries exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func (s *DynamoDBStore) GetEnvironmentHistoryPage(entries *[]types.EnvironmentHistoryEntry, name string, branch string, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentHistoryPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.HistoryTable),
+		KeyConditionExpression: aws.String("#environment = :environment"),
+		ExpressionAttributeNames: map[string]*string{
+			"#environment": aws.String("environment"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":environment": {
+				S: aws.String(historyKey(name, branch)),
+			},
+		},
+		ScanIndexForward:  aws.Bool(false),
+		ExclusiveStartKey: startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Query(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentHistoryPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, entries)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentHistoryPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// AddAuditEntry writes the AuditEntry to the DynamoDB audit Table.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) AddAuditEntry(entry *types.AuditEntry) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddAuditEntry", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.AuditTable),
+		Item:      av,
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddAuditEntry", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// GetAuditEntriesPage reads one page of the audit entries matching the AuditFilter from DynamoDB and unmarshals them into the array of AuditEntry structs
+// from the parameters (call by reference). If the filter is restricted to a single scope the scope gets queried newest entries first, otherwise the Table gets scanned.
+// Entries not matching the filter are removed by DynamoDB, so a page can contain less than limit entries even if more entries exist.
+// The cursor for the next page gets returned, if no more entries exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func (s *DynamoDBStore) GetAuditEntriesPage(entries *[]types.AuditEntry, filter AuditFilter, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetAuditEntriesPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	from, to := filter.timeBounds()
+	names := map[string]*string{
+		"#time": aws.String("time"),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":from": {S: aws.String(from)},
+		":to":   {S: aws.String(to)},
+	}
+	conditions := []string{}
+	addCondition := func(attribute string, value string) {
+		if value == "" {
+			return
+		}
+		names["#"+attribute] = aws.String(attribute)
+		values[":"+attribute] = &dynamodb.AttributeValue{S: aws.String(value)}
+		conditions = append(conditions, "#"+attribute+" = :"+attribute)
+	}
+	addCondition("target", filter.Target)
+	addCondition("branch", filter.Branch)
+
+	var items []map[string]*dynamodb.AttributeValue
+	var lastKey map[string]*dynamodb.AttributeValue
+	if scope := filter.scope(); scope != "" {
+		names["#scope"] = aws.String("scope")
+		values[":scope"] = &dynamodb.AttributeValue{S: aws.String(scope)}
+		input := &dynamodb.QueryInput{
+			TableName:                 aws.String(s.AuditTable),
+			KeyConditionExpression:    aws.String("#scope = :scope AND #time BETWEEN :from AND :to"),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ScanIndexForward:          aws.Bool(false),
+			ExclusiveStartKey:         startKey,
+		}
+		if len(conditions) > 0 {
+			input.FilterExpression = aws.String(strings.Join(conditions, " AND "))
+		}
+		if limit > 0 {
+			input.Limit = aws.Int64(limit)
+		}
+
+		result, err := svc.Query(input)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/GetAuditEntriesPage", "operation": "dynamodb/query"}, 0)
+			return "", err
+		}
+		items, lastKey = result.Items, result.LastEvaluatedKey
+	} else {
+		addCondition("repository", filter.Repository)
+		conditions = append([]string{"#time BETWEEN :from AND :to"}, conditions...)
+		input := &dynamodb.ScanInput{
+			TableName:                 aws.String(s.AuditTable),
+			FilterExpression:          aws.String(strings.Join(conditions, " AND ")),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			ExclusiveStartKey:         startKey,
+		}
+		if limit > 0 {
+			input.Limit = aws.Int64(limit)
+		}
+
+		result, err := svc.Scan(input)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/GetAuditEntriesPage", "operation": "dynamodb/scan"}, 0)
+			return "", err
+		}
+		items, lastKey = result.Items, result.LastEvaluatedKey
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(items, entries)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetAuditEntriesPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(lastKey)
+}
+
+// GetRoleBindingsPage reads one page of RoleBindings from the DynamoDB Table and unmarshals them into the array of RoleBinding structs from the parameters (call by reference).
+// The cursor for the next page gets returned, if no more RoleBindings exist the cursor is empty.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetRoleBindingsPage(bindings *[]types.RoleBinding, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetRoleBindingsPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(s.RoleBindingsTable),
+		ExclusiveStartKey: startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Scan(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetRoleBindingsPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, bindings)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetRoleBindingsPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// GetRoleBindingsForIdentity reads all RoleBindings of the identity from DynamoDB and unmarshals them into the array of RoleBinding structs from the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetRoleBindingsForIdentity(bindings *[]types.RoleBinding, identity string) error {
+	svc := getDynamoDbClient()
+
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(s.RoleBindingsTable),
+		KeyConditionExpression: aws.String("#identity = :identity"),
+		ExpressionAttributeNames: map[string]*string{
+			"#identity": aws.String("identity"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":identity": {
+				S: aws.String(identity),
+			},
+		},
+	}
+
+	all := []types.RoleBinding{}
+	for {
+		result, err := svc.Query(input)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/GetRoleBindingsForIdentity", "operation": "dynamodb/exec"}, 0)
+			return err
+		}
+
+		page := []types.RoleBinding{}
+		err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &page)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/GetRoleBindingsForIdentity", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+			return err
+		}
+		all = append(all, page...)
+
+		if len(result.LastEvaluatedKey) == 0 {
+			break
+		}
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+	}
+
+	*bindings = all
+	return nil
+}
+
+// PutRoleBinding writes the RoleBinding to DynamoDB, an existing RoleBinding of the identity for the same repository gets replaced.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) PutRoleBinding(binding *types.RoleBinding) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(binding)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/PutRoleBinding", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.RoleBindingsTable),
+		Item:      av,
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/PutRoleBinding", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// DeleteRoleBinding deletes the RoleBinding of the identity for the repository from DynamoDB, the deleted RoleBinding gets unmarshaled into the RoleBinding struct
+// from the parameters (call by reference). If the RoleBinding didn't exist the struct stays untouched.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) DeleteRoleBinding(binding *types.RoleBinding, identity string, repository string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(s.RoleBindingsTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"identity": {
+				S: aws.String(identity),
+			},
+			"repository": {
+				S: aws.String(repository),
+			},
+		},
+		ReturnValues: aws.String("ALL_OLD"),
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/DeleteRoleBinding", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, binding)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/DeleteRoleBinding", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// GetAPIKeysPage reads one page of APIKeys from the DynamoDB Table and unmarshals them into the array of APIKey structs from the parameters (call by reference).
+// The cursor for the next page gets returned, if no more APIKeys exist the cursor is empty.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetAPIKeysPage(keys *[]types.APIKey, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetAPIKeysPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(s.APIKeysTable),
+		ExclusiveStartKey: startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Scan(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetAPIKeysPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, keys)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetAPIKeysPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// GetSingleAPIKey reads the APIKey where id matches the id given in the parameters from DynamoDB and unmarshals it into the APIKey struct from the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetSingleAPIKey(key *types.APIKey, id string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.APIKeysTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleAPIKey", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, key)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleAPIKey", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// AddAPIKey writes a new APIKey to DynamoDB, if an APIKey with the same id exists the condition fails.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) AddAPIKey(key *types.APIKey) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(key)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddAPIKey", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(s.APIKeysTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddAPIKey", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// RevokeAPIKey marks the APIKey where id matches the id given in the parameters as revoked, the revocation date of an already revoked APIKey stays untouched.
+// The updated APIKey gets unmarshaled into the APIKey struct from the parameters (call by reference), if the APIKey doesn't exist the condition fails.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) RevokeAPIKey(key *types.APIKey, id string, revocationDate string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.UpdateItem(&dynamodb.UpdateItemInput{
+		TableName: aws.String(s.APIKeysTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+		UpdateExpression:    aws.String("SET revoked = :revoked, revocationDate = if_not_exists(revocationDate, :revocationDate)"),
+		ConditionExpression: aws.String("attribute_exists(id)"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":revoked": {
+				BOOL: aws.Bool(true),
+			},
+			":revocationDate": {
+				S: aws.String(revocationDate),
+			},
+		},
+		ReturnValues: aws.String("ALL_NEW"),
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/RevokeAPIKey", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Attributes, key)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/RevokeAPIKey", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveriesPage reads one page of WebhookDeliveries from the DynamoDB Table and unmarshals them into the array of WebhookDelivery structs from the parameters (call by reference).
+// The cursor for the next page gets returned, if no more WebhookDeliveries exist the cursor is empty.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetWebhookDeliveriesPage(deliveries *[]types.WebhookDelivery, limit int64, cursor string) (string, error) {
+	svc := getDynamoDbClient()
+
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetWebhookDeliveriesPage", "operation": "decodeCursor"}, 1)
+		return "", err
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName:         aws.String(s.DeliveriesTable),
+		ExclusiveStartKey: startKey,
+	}
+	if limit > 0 {
+		input.Limit = aws.Int64(limit)
+	}
+
+	result, err := svc.Scan(input)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetWebhookDeliveriesPage", "operation": "dynamodb/exec"}, 0)
+		return "", err
+	}
+
+	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, deliveries)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetWebhookDeliveriesPage", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
+		return "", err
+	}
+
+	return encodeCursor(result.LastEvaluatedKey)
+}
+
+// GetSingleWebhookDelivery reads the WebhookDelivery where id matches the id given in the parameters from DynamoDB and unmarshals it into the WebhookDelivery struct from the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) GetSingleWebhookDelivery(delivery *types.WebhookDelivery, id string) error {
+	svc := getDynamoDbClient()
+
+	result, err := svc.GetItem(&dynamodb.GetItemInput{
+		TableName: aws.String(s.DeliveriesTable),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {
+				S: aws.String(id),
+			},
+		},
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleWebhookDelivery", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	err = dynamodbattribute.UnmarshalMap(result.Item, delivery)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetSingleWebhookDelivery", "operation": "dynamodb/unmarshalMap"}, 0)
+		return err
+	}
+
+	return nil
+}
+
+// AddWebhookDelivery writes a new WebhookDelivery to DynamoDB, if a WebhookDelivery with the same id exists the condition fails.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) AddWebhookDelivery(delivery *types.WebhookDelivery) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/AddWebhookDelivery", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(s.DeliveriesTable),
+		Item:                av,
+		ConditionExpression: aws.String("attribute_not_exists(id)"),
+	})
+	if err != nil {
+		// A duplicate delivery is expected, so it's only logged as warning
+		level := 0
+		if IsConditionalCheckFailed(err) {
+			level = 1
+		}
+		config.Logger.Log(err, map[string]string{"module": "model/AddWebhookDelivery", "operation": "dynamodb/exec"}, level)
+		return err
+	}
+
+	return nil
+}
+
+// RetryWebhookDelivery overwrites the WebhookDelivery in DynamoDB with the WebhookDelivery from the parameters, if the stored WebhookDelivery
+// still has the receive time previousTime. So only one of multiple concurrent redeliveries processes the delivery again, the others fail the condition.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) RetryWebhookDelivery(delivery *types.WebhookDelivery, previousTime string) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/RetryWebhookDelivery", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName:           aws.String(s.DeliveriesTable),
+		Item:                av,
+		ConditionExpression: aws.String("#time = :previousTime"),
+		ExpressionAttributeNames: map[string]*string{
+			"#time": aws.String("time"),
+		},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":previousTime": {
+				S: aws.String(previousTime),
+			},
+		},
+	})
+	if err != nil {
+		level := 0
+		if IsConditionalCheckFailed(err) {
+			level = 1
+		}
+		config.Logger.Log(err, map[string]string{"module": "model/RetryWebhookDelivery", "operation": "dynamodb/exec"}, level)
+		return err
+	}
+
+	return nil
+}
+
+// PutWebhookDelivery overwrites the WebhookDelivery in DynamoDB with the WebhookDelivery from the parameters.
+// If an error occurs the error gets logged and then returned.
+func (s *DynamoDBStore) PutWebhookDelivery(delivery *types.WebhookDelivery) error {
+	svc := getDynamoDbClient()
+
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/PutWebhookDelivery", "operation": "dynamodb/marshalMap"}, 0)
+		return err
+	}
+
+	_, err = svc.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(s.DeliveriesTable),
+		Item:      av,
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/PutWebhookDelivery", "operation": "dynamodb/exec"}, 0)
+		return err
+	}
+
+	return nil
+}
@@ -6,100 +6,79 @@ import (
 	"fmt"
 	"time"
 
-	"github.com/aws/aws-sdk-go/service/lambda"
-
 	"github.com/auto-staging/tower/config"
 	"github.com/auto-staging/tower/types"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
-// GetAllEnvironmentsForRepository gets all Environments where the repository matches name (parameter) from DynamoDB, the received Environments are unmarshaled into
+// GetAllEnvironmentsForRepository gets all Environments where the repository matches name (parameter) from the Store, the received Environments are written into
 // the array of Environments given in the parameters (call by reference).
+// All pages are read, so the result isn't truncated for repositories with many Environments.
 // If an error occurs the error gets logged and then returned.
 func GetAllEnvironmentsForRepository(environments *[]types.Environment, name string) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.Query(&dynamodb.QueryInput{
-		TableName:              aws.String("auto-staging-environments"),
-		KeyConditionExpression: aws.String("#repository = :repository"),
-		ExpressionAttributeNames: map[string]*string{
-			"#repository": aws.String("repository"),
-		},
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":repository": {
-				S: aws.String(name),
-			},
-		},
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetAllEnvironmentsForRepository", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, environments)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetAllEnvironmentsForRepository", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
-		return err
+	all := []types.Environment{}
+	cursor := ""
+	for {
+		page := []types.Environment{}
+		next, err := store.GetEnvironmentsForRepositoryPage(&page, name, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
 	}
 
+	setAllowedActions(all)
+	*environments = all
 	return nil
 }
 
-// GetSingleEnvironmentForRepository gets the Environment where repository equals name and branch equals branch from DynamoDB,
-// the received Environment gets unmarshaled into the Environment struct given in the parameters (call by reference).
+// GetEnvironmentsForRepositoryPage reads up to limit Environments of the repository matching name starting at the cursor from the Store,
+// the received Environments are written into the array of Environments given in the parameters (call by reference).
+// The cursor for the next page gets returned, if no more Environments exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetEnvironmentsForRepositoryPage(environments *[]types.Environment, name string, limit int64, cursor string) (string, error) {
+	next, err := store.GetEnvironmentsForRepositoryPage(environments, name, limit, cursor)
+	setAllowedActions(*environments)
+	return next, err
+}
+
+// GetSingleEnvironmentForRepository gets the Environment where repository equals name and branch equals branch from the Store,
+// the received Environment gets written into the Environment struct given in the parameters (call by reference).
 // If an error occurs the error gets logged and then returned.
 func GetSingleEnvironmentForRepository(environment *types.Environment, name string, branch string) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.GetItem(&dynamodb.GetItemInput{
-		TableName: aws.String("auto-staging-environments"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"repository": {
-				S: aws.String(name),
-			},
-			"branch": {
-				S: aws.String(branch),
-			},
-		},
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentForRepository", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalMap(result.Item, environment)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentForRepository", "operation": "dynamodb/unmarshalMap"}, 0)
-		return err
+	err := store.GetSingleEnvironmentForRepository(environment, name, branch)
+	if environment.Repository != "" {
+		environment.AllowedActions = environment.Status.AllowedActions()
 	}
-
-	return nil
+	return err
 }
 
 // AddEnvironmentForRepository adds a new Environment for the repository given in the parameters, the values for the new Environment are
 // in the EnvironmentPost struct.
 // If some values are unset, they will be set with the defaults from the repository.
-// After successfully adding the new Environment to DynamoDB, the Builder Lambda gets invoked to add the Schedules and the CodeBuild Job.
+// The schedules are translated into UTC before the Environment is stored, invalid schedules return an error without storing anything.
+// After successfully adding the new Environment to the Store, the status gets changed to "initiating" and the Builder gets invoked to add the Schedules and the CodeBuild Job.
+// If anything fails after the Environment was stored, the status is set to "initiating failed" so the Environment can be destroyed.
 // If an error occurs the error gets logged and then returned. If no error occurs the newly created Environment gets returned.
-func AddEnvironmentForRepository(environment types.EnvironmentPost, name string) (types.Environment, error) {
-	svc := getDynamoDbClient()
-
+func AddEnvironmentForRepository(environment types.EnvironmentPost, name string, actor string) (types.Environment, error) {
 	creation := time.Now().UTC()
 
 	inputEnvironment := types.Environment{
 		Repository:            name,
 		Branch:                environment.Branch,
-		Status:                "pending",
+		Status:                types.EnvironmentStatePending,
 		CreationDate:          creation.String(),
 		InfrastructureRepoURL: environment.InfrastructureRepoURL,
 		ShutdownSchedules:     environment.ShutdownSchedules,
 		StartupSchedules:      environment.StartupSchedules,
 		EnvironmentVariables:  environment.EnvironmentVariables,
 		CodeBuildRoleARN:      environment.CodeBuildRoleARN,
+		PullRequest:           environment.PullRequest,
+		PullRequestAuthor:     environment.PullRequestAuthor,
+		Version:               1,
 	}
 
 	// Overwrite unset values with defaults from the parent repository
@@ -132,190 +111,284 @@ func AddEnvironmentForRepository(environment types.EnvironmentPost, name string)
 		}
 	}
 
-	av, err := dynamodbattribute.MarshalMap(inputEnvironment)
+	// The schedules are translated before the Environment is stored, so invalid schedules don't leave an Environment behind
+	scheduleEvent, offsets, err := scheduleUpdateEvent(inputEnvironment.Repository, inputEnvironment.Branch, inputEnvironment.StartupSchedules, inputEnvironment.ShutdownSchedules, creation)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "dynamodb/marshalMap"}, 0)
 		return types.Environment{}, err
 	}
+	inputEnvironment.ScheduleOffsets = offsets
 
-	input := &dynamodb.PutItemInput{
-		TableName:           aws.String("auto-staging-environments"),
-		Item:                av,
-		ConditionExpression: aws.String("attribute_not_exists(repository) AND attribute_not_exists(branch)"),
-	}
-
-	_, err = svc.PutItem(input)
-
+	err = store.AddEnvironment(&inputEnvironment)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "dynamodb/exec"}, 0)
 		return types.Environment{}, err
 	}
+	recordEnvironmentHistory(types.EnvironmentHistoryEntry{
+		Repository: inputEnvironment.Repository,
+		Branch:     inputEnvironment.Branch,
+		Action:     types.EnvironmentActionCreate,
+		To:         inputEnvironment.Status,
+		Actor:      actor,
+	})
 
-	// Invoke Builder Lambda to configure schedules
-	event := types.BuilderEvent{
-		Operation:         "UPDATE_SCHEDULE",
-		Branch:            inputEnvironment.Branch,
-		Repository:        inputEnvironment.Repository,
-		ShutdownSchedules: inputEnvironment.ShutdownSchedules,
-		StartupSchedules:  inputEnvironment.StartupSchedules,
-	}
-	body, err := json.Marshal(event)
+	// A pending Environment allows no action, so every failure from now on is recorded as failed creation to allow destroying the Environment
+	_, operationID, err := claimEnvironmentTransition(inputEnvironment.Repository, inputEnvironment.Branch, types.EnvironmentActionCreate, actor)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "builder/marshalSchedule"}, 0)
+		failEnvironmentCreation(inputEnvironment.Repository, inputEnvironment.Branch, "", "Status change failed: "+err.Error(), actor)
 		return types.Environment{}, err
 	}
+	inputEnvironment.Status = types.EnvironmentTransitions[types.EnvironmentActionCreate].To
+	inputEnvironment.OperationID = operationID
 
-	client := getLambdaClient()
-	_, err = client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-builder"),
-		InvocationType: aws.String("Event"),
-		Payload:        body,
-	})
-
+	// Invoke Builder to configure schedules
+	err = invokeScheduleUpdate(scheduleEvent)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "builder/invokeSchedule"}, 0)
+		failEnvironmentCreation(inputEnvironment.Repository, inputEnvironment.Branch, operationID, "Builder invocation failed: "+err.Error(), actor)
 		return types.Environment{}, err
 	}
 
-	// Invoke Builder Lambda to generate environment
-	event = types.BuilderEvent{
+	// Invoke Builder to generate environment
+	event := types.BuilderEvent{
 		Operation:             "CREATE",
 		Branch:                inputEnvironment.Branch,
 		Repository:            inputEnvironment.Repository,
 		CodeBuildRoleARN:      inputEnvironment.CodeBuildRoleARN,
 		EnvironmentVariables:  inputEnvironment.EnvironmentVariables,
 		InfrastructureRepoURL: inputEnvironment.InfrastructureRepoURL,
+		OperationID:           operationID,
 	}
-	body, err = json.Marshal(event)
+	body, err := json.Marshal(event)
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "builder/marshal"}, 0)
+		failEnvironmentCreation(inputEnvironment.Repository, inputEnvironment.Branch, operationID, "Builder event failed: "+err.Error(), actor)
 		return types.Environment{}, err
 	}
 
-	_, err = client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-builder"),
-		InvocationType: aws.String("Event"),
-		Payload:        body,
-	})
+	_, err = invoker.Invoke(BuilderComponent, InvocationTypeEvent, body)
 
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/AddEnvironmentForRepositroy", "operation": "builder/invoke"}, 0)
+		failEnvironmentCreation(inputEnvironment.Repository, inputEnvironment.Branch, operationID, "Builder invocation failed: "+err.Error(), actor)
 		return types.Environment{}, err
 	}
 
+	inputEnvironment.AllowedActions = inputEnvironment.Status.AllowedActions()
 	return inputEnvironment, nil
 }
 
-// UpdateEnvironment updates an existing Environment in DynamoDB where repository equals name and branch equals branch, the updated values for the
+// storeUpdateAttempts is the number of attempts to store the configuration of an Environment after the Builder was invoked with it,
+// storeUpdateRetryDelay is the delay before the next attempt (multiplied by the number of failed attempts).
+const storeUpdateAttempts = 3
+
+var storeUpdateRetryDelay = 100 * time.Millisecond
+
+// UpdateEnvironment updates an existing Environment in the Store where repository equals name and branch equals branch, the updated values for the
 // Environment are in the EnvironmentPut struct.
-// After successfully updating the Environment in DynamoDB, the Builder Lambda gets invoked to update the Schedules and the CodeBuild Job.
-// If an error occurs the error gets logged and then returned. If no error occurs the updated Environment gets returned.
-func UpdateEnvironment(environment *types.EnvironmentPut, name string, branch string) (types.Environment, error) {
-	svc := getDynamoDbClient()
+// Before the update the status gets changed to "updating" atomically, if the status doesn't allow the update anymore ErrStatusConflict gets returned.
+// If expectedVersion isn't AnyVersion the status change is conditional on the version as well, if the stored Environment has another version
+// ErrVersionMismatch gets returned before anything is changed.
+// The Builder gets invoked to update the Schedules and the CodeBuild Job, the new configuration is only stored after the Builder was invoked successfully.
+// If an error occurs the status (and the schedules sent to the Builder) get restored and the error gets logged and then returned.
+// Once the Builder was invoked nothing is restored, storing the new configuration is retried storeUpdateAttempts times instead.
+// If no error occurs the updated Environment gets returned.
+func UpdateEnvironment(environment *types.EnvironmentPut, name string, branch string, expectedVersion int64, actor string) (types.Environment, error) {
+	previous, operationID, err := claimVersionedEnvironmentTransition(name, branch, types.EnvironmentActionUpdate, "", expectedVersion, actor)
+	if err != nil {
+		return types.Environment{}, err
+	}
 
-	updateStruct := types.EnvironmentUpdate{
+	// The claim blocks other updates, so the stored configuration stays unchanged until the new configuration gets stored
+	stored := types.Environment{}
+	schedulesSent := false
+	invoked := false
+	defer func() {
+		if invoked {
+			return
+		}
+		if schedulesSent {
+			// The Builder gets the schedules of the unchanged configuration again
+			sendScheduleUpdate(name, branch, stored.StartupSchedules, stored.ShutdownSchedules, time.Now())
+		}
+		rollbackEnvironmentTransition(name, branch, types.EnvironmentActionUpdate, operationID, previous, actor)
+	}()
+
+	err = store.GetSingleEnvironmentForRepository(&stored, name, branch)
+	if err != nil {
+		return types.Environment{}, err
+	}
+
+	// Invoke Builder to configure schedules
+	offsets, err := sendScheduleUpdate(name, branch, environment.StartupSchedules, environment.ShutdownSchedules, time.Now())
+	if err != nil {
+		return types.Environment{}, err
+	}
+	schedulesSent = true
+
+	// Invoke Builder to update environment
+	event := types.BuilderEvent{
+		Operation:             "UPDATE",
+		Branch:                branch,
+		Repository:            name,
 		InfrastructureRepoURL: environment.InfrastructureRepoURL,
-		ShutdownSchedules:     environment.ShutdownSchedules,
-		StartupSchedules:      environment.StartupSchedules,
 		CodeBuildRoleARN:      environment.CodeBuildRoleARN,
 		EnvironmentVariables:  environment.EnvironmentVariables,
+		OperationID:           operationID,
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "builder/marshal"}, 0)
+		return types.Environment{}, err
 	}
 
-	update, err := dynamodbattribute.MarshalMap(updateStruct)
+	_, err = invoker.Invoke(BuilderComponent, InvocationTypeEvent, body)
 
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/marshalUpdateMap"}, 0)
+		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "builder/invoke"}, 0)
 		return types.Environment{}, err
 	}
+	invoked = true
 
-	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String("auto-staging-environments"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"repository": {
-				S: aws.String(name),
-			},
-			"branch": {
-				S: aws.String(branch),
-			},
-		},
-		UpdateExpression:          aws.String("SET shutdownSchedules = :shutdownSchedules, startupSchedules = :startupSchedules, environmentVariables = :environmentVariables, infrastructureRepoURL = :infrastructureRepoURL, codeBuildRoleARN = :codeBuildRoleARN"),
-		ExpressionAttributeValues: update,
-		ConditionExpression:       aws.String("attribute_exists(repository) AND attribute_exists(branch)"),
-		ReturnValues:              aws.String("ALL_NEW"),
+	// The version was checked by the claim, so the update doesn't check it again. The Builder already deploys the new configuration,
+	// so the write is retried and a configuration which can't be stored is logged with the operation ID to repair the Environment manually
+	var response types.Environment
+	for attempt := 1; attempt <= storeUpdateAttempts; attempt++ {
+		response, err = store.UpdateEnvironment(environment, name, branch, AnyVersion)
+		if err == nil || IsConditionalCheckFailed(err) {
+			break
+		}
+		if attempt < storeUpdateAttempts {
+			time.Sleep(time.Duration(attempt) * storeUpdateRetryDelay)
+		}
 	}
-
-	result, err := svc.UpdateItem(input)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "dynamodb/exec"}, 0)
+		config.Logger.Log(errors.New("Configuration of operation "+operationID+" isn't stored, the Builder deploys it anyway: "+err.Error()), map[string]string{"module": "model/UpdateEnvironment", "operation": "store/update"}, 0)
 		return types.Environment{}, err
 	}
+	if offsets != response.ScheduleOffsets {
+		// If the offsets can't be stored the next time zone refresh sends the schedules again
+		store.SetEnvironmentScheduleOffsets(name, branch, offsets)
+		response.ScheduleOffsets = offsets
+	}
 
-	// Invoke Builder Lambda to configure schedules
-	event := types.BuilderEvent{
-		Operation:         "UPDATE_SCHEDULE",
-		Branch:            branch,
-		Repository:        name,
-		ShutdownSchedules: environment.ShutdownSchedules,
-		StartupSchedules:  environment.StartupSchedules,
+	response.AllowedActions = response.Status.AllowedActions()
+	return response, nil
+}
+
+// SetEnvironmentPullRequest sets the pull request number and its author of the existing Environment where repository equals name and branch equals branch,
+// e.g. if the Environment was created for the branch before the pull request was opened.
+// The update is conditional on the version read before, if the Environment changed in between it's read and updated again up to storeUpdateAttempts times,
+// afterwards ErrVersionMismatch gets returned. If the Environment doesn't exist a ConditionalCheckFailedException gets returned.
+// If an error occurs the error gets logged and then returned. If no error occurs the updated Environment gets returned.
+func SetEnvironmentPullRequest(name string, branch string, pullRequest int, author string) (types.Environment, error) {
+	for attempt := 1; attempt <= storeUpdateAttempts; attempt++ {
+		stored := types.Environment{}
+		err := store.GetSingleEnvironmentForRepository(&stored, name, branch)
+		if err != nil {
+			return types.Environment{}, err
+		}
+		expectedVersion := stored.Version
+		if stored.Repository == "" {
+			// The update fails its exists condition like any update of a missing Environment
+			expectedVersion = AnyVersion
+		}
+
+		response, err := store.SetEnvironmentPullRequest(name, branch, pullRequest, author, expectedVersion)
+		if err == nil {
+			response.AllowedActions = response.Status.AllowedActions()
+			return response, nil
+		}
+		if !IsConditionalCheckFailed(err) || expectedVersion == AnyVersion {
+			return types.Environment{}, err
+		}
 	}
-	body, err := json.Marshal(event)
+
+	return types.Environment{}, ErrVersionMismatch
+}
+
+// DeployEnvironmentCommit invokes the Builder to update the Environment where repository equals name and branch equals branch to the commit with the commitSHA,
+// the configuration of the Environment stays unchanged. Before invoking the Builder the status gets changed to "updating" atomically and the commitSHA is stored
+// as commit of the Environment, if the status doesn't allow the update anymore ErrStatusConflict gets returned.
+// If an error occurs the status and the commit get restored and the error gets logged and then returned. If no error occurs the updated Environment gets returned.
+func DeployEnvironmentCommit(name string, branch string, commitSHA string, actor string) (types.Environment, error) {
+	environment := types.Environment{}
+	err := store.GetSingleEnvironmentForRepository(&environment, name, branch)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "builder/marshalSchedule"}, 0)
 		return types.Environment{}, err
 	}
 
-	client := getLambdaClient()
-	_, err = client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-builder"),
-		InvocationType: aws.String("Event"),
-		Payload:        body,
-	})
-
+	previous, operationID, err := claimEnvironmentCommitTransition(name, branch, types.EnvironmentActionUpdate, commitSHA, actor)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "builder/invokeSchedule"}, 0)
 		return types.Environment{}, err
 	}
+	invoked := false
+	defer func() {
+		if !invoked {
+			rollbackEnvironmentTransition(name, branch, types.EnvironmentActionUpdate, operationID, previous, actor)
+		}
+	}()
 
-	// Invoke Builder Lambda to update environment
-	event = types.BuilderEvent{
+	// Invoke Builder to update environment
+	event := types.BuilderEvent{
 		Operation:             "UPDATE",
 		Branch:                branch,
 		Repository:            name,
 		InfrastructureRepoURL: environment.InfrastructureRepoURL,
 		CodeBuildRoleARN:      environment.CodeBuildRoleARN,
 		EnvironmentVariables:  environment.EnvironmentVariables,
+		OperationID:           operationID,
+		CommitSHA:             commitSHA,
 	}
-	body, err = json.Marshal(event)
+	body, err := json.Marshal(event)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "builder/marshal"}, 0)
+		config.Logger.Log(err, map[string]string{"module": "model/DeployEnvironmentCommit", "operation": "builder/marshal"}, 0)
 		return types.Environment{}, err
 	}
 
-	_, err = client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-builder"),
-		InvocationType: aws.String("Event"),
-		Payload:        body,
-	})
+	_, err = invoker.Invoke(BuilderComponent, InvocationTypeEvent, body)
 
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "builder/invoke"}, 0)
+		config.Logger.Log(err, map[string]string{"module": "model/DeployEnvironmentCommit", "operation": "builder/invoke"}, 0)
 		return types.Environment{}, err
 	}
+	invoked = true
 
-	response := types.Environment{}
-	err = dynamodbattribute.UnmarshalMap(result.Attributes, &response)
+	environment.Status = types.EnvironmentTransitions[types.EnvironmentActionUpdate].To
+	environment.OperationID = operationID
+	environment.FailureReason = ""
+	environment.CommitSHA = commitSHA
+	environment.AllowedActions = environment.Status.AllowedActions()
+	return environment, nil
+}
+
+// DeleteSingleEnvironment invokes the Builder to delete the schedules and the CodeBuild Job with the infrastructure.
+// Before invoking the Builder the status gets changed to "destroying" atomically, if the status doesn't allow the deletion anymore ErrStatusConflict gets returned.
+// If an error occurs the status gets restored and the error gets logged and then returned. If the schedules were already deleted, the Builder gets them again.
+func DeleteSingleEnvironment(name string, branch string, actor string) error {
+	previous, operationID, err := claimEnvironmentTransition(name, branch, types.EnvironmentActionDestroy, actor)
 	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateEnvironment", "operation": "builder/unmarshalMap"}, 0)
-		return types.Environment{}, err
+		return err
 	}
 
-	return response, nil
-}
+	// The claim blocks updates, so the stored schedules stay unchanged until the Environment gets destroyed
+	stored := types.Environment{}
+	schedulesDeleted := false
+	invoked := false
+	defer func() {
+		if invoked {
+			return
+		}
+		if schedulesDeleted {
+			// The Environment stays, so the Builder gets its schedules again
+			sendScheduleUpdate(name, branch, stored.StartupSchedules, stored.ShutdownSchedules, time.Now())
+		}
+		rollbackEnvironmentTransition(name, branch, types.EnvironmentActionDestroy, operationID, previous, actor)
+	}()
 
-// DeleteSingleEnvironment invokes the Builder Lambda to delete the schedules and the CodeBuild Job with the infrastructure.
-// If an error occurs the error gets logged and then returned.
-func DeleteSingleEnvironment(name string, branch string) error {
-	// Invoke Builder Lambda to delete schedules
+	err = store.GetSingleEnvironmentForRepository(&stored, name, branch)
+	if err != nil {
+		return err
+	}
+
+	// Invoke Builder to delete schedules
 	event := types.BuilderEvent{
 		Operation:  "DELETE_SCHEDULE",
 		Branch:     branch,
@@ -327,23 +400,20 @@ func DeleteSingleEnvironment(name string, branch string) error {
 		return err
 	}
 
-	client := getLambdaClient()
-	_, err = client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-builder"),
-		InvocationType: aws.String("Event"),
-		Payload:        body,
-	})
+	_, err = invoker.Invoke(BuilderComponent, InvocationTypeEvent, body)
 
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/DeleteSingleEnvironment", "operation": "builder/invokeSchedule"}, 0)
 		return err
 	}
+	schedulesDeleted = true
 
-	// Invoke Builder Lambda to delete environment
+	// Invoke Builder to delete environment
 	event = types.BuilderEvent{
-		Operation:  "DELETE",
-		Branch:     branch,
-		Repository: name,
+		Operation:   "DELETE",
+		Branch:      branch,
+		Repository:  name,
+		OperationID: operationID,
 	}
 	body, err = json.Marshal(event)
 	if err != nil {
@@ -351,51 +421,20 @@ func DeleteSingleEnvironment(name string, branch string) error {
 		return err
 	}
 
-	_, err = client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-builder"),
-		InvocationType: aws.String("Event"),
-		Payload:        body,
-	})
+	_, err = invoker.Invoke(BuilderComponent, InvocationTypeEvent, body)
 
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/DeleteSingleEnvironment", "operation": "builder/invoke"}, 0)
 		return err
 	}
+	invoked = true
 
 	return nil
 }
 
-// CheckIfEnvironmentsForRepositoryExist checks if Environments in DynamoDB exist where repository equals name from the parameters. If Environments
+// CheckIfEnvironmentsForRepositoryExist checks if Environments in the Store exist where repository equals name from the parameters. If Environments
 // were found then true gets returned, otherwise false.
 // If an error occurs the error gets logged and then returned.
 func CheckIfEnvironmentsForRepositoryExist(name string) (bool, error) {
-	svc := getDynamoDbClient()
-
-	result, err := svc.Query(&dynamodb.QueryInput{
-		TableName:              aws.String("auto-staging-environments"),
-		KeyConditionExpression: aws.String("repository = :repository"),
-		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
-			":repository": {
-				S: aws.String(name),
-			},
-		},
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/CheckIfEnvironmentsForRepositoryExist", "operation": "dynamodb/exec"}, 0)
-		return false, err
-	}
-
-	var environments []types.Environment
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &environments)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/CheckIfEnvironmentsForRepositoryExist", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
-		return false, err
-	}
-
-	if len(environments) > 0 {
-		return true, nil
-	}
-
-	return false, nil
+	return store.CheckIfEnvironmentsForRepositoryExist(name)
 }
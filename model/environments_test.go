@@ -0,0 +1,361 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+
+	"github.com/auto-staging/tower/types"
+)
+
+var testRepository = types.Repository{
+	Repository:            "environments",
+	InfrastructureRepoURL: "https://example.com/infrastructure.git",
+	CodeBuildRoleARN:      "arn:aws:iam::123456789012:role/builder",
+	StartupSchedules:      []types.TimeSchedule{{Cron: "0 7 ? * MON-FRI *"}},
+	ShutdownSchedules:     []types.TimeSchedule{{Cron: "0 19 ? * MON-FRI *"}},
+	EnvironmentVariables:  []types.EnvironmentVariable{{Name: "STAGE", Value: "review"}},
+}
+
+func TestAddEnvironmentForRepository(t *testing.T) {
+	tests := []struct {
+		name        string
+		post        types.EnvironmentPost
+		existing    bool
+		invokeErr   error
+		wantErr     bool
+		wantStored  bool
+		wantStatus  types.EnvironmentState
+		wantRepoURL string
+		wantStartup []types.TimeSchedule
+	}{
+		{"defaults of the repository", types.EnvironmentPost{Branch: "feature"}, false, nil, false, true, types.EnvironmentStateInitiating, testRepository.InfrastructureRepoURL, testRepository.StartupSchedules},
+		{
+			"own values", types.EnvironmentPost{Branch: "feature", InfrastructureRepoURL: "https://example.com/other.git", StartupSchedules: []types.TimeSchedule{{Cron: "0 8 ? * MON *"}}},
+			false, nil, false, true, types.EnvironmentStateInitiating, "https://example.com/other.git", []types.TimeSchedule{{Cron: "0 8 ? * MON *"}},
+		},
+		{"existing branch", types.EnvironmentPost{Branch: "feature"}, true, nil, true, true, types.EnvironmentStateRunning, testRepository.InfrastructureRepoURL, testRepository.StartupSchedules},
+		{"invalid zoned schedule", types.EnvironmentPost{Branch: "feature", StartupSchedules: []types.TimeSchedule{{Cron: "0 1 1 * ? *", TimeZone: "Europe/Berlin"}}}, false, nil, true, false, "", "", nil},
+		{"builder invocation failed", types.EnvironmentPost{Branch: "feature"}, false, errors.New("builder unavailable"), true, true, types.EnvironmentStateInitiatingFailed, testRepository.InfrastructureRepoURL, testRepository.StartupSchedules},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := newTestStore(t)
+			repository := testRepository
+			store.AddRepository(&repository)
+			if test.existing {
+				store.AddEnvironment(&types.Environment{Repository: "environments", Branch: "feature", Status: types.EnvironmentStateRunning, InfrastructureRepoURL: testRepository.InfrastructureRepoURL, StartupSchedules: testRepository.StartupSchedules})
+			}
+			recorder.Err = test.invokeErr
+
+			result, err := AddEnvironmentForRepository(test.post, "environments", "tester")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("AddEnvironmentForRepository returned %v, want error %t", err, test.wantErr)
+			}
+			if test.existing && !IsConditionalCheckFailed(err) {
+				t.Errorf("AddEnvironmentForRepository returned %v for an existing branch, want a failed condition", err)
+			}
+
+			stored := types.Environment{}
+			store.GetSingleEnvironmentForRepository(&stored, "environments", "feature")
+			if !test.wantStored {
+				if stored.Repository != "" || len(recorder.Invocations) != 0 {
+					t.Errorf("Environment %+v was stored or the Builder was invoked %d times", stored, len(recorder.Invocations))
+				}
+				return
+			}
+
+			if stored.Status != test.wantStatus || stored.InfrastructureRepoURL != test.wantRepoURL || !reflect.DeepEqual(stored.StartupSchedules, test.wantStartup) {
+				t.Errorf("stored Environment %+v", stored)
+			}
+			if test.wantErr {
+				return
+			}
+			if stored.Version != 1 || stored.OperationID == "" || result.OperationID != stored.OperationID || result.Status != stored.Status {
+				t.Errorf("result %+v and stored Environment %+v", result, stored)
+			}
+			if !reflect.DeepEqual(result.AllowedActions, []types.EnvironmentAction{}) {
+				t.Errorf("allowed actions %v of an initiating Environment", result.AllowedActions)
+			}
+		})
+	}
+}
+
+func TestUpdateEnvironment(t *testing.T) {
+	update := types.EnvironmentPut{
+		InfrastructureRepoURL: "https://example.com/updated.git",
+		CodeBuildRoleARN:      testRepository.CodeBuildRoleARN,
+		StartupSchedules:      []types.TimeSchedule{{Cron: "0 6 ? * MON-FRI *"}},
+		ShutdownSchedules:     []types.TimeSchedule{{Cron: "0 20 ? * MON-FRI *"}},
+		EnvironmentVariables:  []types.EnvironmentVariable{},
+	}
+
+	tests := []struct {
+		name            string
+		status          types.EnvironmentState
+		expectedVersion int64
+		invokeErr       error
+		wantErr         error
+		wantUpdated     bool
+		wantStatus      types.EnvironmentState
+	}{
+		{"any version", types.EnvironmentStateRunning, AnyVersion, nil, nil, true, types.EnvironmentStateUpdating},
+		{"current version", types.EnvironmentStateRunning, 3, nil, nil, true, types.EnvironmentStateUpdating},
+		{"after failed update", types.EnvironmentStateUpdatingFailed, 3, nil, nil, true, types.EnvironmentStateUpdating},
+		{"stale version", types.EnvironmentStateRunning, 2, nil, ErrVersionMismatch, false, types.EnvironmentStateRunning},
+		{"stopped", types.EnvironmentStateStopped, 3, nil, ErrStatusConflict, false, types.EnvironmentStateStopped},
+		{"builder invocation failed", types.EnvironmentStateRunning, 3, errors.New("builder unavailable"), nil, false, types.EnvironmentStateRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := newTestStore(t, types.Environment{
+				Repository:            "environments",
+				Branch:                "feature",
+				Status:                test.status,
+				InfrastructureRepoURL: testRepository.InfrastructureRepoURL,
+				StartupSchedules:      testRepository.StartupSchedules,
+				ShutdownSchedules:     testRepository.ShutdownSchedules,
+				Version:               3,
+			})
+			recorder.Err = test.invokeErr
+
+			put := update
+			result, err := UpdateEnvironment(&put, "environments", "feature", test.expectedVersion, "tester")
+			if test.invokeErr != nil {
+				if err == nil {
+					t.Fatal("UpdateEnvironment returned no error")
+				}
+			} else if err != test.wantErr {
+				t.Fatalf("UpdateEnvironment returned %v, want %v", err, test.wantErr)
+			}
+
+			stored := types.Environment{}
+			store.GetSingleEnvironmentForRepository(&stored, "environments", "feature")
+			if stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+
+			if !test.wantUpdated {
+				if stored.Version != 3 || stored.InfrastructureRepoURL != testRepository.InfrastructureRepoURL || !reflect.DeepEqual(stored.StartupSchedules, testRepository.StartupSchedules) {
+					t.Errorf("failed update changed the configuration: %+v", stored)
+				}
+				return
+			}
+
+			if stored.Version != 4 || stored.InfrastructureRepoURL != update.InfrastructureRepoURL || !reflect.DeepEqual(stored.StartupSchedules, update.StartupSchedules) {
+				t.Errorf("stored Environment %+v", stored)
+			}
+			if result.Version != stored.Version || result.OperationID != stored.OperationID || result.Status != test.wantStatus {
+				t.Errorf("result %+v", result)
+			}
+		})
+	}
+}
+
+func TestDeleteSingleEnvironment(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     types.EnvironmentState
+		exists     bool
+		invokeErr  error
+		wantErr    bool
+		wantStatus types.EnvironmentState
+	}{
+		{"running", types.EnvironmentStateRunning, true, nil, false, types.EnvironmentStateDestroying},
+		{"stopped", types.EnvironmentStateStopped, true, nil, false, types.EnvironmentStateDestroying},
+		{"failed creation", types.EnvironmentStateInitiatingFailed, true, nil, false, types.EnvironmentStateDestroying},
+		{"initiating", types.EnvironmentStateInitiating, true, nil, true, types.EnvironmentStateInitiating},
+		{"destroying", types.EnvironmentStateDestroying, true, nil, true, types.EnvironmentStateDestroying},
+		{"missing", "", false, nil, true, ""},
+		{"builder invocation failed", types.EnvironmentStateRunning, true, errors.New("builder unavailable"), true, types.EnvironmentStateRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			environments := []types.Environment{}
+			if test.exists {
+				environments = append(environments, types.Environment{Repository: "environments", Branch: "feature", Status: test.status})
+			}
+			recorder := newTestStore(t, environments...)
+			recorder.Err = test.invokeErr
+
+			err := DeleteSingleEnvironment("environments", "feature", "tester")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("DeleteSingleEnvironment returned %v, want error %t", err, test.wantErr)
+			}
+			if test.exists && test.invokeErr == nil && test.wantErr && err != ErrStatusConflict {
+				t.Errorf("DeleteSingleEnvironment returned %v, want %v", err, ErrStatusConflict)
+			}
+
+			if stored := storedStatus(t, "environments", "feature"); stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestGetEnvironmentsForRepositoryPage(t *testing.T) {
+	environments := []types.Environment{{Repository: "other", Branch: "main", Status: types.EnvironmentStateRunning}}
+	for i := 0; i < 5; i++ {
+		environments = append(environments, types.Environment{Repository: "environments", Branch: "feature-" + strconv.Itoa(i), Status: types.EnvironmentStateStopped})
+	}
+	newTestStore(t, environments...)
+
+	tests := []struct {
+		name      string
+		limit     int64
+		wantPages []int
+	}{
+		{"single page", 10, []int{5}},
+		{"exact pages", 5, []int{5}},
+		{"multiple pages", 2, []int{2, 2, 1}},
+		{"one per page", 1, []int{1, 1, 1, 1, 1}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pages := []int{}
+			branches := []string{}
+			cursor := ""
+			for {
+				page := []types.Environment{}
+				next, err := GetEnvironmentsForRepositoryPage(&page, "environments", test.limit, cursor)
+				if err != nil {
+					t.Fatalf("GetEnvironmentsForRepositoryPage returned %v", err)
+				}
+				pages = append(pages, len(page))
+				for _, environment := range page {
+					branches = append(branches, environment.Branch)
+					if !reflect.DeepEqual(environment.AllowedActions, []types.EnvironmentAction{types.EnvironmentActionStart, types.EnvironmentActionDestroy}) {
+						t.Errorf("allowed actions %v of a stopped Environment", environment.AllowedActions)
+					}
+				}
+				if next == "" {
+					break
+				}
+				cursor = next
+			}
+
+			if !reflect.DeepEqual(pages, test.wantPages) {
+				t.Errorf("page sizes %v, want %v", pages, test.wantPages)
+			}
+			if !reflect.DeepEqual(branches, []string{"feature-0", "feature-1", "feature-2", "feature-3", "feature-4"}) {
+				t.Errorf("branches %v", branches)
+			}
+		})
+	}
+
+	all := []types.Environment{}
+	if err := GetAllEnvironmentsForRepository(&all, "environments"); err != nil || len(all) != 5 {
+		t.Errorf("GetAllEnvironmentsForRepository returned %d Environments and %v", len(all), err)
+	}
+
+	page := []types.Environment{}
+	if _, err := GetEnvironmentsForRepositoryPage(&page, "environments", 2, "invalid"); err != ErrInvalidCursor {
+		t.Errorf("GetEnvironmentsForRepositoryPage returned %v for an invalid cursor, want %v", err, ErrInvalidCursor)
+	}
+}
+
+// failingOperationInvoker records the invocations like the RecordingInvoker, but the Builder invocations with the operation fail.
+type failingOperationInvoker struct {
+	*RecordingInvoker
+	operation string
+}
+
+func (f failingOperationInvoker) Invoke(component string, invocationType string, payload []byte) ([]byte, error) {
+	response, err := f.RecordingInvoker.Invoke(component, invocationType, payload)
+	event := types.BuilderEvent{}
+	json.Unmarshal(payload, &event)
+	if component == BuilderComponent && event.Operation == f.operation {
+		return nil, errors.New("builder failed " + f.operation)
+	}
+	return response, err
+}
+
+func TestDeleteSingleEnvironmentRestoresSchedules(t *testing.T) {
+	startups := []types.TimeSchedule{{Cron: "0 7 ? * MON-FRI *"}}
+	shutdowns := []types.TimeSchedule{{Cron: "0 19 ? * MON-FRI *"}}
+	recorder := newTestStore(t, types.Environment{Repository: "environments", Branch: "feature", Status: types.EnvironmentStateRunning, StartupSchedules: startups, ShutdownSchedules: shutdowns})
+	SetInvoker(failingOperationInvoker{RecordingInvoker: recorder, operation: "DELETE"})
+
+	if err := DeleteSingleEnvironment("environments", "feature", "tester"); err == nil {
+		t.Fatal("DeleteSingleEnvironment returned no error for a failed DELETE")
+	}
+
+	events, err := recorder.BuilderEvents()
+	if err != nil {
+		t.Fatalf("BuilderEvents returned %v", err)
+	}
+	if len(events) != 3 {
+		t.Fatalf("Builder events %+v, want DELETE_SCHEDULE, DELETE and UPDATE_SCHEDULE", events)
+	}
+	want := []types.BuilderEvent{
+		{Operation: "DELETE_SCHEDULE", Repository: "environments", Branch: "feature"},
+		{Operation: "DELETE", Repository: "environments", Branch: "feature", OperationID: events[1].OperationID},
+		{Operation: "UPDATE_SCHEDULE", Repository: "environments", Branch: "feature", StartupSchedules: startups, ShutdownSchedules: shutdowns},
+	}
+	if !reflect.DeepEqual(events, want) {
+		t.Errorf("Builder events\n%+v\nwant\n%+v", events, want)
+	}
+	if stored := storedStatus(t, "environments", "feature"); stored.Status != types.EnvironmentStateRunning {
+		t.Errorf("stored status %q, want %q", stored.Status, types.EnvironmentStateRunning)
+	}
+}
+
+// failingUpdateStore is a MemoryStore which fails the next writes of an Environment configuration, failures is the number of failing writes.
+type failingUpdateStore struct {
+	*MemoryStore
+	failures int
+}
+
+func (s *failingUpdateStore) UpdateEnvironment(environment *types.EnvironmentPut, name string, branch string, expectedVersion int64) (types.Environment, error) {
+	if s.failures > 0 {
+		s.failures--
+		return types.Environment{}, errors.New("store unavailable")
+	}
+	return s.MemoryStore.UpdateEnvironment(environment, name, branch, expectedVersion)
+}
+
+func TestUpdateEnvironmentStoreFailure(t *testing.T) {
+	delay := storeUpdateRetryDelay
+	storeUpdateRetryDelay = 0
+	defer func() { storeUpdateRetryDelay = delay }()
+	tests := []struct {
+		name        string
+		failures    int
+		wantErr     bool
+		wantRepoURL string
+	}{
+		{"retried", storeUpdateAttempts - 1, false, "https://example.com/updated.git"},
+		{"failed", storeUpdateAttempts, true, "https://example.com/infrastructure.git"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := newTestStore(t, types.Environment{Repository: "environments", Branch: "feature", Status: types.EnvironmentStateRunning, InfrastructureRepoURL: "https://example.com/infrastructure.git"})
+			SetStore(&failingUpdateStore{MemoryStore: store.(*MemoryStore), failures: test.failures})
+
+			_, err := UpdateEnvironment(&types.EnvironmentPut{InfrastructureRepoURL: "https://example.com/updated.git"}, "environments", "feature", AnyVersion, "tester")
+			if (err != nil) != test.wantErr {
+				t.Fatalf("UpdateEnvironment returned %v, want error %t", err, test.wantErr)
+			}
+
+			stored := types.Environment{}
+			store.GetSingleEnvironmentForRepository(&stored, "environments", "feature")
+			if stored.InfrastructureRepoURL != test.wantRepoURL {
+				t.Errorf("stored infrastructure repository %q, want %q", stored.InfrastructureRepoURL, test.wantRepoURL)
+			}
+			// The Builder was invoked, so the claim is never rolled back and the Builder callback completes it
+			if stored.Status != types.EnvironmentStateUpdating {
+				t.Errorf("stored status %q, want %q", stored.Status, types.EnvironmentStateUpdating)
+			}
+			if len(recorder.Invocations) != 2 {
+				t.Errorf("Builder invoked %d times, want UPDATE_SCHEDULE and UPDATE only", len(recorder.Invocations))
+			}
+		})
+	}
+}
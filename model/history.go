@@ -0,0 +1,59 @@
+package model
+
+import (
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// historyTimeLayout is the layout of the time of an EnvironmentHistoryEntry, it has a fixed width so the entries are sorted by time in the Store.
+const historyTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+// historyKey returns the key of the history of an Environment, repository names can't contain "/" so the key is unique.
+func historyKey(name string, branch string) string {
+	return name + "/" + branch
+}
+
+// recordEnvironmentHistory appends the status change described by the EnvironmentHistoryEntry to the history of the Environment, the time gets set to now.
+// The status change already happened, so errors are only logged.
+func recordEnvironmentHistory(entry types.EnvironmentHistoryEntry) {
+	entry.Environment = historyKey(entry.Repository, entry.Branch)
+	entry.Time = time.Now().UTC().Format(historyTimeLayout)
+
+	err := store.AddEnvironmentHistoryEntry(&entry)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/recordEnvironmentHistory", "operation": "store/add"}, 0)
+	}
+}
+
+// GetAllEnvironmentHistory reads the complete status history of the Environment where repository equals name and branch equals branch from the Store,
+// newest entries first. The entries are written into the array of EnvironmentHistoryEntry structs given in the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func GetAllEnvironmentHistory(entries *[]types.EnvironmentHistoryEntry, name string, branch string) error {
+	all := []types.EnvironmentHistoryEntry{}
+	cursor := ""
+	for {
+		page := []types.EnvironmentHistoryEntry{}
+		next, err := store.GetEnvironmentHistoryPage(&page, name, branch, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	*entries = all
+	return nil
+}
+
+// GetEnvironmentHistoryPage reads up to limit entries of the status history of the Environment where repository equals name and branch equals branch
+// starting at the cursor from the Store, newest entries first. The entries are written into the array of EnvironmentHistoryEntry structs given in the parameters (call by reference).
+// The cursor for the next page gets returned, if no more entries exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetEnvironmentHistoryPage(entries *[]types.EnvironmentHistoryEntry, name string, branch string, limit int64, cursor string) (string, error) {
+	return store.GetEnvironmentHistoryPage(entries, name, branch, limit, cursor)
+}
@@ -0,0 +1,73 @@
+package model
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+)
+
+// HTTPInvoker is the Invoker implementation which sends the payload as POST request to an HTTP endpoint per component,
+// it's used to point Tower at locally running stand-ins of the Builder and Scheduler.
+// The endpoint receives the invocation type in the X-Invocation-Type header and must respond with the same payload the Lambda function would return.
+type HTTPInvoker struct {
+	Endpoints map[string]string
+	Client    *http.Client
+}
+
+// NewHTTPInvoker returns an HTTPInvoker for the given component endpoints.
+func NewHTTPInvoker(endpoints map[string]string) *HTTPInvoker {
+	return &HTTPInvoker{
+		Endpoints: endpoints,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// Invoke sends the payload to the endpoint configured for the component and returns the response body.
+// If no endpoint is configured or the endpoint responds with a non 2xx status code an error gets returned.
+// If an error occurs the error gets logged and then returned.
+func (h *HTTPInvoker) Invoke(component string, invocationType string, payload []byte) ([]byte, error) {
+	endpoint := h.Endpoints[component]
+	if endpoint == "" {
+		err := errors.New("No endpoint configured for component " + component)
+		config.Logger.Log(err, map[string]string{"module": "model/HTTPInvoker", "operation": "endpoint"}, 0)
+		return nil, err
+	}
+
+	request, err := http.NewRequest(http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/HTTPInvoker", "operation": "http/newRequest"}, 0)
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Invocation-Type", invocationType)
+
+	response, err := h.Client.Do(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/HTTPInvoker", "operation": "http/do"}, 0)
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	body, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/HTTPInvoker", "operation": "http/readBody"}, 0)
+		return nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		err = errors.New(component + " responded with status code " + strconv.Itoa(response.StatusCode))
+		config.Logger.Log(err, map[string]string{"module": "model/HTTPInvoker", "operation": "http/statusCode"}, 0)
+		return nil, err
+	}
+
+	if invocationType != InvocationTypeRequestResponse {
+		return nil, nil
+	}
+
+	return body, nil
+}
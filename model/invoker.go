@@ -0,0 +1,121 @@
+package model
+
+import (
+	"encoding/json"
+	"sync"
+
+	"github.com/auto-staging/tower/types"
+)
+
+const (
+	// BuilderComponent is the name of the auto-staging Builder component.
+	BuilderComponent = "auto-staging-builder"
+	// SchedulerComponent is the name of the auto-staging Scheduler component.
+	SchedulerComponent = "auto-staging-scheduler"
+
+	// InvocationTypeEvent is used for asynchronous invocations, the response payload is empty.
+	InvocationTypeEvent = "Event"
+	// InvocationTypeRequestResponse is used for synchronous invocations, the response payload of the component gets returned.
+	InvocationTypeRequestResponse = "RequestResponse"
+)
+
+// Invoker calls the auto-staging components (Builder and Scheduler) with the given payload.
+// For RequestResponse invocations the raw response payload of the component gets returned.
+type Invoker interface {
+	Invoke(component string, invocationType string, payload []byte) ([]byte, error)
+}
+
+var invoker Invoker = &LambdaInvoker{}
+
+// SetInvoker replaces the Invoker used by all model functions, the default Invoker is the LambdaInvoker.
+func SetInvoker(i Invoker) {
+	invoker = i
+}
+
+// InitInvoker selects the Invoker implementation by name, "http" uses the HTTPInvoker with the given Builder and Scheduler endpoints,
+// "recording" uses an in-process RecordingInvoker, every other value keeps the LambdaInvoker.
+func InitInvoker(backend string, builderEndpoint string, schedulerEndpoint string) {
+	switch backend {
+	case "http":
+		SetInvoker(NewHTTPInvoker(map[string]string{
+			BuilderComponent:   builderEndpoint,
+			SchedulerComponent: schedulerEndpoint,
+		}))
+	case "recording":
+		SetInvoker(NewRecordingInvoker())
+	default:
+		SetInvoker(&LambdaInvoker{})
+	}
+}
+
+// Invocation contains the values of a single call recorded by the RecordingInvoker.
+type Invocation struct {
+	Component      string
+	InvocationType string
+	Payload        []byte
+}
+
+// RecordingInvoker is an in-process Invoker which records all invocations instead of calling the components.
+// The responses for RequestResponse invocations can be preset per component, it's used for local runs and tests.
+type RecordingInvoker struct {
+	mutex       sync.Mutex
+	Invocations []Invocation
+	Responses   map[string][]byte
+	Err         error
+}
+
+// NewRecordingInvoker returns an empty RecordingInvoker.
+func NewRecordingInvoker() *RecordingInvoker {
+	return &RecordingInvoker{
+		Responses: map[string][]byte{},
+	}
+}
+
+// Invoke records the invocation and returns the preset response for the component, if Err is set it gets returned instead.
+func (r *RecordingInvoker) Invoke(component string, invocationType string, payload []byte) ([]byte, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Invocations = append(r.Invocations, Invocation{
+		Component:      component,
+		InvocationType: invocationType,
+		Payload:        payload,
+	})
+	if r.Err != nil {
+		return nil, r.Err
+	}
+	if invocationType != InvocationTypeRequestResponse {
+		return nil, nil
+	}
+
+	return r.Responses[component], nil
+}
+
+// BuilderEvents returns the payloads of all recorded Builder invocations unmarshaled into BuilderEvent structs.
+func (r *RecordingInvoker) BuilderEvents() ([]types.BuilderEvent, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	events := []types.BuilderEvent{}
+	for _, invocation := range r.Invocations {
+		if invocation.Component != BuilderComponent {
+			continue
+		}
+		event := types.BuilderEvent{}
+		err := json.Unmarshal(invocation.Payload, &event)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Reset removes all recorded invocations.
+func (r *RecordingInvoker) Reset() {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.Invocations = nil
+}
@@ -0,0 +1,239 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/auto-staging/tower/types"
+)
+
+func TestBuilderEvents(t *testing.T) {
+	startups := []types.TimeSchedule{{Cron: "0 7 ? * MON-FRI *"}}
+	shutdowns := []types.TimeSchedule{{Cron: "0 19 ? * MON-FRI *"}}
+	variables := []types.EnvironmentVariable{{Name: "STAGE", Type: "PLAINTEXT", Value: "review"}}
+	existing := types.Environment{
+		Repository:            "invoker",
+		Branch:                "feature",
+		Status:                types.EnvironmentStateRunning,
+		InfrastructureRepoURL: "https://example.com/infrastructure.git",
+		CodeBuildRoleARN:      "arn:aws:iam::123456789012:role/builder",
+		StartupSchedules:      startups,
+		ShutdownSchedules:     shutdowns,
+		EnvironmentVariables:  variables,
+		Version:               1,
+	}
+
+	tests := []struct {
+		name   string
+		stored []types.Environment
+		// run executes the action against the Environment and returns the operation ID which must be sent to the Builder
+		run        func(t *testing.T) string
+		wantEvents []types.BuilderEvent
+	}{
+		{
+			name: "create",
+			run: func(t *testing.T) string {
+				result, err := AddEnvironmentForRepository(types.EnvironmentPost{
+					Branch:                "feature",
+					InfrastructureRepoURL: existing.InfrastructureRepoURL,
+					CodeBuildRoleARN:      existing.CodeBuildRoleARN,
+					StartupSchedules:      startups,
+					ShutdownSchedules:     shutdowns,
+					EnvironmentVariables:  variables,
+				}, "invoker", "tester")
+				if err != nil {
+					t.Fatalf("AddEnvironmentForRepository returned %v", err)
+				}
+				return result.OperationID
+			},
+			wantEvents: []types.BuilderEvent{
+				{Operation: "UPDATE_SCHEDULE", Repository: "invoker", Branch: "feature", StartupSchedules: startups, ShutdownSchedules: shutdowns},
+				{Operation: "CREATE", Repository: "invoker", Branch: "feature", InfrastructureRepoURL: existing.InfrastructureRepoURL, CodeBuildRoleARN: existing.CodeBuildRoleARN, EnvironmentVariables: variables},
+			},
+		},
+		{
+			name:   "update",
+			stored: []types.Environment{existing},
+			run: func(t *testing.T) string {
+				result, err := UpdateEnvironment(&types.EnvironmentPut{
+					InfrastructureRepoURL: "https://example.com/updated.git",
+					CodeBuildRoleARN:      existing.CodeBuildRoleARN,
+					StartupSchedules:      []types.TimeSchedule{{Cron: "0 6 ? * MON-FRI *"}},
+					ShutdownSchedules:     shutdowns,
+					EnvironmentVariables:  variables,
+				}, "invoker", "feature", 1, "tester")
+				if err != nil {
+					t.Fatalf("UpdateEnvironment returned %v", err)
+				}
+				return result.OperationID
+			},
+			wantEvents: []types.BuilderEvent{
+				{Operation: "UPDATE_SCHEDULE", Repository: "invoker", Branch: "feature", StartupSchedules: []types.TimeSchedule{{Cron: "0 6 ? * MON-FRI *"}}, ShutdownSchedules: shutdowns},
+				{Operation: "UPDATE", Repository: "invoker", Branch: "feature", InfrastructureRepoURL: "https://example.com/updated.git", CodeBuildRoleARN: existing.CodeBuildRoleARN, EnvironmentVariables: variables},
+			},
+		},
+		{
+			name:   "update with zoned schedules",
+			stored: []types.Environment{existing},
+			run: func(t *testing.T) string {
+				result, err := UpdateEnvironment(&types.EnvironmentPut{
+					InfrastructureRepoURL: existing.InfrastructureRepoURL,
+					CodeBuildRoleARN:      existing.CodeBuildRoleARN,
+					StartupSchedules:      []types.TimeSchedule{{Cron: "0 12 ? * MON-FRI *", TimeZone: "Etc/GMT-2"}},
+					ShutdownSchedules:     shutdowns,
+					EnvironmentVariables:  variables,
+				}, "invoker", "feature", AnyVersion, "tester")
+				if err != nil {
+					t.Fatalf("UpdateEnvironment returned %v", err)
+				}
+				return result.OperationID
+			},
+			wantEvents: []types.BuilderEvent{
+				{Operation: "UPDATE_SCHEDULE", Repository: "invoker", Branch: "feature", StartupSchedules: []types.TimeSchedule{{Cron: "0 10 ? * MON-FRI *"}}, ShutdownSchedules: shutdowns},
+				{Operation: "UPDATE", Repository: "invoker", Branch: "feature", InfrastructureRepoURL: existing.InfrastructureRepoURL, CodeBuildRoleARN: existing.CodeBuildRoleARN, EnvironmentVariables: variables},
+			},
+		},
+		{
+			name:   "deploy commit",
+			stored: []types.Environment{existing},
+			run: func(t *testing.T) string {
+				result, err := DeployEnvironmentCommit("invoker", "feature", "0123456789abcdef", "tester")
+				if err != nil {
+					t.Fatalf("DeployEnvironmentCommit returned %v", err)
+				}
+				return result.OperationID
+			},
+			wantEvents: []types.BuilderEvent{
+				{Operation: "UPDATE", Repository: "invoker", Branch: "feature", InfrastructureRepoURL: existing.InfrastructureRepoURL, CodeBuildRoleARN: existing.CodeBuildRoleARN, EnvironmentVariables: variables, CommitSHA: "0123456789abcdef"},
+			},
+		},
+		{
+			name:   "destroy",
+			stored: []types.Environment{existing},
+			run: func(t *testing.T) string {
+				if err := DeleteSingleEnvironment("invoker", "feature", "tester"); err != nil {
+					t.Fatalf("DeleteSingleEnvironment returned %v", err)
+				}
+				return storedStatus(t, "invoker", "feature").OperationID
+			},
+			wantEvents: []types.BuilderEvent{
+				{Operation: "DELETE_SCHEDULE", Repository: "invoker", Branch: "feature"},
+				{Operation: "DELETE", Repository: "invoker", Branch: "feature"},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := newTestStore(t, test.stored...)
+
+			operationID := test.run(t)
+			if operationID == "" {
+				t.Fatal("no operation ID was claimed")
+			}
+
+			events, err := recorder.BuilderEvents()
+			if err != nil {
+				t.Fatalf("BuilderEvents returned %v", err)
+			}
+			// Only the operations with a Builder callback carry the operation ID of the claim
+			want := make([]types.BuilderEvent, len(test.wantEvents))
+			for i, event := range test.wantEvents {
+				if _, ok := builderOperations[event.Operation]; ok {
+					event.OperationID = operationID
+				}
+				want[i] = event
+			}
+			if !reflect.DeepEqual(events, want) {
+				t.Errorf("Builder events\n%+v\nwant\n%+v", events, want)
+			}
+			for _, invocation := range recorder.Invocations {
+				if invocation.Component != BuilderComponent || invocation.InvocationType != InvocationTypeEvent {
+					t.Errorf("invocation of %q with type %q", invocation.Component, invocation.InvocationType)
+				}
+			}
+		})
+	}
+}
+
+func TestBuilderInvocationFailed(t *testing.T) {
+	recorder := newTestStore(t, types.Environment{Repository: "invoker", Branch: "feature", Status: types.EnvironmentStateRunning})
+	recorder.Err = errors.New("builder unavailable")
+
+	if err := DeleteSingleEnvironment("invoker", "feature", "tester"); err != recorder.Err {
+		t.Errorf("DeleteSingleEnvironment returned %v, want %v", err, recorder.Err)
+	}
+	if len(recorder.Invocations) != 1 {
+		t.Errorf("Builder invoked %d times after a failed invocation", len(recorder.Invocations))
+	}
+	if stored := storedStatus(t, "invoker", "feature"); stored.Status != types.EnvironmentStateRunning {
+		t.Errorf("stored status %q, want %q", stored.Status, types.EnvironmentStateRunning)
+	}
+}
+
+func TestSchedulerInvocation(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     types.EnvironmentState
+		action     string
+		response   []byte
+		wantOutput string
+		wantStatus types.EnvironmentState
+	}{
+		{"start", types.EnvironmentStateStopped, "start", []byte(`"Environment started"`), "Environment started", types.EnvironmentStateStarting},
+		{"stop", types.EnvironmentStateRunning, "stop", []byte(`"Environment stopped"`), "Environment stopped", types.EnvironmentStateStopping},
+		{"scheduler failed", types.EnvironmentStateStopped, "start", []byte(`""`), "{ \"message\": \"scheduler failed, check the scheduler logs for more information\" }", types.EnvironmentStateStartingFailed},
+		{"unquoted response", types.EnvironmentStateStopped, "start", []byte(`{"message": "Environment started"}`), `{"message": "Environment started"}`, types.EnvironmentStateStarting},
+		{"empty response", types.EnvironmentStateStopped, "start", []byte{}, "{ \"message\": \"scheduler failed, check the scheduler logs for more information\" }", types.EnvironmentStateStartingFailed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := newTestStore(t, types.Environment{Repository: "invoker", Branch: "feature", Status: test.status})
+			recorder.Responses[SchedulerComponent] = test.response
+
+			output, err := TriggerSchedulerLambdaForEnvironment("invoker", "feature", test.action, "tester")
+			if err != nil {
+				t.Fatalf("TriggerSchedulerLambdaForEnvironment returned %v", err)
+			}
+			if output != test.wantOutput {
+				t.Errorf("output %q, want %q", output, test.wantOutput)
+			}
+
+			if len(recorder.Invocations) != 1 || recorder.Invocations[0].Component != SchedulerComponent || recorder.Invocations[0].InvocationType != InvocationTypeRequestResponse {
+				t.Fatalf("invocations %+v", recorder.Invocations)
+			}
+			body := map[string]string{}
+			if err := json.Unmarshal(recorder.Invocations[0].Payload, &body); err != nil {
+				t.Fatalf("Scheduler payload %q isn't valid JSON: %v", recorder.Invocations[0].Payload, err)
+			}
+			if !reflect.DeepEqual(body, map[string]string{"repository": "invoker", "branch": "feature", "action": test.action}) {
+				t.Errorf("Scheduler payload %v", body)
+			}
+
+			if stored := storedStatus(t, "invoker", "feature"); stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+		})
+	}
+}
+
+func TestSchedulerPayloadEscaping(t *testing.T) {
+	branch := `feature/"quoted"\path`
+	recorder := newTestStore(t, types.Environment{Repository: "invoker", Branch: branch, Status: types.EnvironmentStateRunning})
+	recorder.Responses[SchedulerComponent] = []byte(`"Environment stopped"`)
+
+	if _, err := TriggerSchedulerLambdaForEnvironment("invoker", branch, "stop", "tester"); err != nil {
+		t.Fatalf("TriggerSchedulerLambdaForEnvironment returned %v", err)
+	}
+
+	event := types.SchedulerEvent{}
+	if err := json.Unmarshal(recorder.Invocations[0].Payload, &event); err != nil {
+		t.Fatalf("Scheduler payload %q isn't valid JSON: %v", recorder.Invocations[0].Payload, err)
+	}
+	if event != (types.SchedulerEvent{Repository: "invoker", Branch: branch, Action: "stop"}) {
+		t.Errorf("Scheduler event %+v", event)
+	}
+}
@@ -20,3 +20,24 @@ func getLambdaClient() *lambda.Lambda {
 
 	return lambda.New(sess)
 }
+
+// LambdaInvoker is the Invoker implementation which invokes the component Lambda functions, the component name is used as function name.
+type LambdaInvoker struct{}
+
+// Invoke invokes the Lambda function named like the component with the given invocation type and payload.
+// If an error occurs the error gets logged and then returned.
+func (l *LambdaInvoker) Invoke(component string, invocationType string, payload []byte) ([]byte, error) {
+	client := getLambdaClient()
+	result, err := client.Invoke(&lambda.InvokeInput{
+		FunctionName:   aws.String(component),
+		InvocationType: aws.String(invocationType),
+		Payload:        payload,
+	})
+
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/LambdaInvoker", "operation": "lambda/invoke"}, 0)
+		return nil, err
+	}
+
+	return result.Payload, nil
+}
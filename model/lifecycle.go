@@ -0,0 +1,257 @@
+package model
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// ErrStatusConflict is returned if the status of an existing Environment doesn't allow the transition anymore,
+// because the status was changed by a concurrent request since it was checked.
+var ErrStatusConflict = errors.New("Environment status changed concurrently")
+
+// ErrUnknownOperation is returned if a Builder callback contains an operation without transition in the Environment lifecycle.
+var ErrUnknownOperation = errors.New("Unknown operation")
+
+// builderOperations maps the operations of the BuilderEvent to the actions of the Environment lifecycle.
+var builderOperations = map[string]types.EnvironmentAction{
+	"CREATE": types.EnvironmentActionCreate,
+	"UPDATE": types.EnvironmentActionUpdate,
+	"DELETE": types.EnvironmentActionDestroy,
+}
+
+// StatusTransition is a conditional status update of an Environment executed by the Store.
+//
+// From contains the states the Environment must be in, if OperationID is set the stored operation ID must match as well.
+//
+// To is the new status, NewOperationID and FailureReason are stored with the status (empty values remove the stored values).
+//
+// CommitSHA is stored as commit of the Environment, an empty value keeps the stored commit.
+//
+// If CheckVersion is set the stored version of the Environment must be ExpectedVersion, the version itself isn't changed by the transition.
+type StatusTransition struct {
+	From            []types.EnvironmentState
+	OperationID     string
+	To              types.EnvironmentState
+	NewOperationID  string
+	FailureReason   string
+	CommitSHA       string
+	CheckVersion    bool
+	ExpectedVersion int64
+}
+
+// setAllowedActions sets the actions allowed by the EnvironmentTransitions table on all Environments which were read from the Store.
+func setAllowedActions(environments []types.Environment) {
+	for i := range environments {
+		environments[i].AllowedActions = environments[i].Status.AllowedActions()
+	}
+}
+
+// setStatusAllowedActions sets the actions allowed by the EnvironmentTransitions table on all EnvironmentStatus entries which were read from the Store.
+func setStatusAllowedActions(status []types.EnvironmentStatus) {
+	for i := range status {
+		status[i].AllowedActions = status[i].Status.AllowedActions()
+	}
+}
+
+// randomHex returns length random bytes as hex string.
+func randomHex(length int) (string, error) {
+	value := make([]byte, length)
+	_, err := rand.Read(value)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(value), nil
+}
+
+func newOperationID() (string, error) {
+	return randomHex(16)
+}
+
+// claimEnvironmentTransition atomically sets the status of the Environment to the To state of the action, if the current status is one of the From states.
+// Claiming the transition before invoking the Builder or Scheduler ensures that only one of multiple concurrent requests executes the action.
+// Every claim gets a new operation ID, it's sent to the Builder and identifies the claim when the result of the action is reported.
+// The status information before the transition and the operation ID get returned, if the Environment exists but its status doesn't allow the action
+// ErrStatusConflict gets returned. The status change gets recorded in the history of the Environment with the actor who requested the action.
+func claimEnvironmentTransition(name string, branch string, action types.EnvironmentAction, actor string) (types.EnvironmentStatus, string, error) {
+	return claimEnvironmentCommitTransition(name, branch, action, "", actor)
+}
+
+// claimEnvironmentCommitTransition claims the transition like claimEnvironmentTransition and stores the commitSHA as commit of the Environment,
+// if commitSHA is empty the stored commit is kept.
+func claimEnvironmentCommitTransition(name string, branch string, action types.EnvironmentAction, commitSHA string, actor string) (types.EnvironmentStatus, string, error) {
+	return claimVersionedEnvironmentTransition(name, branch, action, commitSHA, AnyVersion, actor)
+}
+
+// claimVersionedEnvironmentTransition claims the transition like claimEnvironmentCommitTransition, if expectedVersion isn't AnyVersion the claim is also conditional
+// on the stored version of the Environment. If the Environment exists but has another version ErrVersionMismatch gets returned and neither the status nor the history is changed.
+func claimVersionedEnvironmentTransition(name string, branch string, action types.EnvironmentAction, commitSHA string, expectedVersion int64, actor string) (types.EnvironmentStatus, string, error) {
+	operationID, err := newOperationID()
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/claimEnvironmentTransition", "operation": "newOperationID"}, 0)
+		return types.EnvironmentStatus{}, "", err
+	}
+
+	transition := types.EnvironmentTransitions[action]
+	previous, err := store.TransitionEnvironmentStatus(name, branch, StatusTransition{
+		From:            transition.From,
+		To:              transition.To,
+		NewOperationID:  operationID,
+		CommitSHA:       commitSHA,
+		CheckVersion:    expectedVersion != AnyVersion,
+		ExpectedVersion: expectedVersion,
+	})
+	if IsConditionalCheckFailed(err) {
+		// The condition also fails if the Environment doesn't exist, the status or version only conflicts if the Environment is still stored
+		stored := types.Environment{}
+		if getErr := store.GetSingleEnvironmentForRepository(&stored, name, branch); getErr == nil && stored.Branch != "" {
+			if expectedVersion != AnyVersion && stored.Version != expectedVersion {
+				return types.EnvironmentStatus{}, "", ErrVersionMismatch
+			}
+			return types.EnvironmentStatus{}, "", ErrStatusConflict
+		}
+	}
+	if err != nil {
+		return types.EnvironmentStatus{}, "", err
+	}
+
+	recordEnvironmentHistory(types.EnvironmentHistoryEntry{
+		Repository:  name,
+		Branch:      branch,
+		Action:      action,
+		From:        previous.Status,
+		To:          transition.To,
+		OperationID: operationID,
+		CommitSHA:   commitSHA,
+		Actor:       actor,
+	})
+
+	return previous, operationID, nil
+}
+
+// rollbackEnvironmentTransition restores the status information (and the commit) from before the claim, it's used if the action couldn't be started.
+// If the claim was already completed (e.g. by the Builder) the status stays untouched, errors are only logged.
+func rollbackEnvironmentTransition(name string, branch string, action types.EnvironmentAction, operationID string, previous types.EnvironmentStatus, actor string) {
+	transition := types.EnvironmentTransitions[action]
+	_, err := store.TransitionEnvironmentStatus(name, branch, StatusTransition{
+		From:           []types.EnvironmentState{transition.To},
+		OperationID:    operationID,
+		To:             previous.Status,
+		NewOperationID: previous.OperationID,
+		FailureReason:  previous.FailureReason,
+		CommitSHA:      previous.CommitSHA,
+	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/rollbackEnvironmentTransition", "operation": "transition/" + string(action)}, 1)
+		return
+	}
+
+	recordEnvironmentHistory(types.EnvironmentHistoryEntry{
+		Repository:  name,
+		Branch:      branch,
+		Action:      action,
+		From:        transition.To,
+		To:          previous.Status,
+		OperationID: operationID,
+		Reason:      "Rolled back, the action couldn't be started",
+		Actor:       actor,
+	})
+}
+
+// failEnvironmentCreation sets the status of a new Environment which couldn't be created to the Failure state of the create action, since a pending Environment
+// allows no action at all. If operationID is empty the create transition wasn't claimed yet and the Environment must still be pending, otherwise the claim
+// identified by operationID gets completed as failed. Errors get logged, because the failure of the creation is returned to the caller anyway.
+func failEnvironmentCreation(name string, branch string, operationID string, failureReason string, actor string) {
+	var err error
+	if operationID != "" {
+		err = completeEnvironmentTransition(name, branch, types.EnvironmentActionCreate, operationID, false, failureReason, actor)
+	} else {
+		transition := types.EnvironmentTransitions[types.EnvironmentActionCreate]
+		_, err = store.TransitionEnvironmentStatus(name, branch, StatusTransition{
+			From:          []types.EnvironmentState{types.EnvironmentStatePending},
+			To:            transition.Failure,
+			FailureReason: failureReason,
+		})
+		if err == nil {
+			recordEnvironmentHistory(types.EnvironmentHistoryEntry{
+				Repository: name,
+				Branch:     branch,
+				Action:     types.EnvironmentActionCreate,
+				From:       types.EnvironmentStatePending,
+				To:         transition.Failure,
+				Reason:     failureReason,
+				Actor:      actor,
+			})
+		}
+	}
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/failEnvironmentCreation", "operation": "transition/create"}, 1)
+	}
+}
+
+// completeEnvironmentTransition sets the status of the Environment with the claim identified by operationID to the Success or Failure state of the action,
+// the failureReason is stored with the Failure state. A successful destroy removes the Environment from the Store.
+// If the Environment doesn't exist anymore or the claim was replaced, the condition of the update fails with a ConditionalCheckFailedException.
+// The status change gets recorded in the history of the Environment with the actor who reported the result.
+func completeEnvironmentTransition(name string, branch string, action types.EnvironmentAction, operationID string, success bool, failureReason string, actor string) error {
+	transition := types.EnvironmentTransitions[action]
+	entry := types.EnvironmentHistoryEntry{
+		Repository:  name,
+		Branch:      branch,
+		Action:      action,
+		From:        transition.To,
+		To:          transition.Success,
+		OperationID: operationID,
+		Actor:       actor,
+	}
+
+	var err error
+	if success && transition.Success == "" {
+		err = store.DeleteEnvironment(name, branch, []types.EnvironmentState{transition.To}, operationID)
+	} else {
+		update := StatusTransition{
+			From:        []types.EnvironmentState{transition.To},
+			OperationID: operationID,
+			To:          transition.Success,
+		}
+		if !success {
+			update.To = transition.Failure
+			update.FailureReason = failureReason
+			entry.To = transition.Failure
+			entry.Reason = failureReason
+		}
+		_, err = store.TransitionEnvironmentStatus(name, branch, update)
+	}
+	if err != nil {
+		return err
+	}
+
+	recordEnvironmentHistory(entry)
+	return nil
+}
+
+// ApplyBuilderCallback applies the result of a CREATE, UPDATE or DELETE operation reported by the Builder to the Environment lifecycle.
+// The callback is only applied if the Environment is still in the status of the operation and the operation ID matches the ID sent to the Builder,
+// otherwise ErrStatusConflict gets returned. If the operation has no transition ErrUnknownOperation gets returned.
+// The status change gets recorded in the history of the Environment with the Builder as actor.
+// If an error occurs the error gets logged and then returned.
+func ApplyBuilderCallback(callback types.BuilderCallback) error {
+	action, ok := builderOperations[callback.Operation]
+	if !ok {
+		return ErrUnknownOperation
+	}
+
+	err := completeEnvironmentTransition(callback.Repository, callback.Branch, action, callback.OperationID, callback.Success == 1, callback.Reason, BuilderComponent)
+	if IsConditionalCheckFailed(err) {
+		// The condition also fails if the Environment doesn't exist, the callback only conflicts if the Environment is still stored
+		stored := types.EnvironmentStatus{}
+		if getErr := store.GetSingleEnvironmentStatusInformation(&stored, callback.Repository, callback.Branch); getErr == nil && stored.Repository != "" {
+			return ErrStatusConflict
+		}
+	}
+
+	return err
+}
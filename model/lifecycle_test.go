@@ -0,0 +1,292 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/auto-staging/tower/types"
+)
+
+// newTestStore replaces the Store and the Invoker with a MemoryStore and a RecordingInvoker, the Environments are added to the Store.
+func newTestStore(t *testing.T, environments ...types.Environment) *RecordingInvoker {
+	SetStore(NewMemoryStore())
+	recorder := NewRecordingInvoker()
+	SetInvoker(recorder)
+
+	for i := range environments {
+		if err := store.AddEnvironment(&environments[i]); err != nil {
+			t.Fatalf("AddEnvironment returned %v", err)
+		}
+	}
+	return recorder
+}
+
+// storedStatus returns the stored status information of the Environment, the Repository is empty if the Environment doesn't exist.
+func storedStatus(t *testing.T, name string, branch string) types.EnvironmentStatus {
+	status := types.EnvironmentStatus{}
+	if err := store.GetSingleEnvironmentStatusInformation(&status, name, branch); err != nil {
+		t.Fatalf("GetSingleEnvironmentStatusInformation returned %v", err)
+	}
+	return status
+}
+
+// storedHistory returns the history of the Environment, newest entry first.
+func storedHistory(t *testing.T, name string, branch string) []types.EnvironmentHistoryEntry {
+	entries := []types.EnvironmentHistoryEntry{}
+	if _, err := GetEnvironmentHistoryPage(&entries, name, branch, 0, ""); err != nil {
+		t.Fatalf("GetEnvironmentHistoryPage returned %v", err)
+	}
+	return entries
+}
+
+func TestClaimEnvironmentTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		status     types.EnvironmentState
+		action     types.EnvironmentAction
+		wantErr    error
+		wantStatus types.EnvironmentState
+	}{
+		{"create pending", types.EnvironmentStatePending, types.EnvironmentActionCreate, nil, types.EnvironmentStateInitiating},
+		{"update running", types.EnvironmentStateRunning, types.EnvironmentActionUpdate, nil, types.EnvironmentStateUpdating},
+		{"update after failed update", types.EnvironmentStateUpdatingFailed, types.EnvironmentActionUpdate, nil, types.EnvironmentStateUpdating},
+		{"stop running", types.EnvironmentStateRunning, types.EnvironmentActionStop, nil, types.EnvironmentStateStopping},
+		{"start stopped", types.EnvironmentStateStopped, types.EnvironmentActionStart, nil, types.EnvironmentStateStarting},
+		{"start after failed start", types.EnvironmentStateStartingFailed, types.EnvironmentActionStart, nil, types.EnvironmentStateStarting},
+		{"destroy after failed creation", types.EnvironmentStateInitiatingFailed, types.EnvironmentActionDestroy, nil, types.EnvironmentStateDestroying},
+		{"destroy after failed destroy", types.EnvironmentStateDestroyingFailed, types.EnvironmentActionDestroy, nil, types.EnvironmentStateDestroying},
+		{"start running", types.EnvironmentStateRunning, types.EnvironmentActionStart, ErrStatusConflict, types.EnvironmentStateRunning},
+		{"stop stopped", types.EnvironmentStateStopped, types.EnvironmentActionStop, ErrStatusConflict, types.EnvironmentStateStopped},
+		{"update pending", types.EnvironmentStatePending, types.EnvironmentActionUpdate, ErrStatusConflict, types.EnvironmentStatePending},
+		{"destroy initiating", types.EnvironmentStateInitiating, types.EnvironmentActionDestroy, ErrStatusConflict, types.EnvironmentStateInitiating},
+		{"destroy destroying", types.EnvironmentStateDestroying, types.EnvironmentActionDestroy, ErrStatusConflict, types.EnvironmentStateDestroying},
+		{"create running", types.EnvironmentStateRunning, types.EnvironmentActionCreate, ErrStatusConflict, types.EnvironmentStateRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestStore(t, types.Environment{Repository: "lifecycle", Branch: "main", Status: test.status, OperationID: "previous", FailureReason: "previous failure"})
+
+			previous, operationID, err := claimEnvironmentTransition("lifecycle", "main", test.action, "tester")
+			if err != test.wantErr {
+				t.Fatalf("claimEnvironmentTransition returned %v, want %v", err, test.wantErr)
+			}
+
+			stored := storedStatus(t, "lifecycle", "main")
+			if stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+
+			history := storedHistory(t, "lifecycle", "main")
+			if test.wantErr != nil {
+				if stored.OperationID != "previous" || len(history) != 0 {
+					t.Errorf("conflicting claim changed the operation ID to %q or recorded %+v", stored.OperationID, history)
+				}
+				return
+			}
+
+			if previous.Status != test.status || previous.OperationID != "previous" || previous.FailureReason != "previous failure" {
+				t.Errorf("previous status information %+v", previous)
+			}
+			if operationID == "" || stored.OperationID != operationID || stored.FailureReason != "" {
+				t.Errorf("stored operation ID %q and failure reason %q, claimed operation ID %q", stored.OperationID, stored.FailureReason, operationID)
+			}
+			if len(history) != 1 || history[0].Action != test.action || history[0].From != test.status || history[0].To != test.wantStatus || history[0].OperationID != operationID || history[0].Actor != "tester" {
+				t.Errorf("history %+v", history)
+			}
+		})
+	}
+}
+
+func TestClaimEnvironmentTransitionMissingEnvironment(t *testing.T) {
+	newTestStore(t)
+
+	_, _, err := claimEnvironmentTransition("lifecycle", "missing", types.EnvironmentActionDestroy, "tester")
+	if err == nil || err == ErrStatusConflict || !IsConditionalCheckFailed(err) {
+		t.Errorf("claimEnvironmentTransition returned %v, want a failed condition", err)
+	}
+	if history := storedHistory(t, "lifecycle", "missing"); len(history) != 0 {
+		t.Errorf("history %+v", history)
+	}
+}
+
+func TestClaimVersionedEnvironmentTransition(t *testing.T) {
+	tests := []struct {
+		name            string
+		status          types.EnvironmentState
+		expectedVersion int64
+		wantErr         error
+	}{
+		{"any version", types.EnvironmentStateRunning, AnyVersion, nil},
+		{"current version", types.EnvironmentStateRunning, 3, nil},
+		{"stale version", types.EnvironmentStateRunning, 2, ErrVersionMismatch},
+		{"newer version", types.EnvironmentStateRunning, 4, ErrVersionMismatch},
+		{"stale version and conflicting status", types.EnvironmentStateStopped, 2, ErrVersionMismatch},
+		{"current version and conflicting status", types.EnvironmentStateStopped, 3, ErrStatusConflict},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestStore(t, types.Environment{Repository: "lifecycle", Branch: "main", Status: test.status, Version: 3})
+
+			_, _, err := claimVersionedEnvironmentTransition("lifecycle", "main", types.EnvironmentActionUpdate, "", test.expectedVersion, "tester")
+			if err != test.wantErr {
+				t.Fatalf("claimVersionedEnvironmentTransition returned %v, want %v", err, test.wantErr)
+			}
+
+			environment := types.Environment{}
+			store.GetSingleEnvironmentForRepository(&environment, "lifecycle", "main")
+			if environment.Version != 3 {
+				t.Errorf("claim changed the version to %d", environment.Version)
+			}
+			if test.wantErr != nil && environment.Status != test.status {
+				t.Errorf("failed claim changed the status to %q", environment.Status)
+			}
+		})
+	}
+}
+
+func TestApplyBuilderCallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      types.EnvironmentState
+		action      types.EnvironmentAction
+		operation   string
+		success     int
+		otherClaim  bool
+		wantErr     error
+		wantDeleted bool
+		wantStatus  types.EnvironmentState
+	}{
+		{"create succeeded", types.EnvironmentStatePending, types.EnvironmentActionCreate, "CREATE", 1, false, nil, false, types.EnvironmentStateRunning},
+		{"create failed", types.EnvironmentStatePending, types.EnvironmentActionCreate, "CREATE", 0, false, nil, false, types.EnvironmentStateInitiatingFailed},
+		{"update succeeded", types.EnvironmentStateRunning, types.EnvironmentActionUpdate, "UPDATE", 1, false, nil, false, types.EnvironmentStateRunning},
+		{"update failed", types.EnvironmentStateRunning, types.EnvironmentActionUpdate, "UPDATE", 0, false, nil, false, types.EnvironmentStateUpdatingFailed},
+		{"delete succeeded", types.EnvironmentStateStopped, types.EnvironmentActionDestroy, "DELETE", 1, false, nil, true, ""},
+		{"delete failed", types.EnvironmentStateStopped, types.EnvironmentActionDestroy, "DELETE", 0, false, nil, false, types.EnvironmentStateDestroyingFailed},
+		{"callback of a replaced claim", types.EnvironmentStateRunning, types.EnvironmentActionUpdate, "UPDATE", 1, true, ErrStatusConflict, false, types.EnvironmentStateUpdating},
+		{"callback for another operation", types.EnvironmentStateRunning, types.EnvironmentActionUpdate, "CREATE", 1, false, ErrStatusConflict, false, types.EnvironmentStateUpdating},
+		{"unknown operation", types.EnvironmentStateRunning, types.EnvironmentActionUpdate, "UPDATE_SCHEDULE", 1, false, ErrUnknownOperation, false, types.EnvironmentStateUpdating},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestStore(t, types.Environment{Repository: "lifecycle", Branch: "main", Status: test.status})
+			_, operationID, err := claimEnvironmentTransition("lifecycle", "main", test.action, "tester")
+			if err != nil {
+				t.Fatalf("claimEnvironmentTransition returned %v", err)
+			}
+			if test.otherClaim {
+				operationID = "replaced"
+			}
+
+			err = ApplyBuilderCallback(types.BuilderCallback{Repository: "lifecycle", Branch: "main", Operation: test.operation, OperationID: operationID, Success: test.success, Reason: "build failed"})
+			if err != test.wantErr {
+				t.Fatalf("ApplyBuilderCallback returned %v, want %v", err, test.wantErr)
+			}
+
+			stored := storedStatus(t, "lifecycle", "main")
+			if test.wantDeleted {
+				if stored.Repository != "" {
+					t.Errorf("Environment wasn't deleted: %+v", stored)
+				}
+				return
+			}
+			if stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+			if test.wantErr == nil && test.success == 0 && stored.FailureReason != "build failed" {
+				t.Errorf("stored failure reason %q", stored.FailureReason)
+			}
+
+			history := storedHistory(t, "lifecycle", "main")
+			if test.wantErr == nil && (len(history) != 2 || history[0].To != test.wantStatus || history[0].Actor != BuilderComponent) {
+				t.Errorf("history %+v", history)
+			}
+		})
+	}
+}
+
+func TestApplyBuilderCallbackMissingEnvironment(t *testing.T) {
+	newTestStore(t)
+
+	err := ApplyBuilderCallback(types.BuilderCallback{Repository: "lifecycle", Branch: "missing", Operation: "DELETE", OperationID: "deleted", Success: 1})
+	if err == nil || err == ErrStatusConflict {
+		t.Errorf("ApplyBuilderCallback returned %v, want a failed condition", err)
+	}
+}
+
+func TestRollbackEnvironmentTransition(t *testing.T) {
+	tests := []struct {
+		name       string
+		completed  bool
+		wantStatus types.EnvironmentState
+	}{
+		{"claim still active", false, types.EnvironmentStateUpdatingFailed},
+		{"claim already completed", true, types.EnvironmentStateRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestStore(t, types.Environment{Repository: "lifecycle", Branch: "main", Status: types.EnvironmentStateUpdatingFailed, OperationID: "failed", FailureReason: "build failed", CommitSHA: "abc"})
+			previous, operationID, err := claimEnvironmentCommitTransition("lifecycle", "main", types.EnvironmentActionUpdate, "def", "tester")
+			if err != nil {
+				t.Fatalf("claimEnvironmentCommitTransition returned %v", err)
+			}
+			if test.completed {
+				if err := completeEnvironmentTransition("lifecycle", "main", types.EnvironmentActionUpdate, operationID, true, "", BuilderComponent); err != nil {
+					t.Fatalf("completeEnvironmentTransition returned %v", err)
+				}
+			}
+
+			rollbackEnvironmentTransition("lifecycle", "main", types.EnvironmentActionUpdate, operationID, previous, "tester")
+
+			stored := storedStatus(t, "lifecycle", "main")
+			if stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+			if !test.completed && (stored.OperationID != "failed" || stored.FailureReason != "build failed" || stored.CommitSHA != "abc") {
+				t.Errorf("rollback didn't restore the status information: %+v", stored)
+			}
+			if test.completed && stored.CommitSHA != "def" {
+				t.Errorf("rollback of a completed claim changed the commit to %q", stored.CommitSHA)
+			}
+		})
+	}
+}
+
+func TestFailEnvironmentCreation(t *testing.T) {
+	tests := []struct {
+		name       string
+		claim      bool
+		status     types.EnvironmentState
+		wantStatus types.EnvironmentState
+	}{
+		{"pending", false, types.EnvironmentStatePending, types.EnvironmentStateInitiatingFailed},
+		{"initiating", true, types.EnvironmentStatePending, types.EnvironmentStateInitiatingFailed},
+		{"not pending anymore", false, types.EnvironmentStateRunning, types.EnvironmentStateRunning},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			newTestStore(t, types.Environment{Repository: "lifecycle", Branch: "main", Status: test.status})
+			operationID := ""
+			if test.claim {
+				var err error
+				_, operationID, err = claimEnvironmentTransition("lifecycle", "main", types.EnvironmentActionCreate, "tester")
+				if err != nil {
+					t.Fatalf("claimEnvironmentTransition returned %v", err)
+				}
+			}
+
+			failEnvironmentCreation("lifecycle", "main", operationID, "Builder invocation failed", "tester")
+
+			stored := storedStatus(t, "lifecycle", "main")
+			if stored.Status != test.wantStatus {
+				t.Errorf("stored status %q, want %q", stored.Status, test.wantStatus)
+			}
+			if test.wantStatus == types.EnvironmentStateInitiatingFailed && (stored.FailureReason != "Builder invocation failed" || !stored.Status.Allows(types.EnvironmentActionDestroy)) {
+				t.Errorf("stored status information %+v", stored)
+			}
+		})
+	}
+}
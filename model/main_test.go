@@ -0,0 +1,14 @@
+package model
+
+import (
+	"os"
+	"testing"
+
+	"github.com/auto-staging/tower/config"
+)
+
+// TestMain initializes the Logger, since the tested functions log their errors.
+func TestMain(m *testing.M) {
+	config.Init()
+	os.Exit(m.Run())
+}
@@ -0,0 +1,751 @@
+package model
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+)
+
+type memoryItem map[string]*dynamodb.AttributeValue
+
+// MemoryStore is a Store implementation which keeps all items in memory, it's used for local runs and tests without AWS.
+// Items are stored as DynamoDB attribute maps, so marshaling, projections and conditional writes behave like the DynamoDBStore.
+type MemoryStore struct {
+	mutex        sync.Mutex
+	repositories map[string]memoryItem
+	environments map[string]map[string]memoryItem
+	globalConfig map[string]memoryItem
+	history      map[string][]memoryItem
+	audit        []memoryItem
+	roleBindings map[string]map[string]memoryItem
+	apiKeys      map[string]memoryItem
+	deliveries   map[string]memoryItem
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		repositories: map[string]memoryItem{},
+		environments: map[string]map[string]memoryItem{},
+		globalConfig: map[string]memoryItem{},
+		history:      map[string][]memoryItem{},
+		roleBindings: map[string]map[string]memoryItem{},
+		apiKeys:      map[string]memoryItem{},
+		deliveries:   map[string]memoryItem{},
+	}
+}
+
+func conditionalCheckFailed(module string) error {
+	err := awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+	config.Logger.Log(err, map[string]string{"module": module, "operation": "memory/exec"}, 0)
+	return err
+}
+
+// applyUpdate sets all values from the update map (keys prefixed with ":") on the item, like an UpdateExpression with SET for every key.
+func applyUpdate(item memoryItem, update memoryItem) {
+	for key, value := range update {
+		item[strings.TrimPrefix(key, ":")] = value
+	}
+}
+
+func sortedKeys(items map[string]memoryItem) []string {
+	keys := make([]string, 0, len(items))
+	for key := range items {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// memoryPage returns up to limit items which are sorted after the cursor, the items must be sorted by the key attributes (in descending order if descending is set).
+// A limit of 0 returns all remaining items. The cursor for the next page gets returned, if no more items exist the cursor is empty.
+func memoryPage(items []memoryItem, keyAttributes []string, descending bool, limit int64, cursor string) ([]map[string]*dynamodb.AttributeValue, string, error) {
+	startKey, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	sortKey := func(item map[string]*dynamodb.AttributeValue) string {
+		values := []string{}
+		for _, attribute := range keyAttributes {
+			values = append(values, aws.StringValue(item[attribute].S))
+		}
+		return strings.Join(values, "\x00")
+	}
+
+	page := []map[string]*dynamodb.AttributeValue{}
+	for i, item := range items {
+		if startKey != nil && !descending && sortKey(item) <= sortKey(startKey) {
+			continue
+		}
+		if startKey != nil && descending && sortKey(item) >= sortKey(startKey) {
+			continue
+		}
+		if limit > 0 && int64(len(page)) == limit {
+			key := map[string]*dynamodb.AttributeValue{}
+			for _, attribute := range keyAttributes {
+				key[attribute] = items[i-1][attribute]
+			}
+			next, err := encodeCursor(key)
+			return page, next, err
+		}
+		page = append(page, item)
+	}
+
+	return page, "", nil
+}
+
+// GetRepositoriesPage writes one page of the stored Repositories to the array of Repository structs from the parameters (call by reference)
+// and returns the cursor for the next page.
+func (s *MemoryStore) GetRepositoriesPage(repositories *[]types.Repository, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []memoryItem{}
+	for _, key := range sortedKeys(s.repositories) {
+		items = append(items, s.repositories[key])
+	}
+
+	page, next, err := memoryPage(items, []string{"repository"}, false, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, repositories)
+}
+
+// GetSingleRepository writes the Repository matching name to the Repository struct from the parameters (call by reference).
+func (s *MemoryStore) GetSingleRepository(repository *types.Repository, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return dynamodbattribute.UnmarshalMap(s.repositories[name], repository)
+}
+
+// AddRepository stores a new Repository, if a Repository with the same name exists a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) AddRepository(repository *types.Repository) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.repositories[repository.Repository]; ok {
+		return conditionalCheckFailed("model/MemoryStore.AddRepository")
+	}
+
+	av, err := dynamodbattribute.MarshalMap(repository)
+	if err != nil {
+		return err
+	}
+	s.repositories[repository.Repository] = av
+
+	return nil
+}
+
+// UpdateSingleRepository updates the Repository matching name and increments its version, if it doesn't exist or the version doesn't match expectedVersion
+// a ConditionalCheckFailedException gets returned. All values in the Repository struct are overwritten with the stored Repository (call by reference).
+func (s *MemoryStore) UpdateSingleRepository(repository *types.Repository, name string, expectedVersion int64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.repositories[name]
+	if !ok || (expectedVersion != AnyVersion && itemVersion(item) != expectedVersion) {
+		return conditionalCheckFailed("model/MemoryStore.UpdateSingleRepository")
+	}
+
+	update, err := dynamodbattribute.MarshalMap(types.RepositoryUpdate{
+		Webhook:               repository.Webhook,
+		Filters:               repository.Filters,
+		PullRequestActions:    repository.PullRequestActions,
+		ShutdownSchedules:     repository.ShutdownSchedules,
+		StartupSchedules:      repository.StartupSchedules,
+		EnvironmentVariables:  repository.EnvironmentVariables,
+		InfrastructureRepoURL: repository.InfrastructureRepoURL,
+		CodeBuildRoleARN:      repository.CodeBuildRoleARN,
+	})
+	if err != nil {
+		return err
+	}
+	applyUpdate(item, update)
+	incrementItemVersion(item)
+
+	return dynamodbattribute.UnmarshalMap(item, repository)
+}
+
+// SetRepositoryWebhookSecret sets the webhook secrets of the Repository matching name, empty secrets get removed.
+// If the Repository doesn't exist a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) SetRepositoryWebhookSecret(name string, secret string, previousSecret string, previousSecretExpiry string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.repositories[name]
+	if !ok {
+		return conditionalCheckFailed("model/MemoryStore.SetRepositoryWebhookSecret")
+	}
+
+	for attribute, value := range map[string]string{"webhookSecret": secret, "previousWebhookSecret": previousSecret, "previousSecretExpiry": previousSecretExpiry} {
+		delete(item, attribute)
+		if value != "" {
+			item[attribute] = &dynamodb.AttributeValue{S: aws.String(value)}
+		}
+	}
+
+	return nil
+}
+
+// DeleteSingleRepository removes the Repository matching name and writes the removed values to the Repository struct (call by reference).
+func (s *MemoryStore) DeleteSingleRepository(repository *types.Repository, name string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item := s.repositories[name]
+	delete(s.repositories, name)
+
+	return dynamodbattribute.UnmarshalMap(item, repository)
+}
+
+// GetEnvironmentsForRepositoryPage writes one page of the Environments of the Repository matching name to the array of Environments from the parameters (call by reference)
+// and returns the cursor for the next page.
+func (s *MemoryStore) GetEnvironmentsForRepositoryPage(environments *[]types.Environment, name string, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []memoryItem{}
+	for _, branch := range sortedKeys(s.environments[name]) {
+		items = append(items, s.environments[name][branch])
+	}
+
+	page, next, err := memoryPage(items, []string{"repository", "branch"}, false, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, environments)
+}
+
+// GetSingleEnvironmentForRepository writes the Environment matching name and branch to the Environment struct from the parameters (call by reference).
+func (s *MemoryStore) GetSingleEnvironmentForRepository(environment *types.Environment, name string, branch string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return dynamodbattribute.UnmarshalMap(s.environments[name][branch], environment)
+}
+
+// AddEnvironment stores a new Environment, if an Environment with the same repository and branch exists a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) AddEnvironment(environment *types.Environment) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.environments[environment.Repository][environment.Branch]; ok {
+		return conditionalCheckFailed("model/MemoryStore.AddEnvironment")
+	}
+
+	av, err := dynamodbattribute.MarshalMap(environment)
+	if err != nil {
+		return err
+	}
+	if s.environments[environment.Repository] == nil {
+		s.environments[environment.Repository] = map[string]memoryItem{}
+	}
+	s.environments[environment.Repository][environment.Branch] = av
+
+	return nil
+}
+
+// UpdateEnvironment updates the Environment matching name and branch and increments its version, if it doesn't exist or the version doesn't match expectedVersion
+// a ConditionalCheckFailedException gets returned. If no error occurs the updated Environment gets returned.
+func (s *MemoryStore) UpdateEnvironment(environment *types.EnvironmentPut, name string, branch string, expectedVersion int64) (types.Environment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.environments[name][branch]
+	if !ok || (expectedVersion != AnyVersion && itemVersion(item) != expectedVersion) {
+		return types.Environment{}, conditionalCheckFailed("model/MemoryStore.UpdateEnvironment")
+	}
+
+	update, err := dynamodbattribute.MarshalMap(types.EnvironmentUpdate{
+		InfrastructureRepoURL: environment.InfrastructureRepoURL,
+		ShutdownSchedules:     environment.ShutdownSchedules,
+		StartupSchedules:      environment.StartupSchedules,
+		CodeBuildRoleARN:      environment.CodeBuildRoleARN,
+		EnvironmentVariables:  environment.EnvironmentVariables,
+	})
+	if err != nil {
+		return types.Environment{}, err
+	}
+	applyUpdate(item, update)
+	incrementItemVersion(item)
+
+	response := types.Environment{}
+	err = dynamodbattribute.UnmarshalMap(item, &response)
+	return response, err
+}
+
+// SetEnvironmentPullRequest sets the pull request number and its author of the Environment matching name and branch and increments its version,
+// if it doesn't exist or the version doesn't match expectedVersion a ConditionalCheckFailedException gets returned. If no error occurs the updated Environment gets returned.
+func (s *MemoryStore) SetEnvironmentPullRequest(name string, branch string, pullRequest int, author string, expectedVersion int64) (types.Environment, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.environments[name][branch]
+	if !ok || (expectedVersion != AnyVersion && itemVersion(item) != expectedVersion) {
+		return types.Environment{}, conditionalCheckFailed("model/MemoryStore.SetEnvironmentPullRequest")
+	}
+
+	item["pullRequest"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(pullRequest))}
+	item["pullRequestAuthor"] = &dynamodb.AttributeValue{S: aws.String(author)}
+	incrementItemVersion(item)
+
+	response := types.Environment{}
+	err := dynamodbattribute.UnmarshalMap(item, &response)
+	return response, err
+}
+
+// SetEnvironmentScheduleOffsets sets the time zone offsets of the schedules of the Environment matching name and branch, empty offsets get removed.
+// If the Environment doesn't exist a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) SetEnvironmentScheduleOffsets(name string, branch string, offsets string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.environments[name][branch]
+	if !ok {
+		return conditionalCheckFailed("model/MemoryStore.SetEnvironmentScheduleOffsets")
+	}
+
+	delete(item, "scheduleOffsets")
+	if offsets != "" {
+		item["scheduleOffsets"] = &dynamodb.AttributeValue{S: aws.String(offsets)}
+	}
+
+	return nil
+}
+
+// TransitionEnvironmentStatus applies the StatusTransition to the Environment matching name and branch and returns the previous status information,
+// if the Environment doesn't exist, its status isn't one of the From states, the operation ID or the checked version doesn't match a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) TransitionEnvironmentStatus(name string, branch string, transition StatusTransition) (types.EnvironmentStatus, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.environments[name][branch]
+	if !ok || !matchesStatus(item, transition.From, transition.OperationID) || (transition.CheckVersion && itemVersion(item) != transition.ExpectedVersion) {
+		return types.EnvironmentStatus{}, conditionalCheckFailed("model/MemoryStore.TransitionEnvironmentStatus")
+	}
+
+	previous := types.EnvironmentStatus{}
+	err := dynamodbattribute.UnmarshalMap(item, &previous)
+	if err != nil {
+		return types.EnvironmentStatus{}, err
+	}
+
+	item["status"] = &dynamodb.AttributeValue{S: aws.String(string(transition.To))}
+	delete(item, "operationId")
+	if transition.NewOperationID != "" {
+		item["operationId"] = &dynamodb.AttributeValue{S: aws.String(transition.NewOperationID)}
+	}
+	delete(item, "failureReason")
+	if transition.FailureReason != "" {
+		item["failureReason"] = &dynamodb.AttributeValue{S: aws.String(transition.FailureReason)}
+	}
+	if transition.CommitSHA != "" {
+		item["commitSha"] = &dynamodb.AttributeValue{S: aws.String(transition.CommitSHA)}
+	}
+
+	return previous, nil
+}
+
+// DeleteEnvironment removes the Environment matching name and branch, if its status isn't one of the from states or the operation ID doesn't match
+// a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) DeleteEnvironment(name string, branch string, from []types.EnvironmentState, operationID string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.environments[name][branch]
+	if !ok || !matchesStatus(item, from, operationID) || operationID == "" {
+		return conditionalCheckFailed("model/MemoryStore.DeleteEnvironment")
+	}
+	delete(s.environments[name], branch)
+
+	return nil
+}
+
+// matchesStatus checks the status condition of a StatusTransition on a stored Environment, an empty operationID matches every item.
+func matchesStatus(item memoryItem, from []types.EnvironmentState, operationID string) bool {
+	if operationID != "" && (item["operationId"] == nil || aws.StringValue(item["operationId"].S) != operationID) {
+		return false
+	}
+	if item["status"] == nil {
+		return false
+	}
+	for _, state := range from {
+		if string(state) == aws.StringValue(item["status"].S) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckIfEnvironmentsForRepositoryExist returns true if at least one Environment for the Repository matching name is stored.
+func (s *MemoryStore) CheckIfEnvironmentsForRepositoryExist(name string) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return len(s.environments[name]) > 0, nil
+}
+
+// GetEnvironmentsStatusInformationPage writes the repository, branch, status and creationDate of one page of the stored Environments to the array of EnvironmentStatus structs (call by reference)
+// and returns the cursor for the next page.
+func (s *MemoryStore) GetEnvironmentsStatusInformationPage(status *[]types.EnvironmentStatus, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []memoryItem{}
+	repositories := make([]string, 0, len(s.environments))
+	for name := range s.environments {
+		repositories = append(repositories, name)
+	}
+	sort.Strings(repositories)
+	for _, name := range repositories {
+		for _, branch := range sortedKeys(s.environments[name]) {
+			items = append(items, s.environments[name][branch])
+		}
+	}
+
+	page, next, err := memoryPage(items, []string{"repository", "branch"}, false, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, status)
+}
+
+// GetSingleEnvironmentStatusInformation writes the repository, branch, status and creationDate of the Environment matching name and branch to the EnvironmentStatus struct (call by reference).
+func (s *MemoryStore) GetSingleEnvironmentStatusInformation(status *types.EnvironmentStatus, name string, branch string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return dynamodbattribute.UnmarshalMap(s.environments[name][branch], status)
+}
+
+// GetGlobalRepositoryConfiguration writes the global repository configuration for the stage to the GeneralConfig struct (call by reference).
+func (s *MemoryStore) GetGlobalRepositoryConfiguration(configuration *types.GeneralConfig, stage string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return dynamodbattribute.UnmarshalMap(s.globalConfig[stage], configuration)
+}
+
+// UpdateGlobalRepositoryConfiguration stores the global repository configuration for the stage, the item gets created if it doesn't exist.
+// All values in the GeneralConfig struct are overwritten with the stored configuration (call by reference).
+func (s *MemoryStore) UpdateGlobalRepositoryConfiguration(configuration *types.GeneralConfig, stage string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	update, err := dynamodbattribute.MarshalMap(types.GeneralConfigUpdate{
+		ShutdownSchedules:    configuration.ShutdownSchedules,
+		StartupSchedules:     configuration.StartupSchedules,
+		EnvironmentVariables: configuration.EnvironmentVariables,
+	})
+	if err != nil {
+		return err
+	}
+
+	item, ok := s.globalConfig[stage]
+	if !ok {
+		item = memoryItem{"stage": {S: aws.String(stage)}}
+		s.globalConfig[stage] = item
+	}
+	applyUpdate(item, update)
+
+	return dynamodbattribute.UnmarshalMap(item, configuration)
+}
+
+// AddEnvironmentHistoryEntry appends the EnvironmentHistoryEntry to the history of its Environment.
+func (s *MemoryStore) AddEnvironmentHistoryEntry(entry *types.EnvironmentHistoryEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	av, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return err
+	}
+	s.history[entry.Environment] = append(s.history[entry.Environment], av)
+
+	return nil
+}
+
+// GetEnvironmentHistoryPage writes one page of the history of the Environment matching name and branch to the array of EnvironmentHistoryEntry structs
+// from the parameters (call by reference), newest entries first. The cursor for the next page gets returned.
+func (s *MemoryStore) GetEnvironmentHistoryPage(entries *[]types.EnvironmentHistoryEntry, name string, branch string, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := append([]memoryItem{}, s.history[historyKey(name, branch)]...)
+	sort.SliceStable(items, func(i, j int) bool {
+		return aws.StringValue(items[i]["time"].S) > aws.StringValue(items[j]["time"].S)
+	})
+
+	page, next, err := memoryPage(items, []string{"environment", "time"}, true, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, entries)
+}
+
+// AddAuditEntry appends the AuditEntry to the audit log.
+func (s *MemoryStore) AddAuditEntry(entry *types.AuditEntry) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	av, err := dynamodbattribute.MarshalMap(entry)
+	if err != nil {
+		return err
+	}
+	s.audit = append(s.audit, av)
+
+	return nil
+}
+
+// GetAuditEntriesPage writes one page of the audit entries matching the AuditFilter to the array of AuditEntry structs from the parameters (call by reference),
+// newest entries first. The cursor for the next page gets returned.
+func (s *MemoryStore) GetAuditEntriesPage(entries *[]types.AuditEntry, filter AuditFilter, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []memoryItem{}
+	for _, item := range s.audit {
+		entry := types.AuditEntry{}
+		err := dynamodbattribute.UnmarshalMap(item, &entry)
+		if err != nil {
+			return "", err
+		}
+		if filter.matches(entry) {
+			items = append(items, item)
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool {
+		return aws.StringValue(items[i]["time"].S)+"\x00"+aws.StringValue(items[i]["scope"].S) > aws.StringValue(items[j]["time"].S)+"\x00"+aws.StringValue(items[j]["scope"].S)
+	})
+
+	page, next, err := memoryPage(items, []string{"time", "scope"}, true, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, entries)
+}
+
+// GetRoleBindingsPage writes one page of the stored RoleBindings to the array of RoleBinding structs from the parameters (call by reference)
+// and returns the cursor for the next page.
+func (s *MemoryStore) GetRoleBindingsPage(bindings *[]types.RoleBinding, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	identities := []string{}
+	for identity := range s.roleBindings {
+		identities = append(identities, identity)
+	}
+	sort.Strings(identities)
+
+	items := []memoryItem{}
+	for _, identity := range identities {
+		for _, repository := range sortedKeys(s.roleBindings[identity]) {
+			items = append(items, s.roleBindings[identity][repository])
+		}
+	}
+
+	page, next, err := memoryPage(items, []string{"identity", "repository"}, false, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, bindings)
+}
+
+// GetRoleBindingsForIdentity writes all RoleBindings of the identity to the array of RoleBinding structs from the parameters (call by reference).
+func (s *MemoryStore) GetRoleBindingsForIdentity(bindings *[]types.RoleBinding, identity string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []map[string]*dynamodb.AttributeValue{}
+	for _, repository := range sortedKeys(s.roleBindings[identity]) {
+		items = append(items, s.roleBindings[identity][repository])
+	}
+
+	return dynamodbattribute.UnmarshalListOfMaps(items, bindings)
+}
+
+// PutRoleBinding stores the RoleBinding, an existing RoleBinding of the identity for the same repository gets replaced.
+func (s *MemoryStore) PutRoleBinding(binding *types.RoleBinding) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	av, err := dynamodbattribute.MarshalMap(binding)
+	if err != nil {
+		return err
+	}
+	if s.roleBindings[binding.Identity] == nil {
+		s.roleBindings[binding.Identity] = map[string]memoryItem{}
+	}
+	s.roleBindings[binding.Identity][binding.Repository] = av
+
+	return nil
+}
+
+// DeleteRoleBinding removes the RoleBinding of the identity for the repository and writes it to the RoleBinding struct from the parameters (call by reference).
+func (s *MemoryStore) DeleteRoleBinding(binding *types.RoleBinding, identity string, repository string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item := s.roleBindings[identity][repository]
+	delete(s.roleBindings[identity], repository)
+
+	return dynamodbattribute.UnmarshalMap(item, binding)
+}
+
+// GetAPIKeysPage writes one page of the stored APIKeys to the array of APIKey structs from the parameters (call by reference)
+// and returns the cursor for the next page.
+func (s *MemoryStore) GetAPIKeysPage(keys *[]types.APIKey, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []memoryItem{}
+	for _, id := range sortedKeys(s.apiKeys) {
+		items = append(items, s.apiKeys[id])
+	}
+
+	page, next, err := memoryPage(items, []string{"id"}, false, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, keys)
+}
+
+// GetSingleAPIKey writes the APIKey matching id to the APIKey struct from the parameters (call by reference).
+func (s *MemoryStore) GetSingleAPIKey(key *types.APIKey, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return dynamodbattribute.UnmarshalMap(s.apiKeys[id], key)
+}
+
+// AddAPIKey stores a new APIKey, if an APIKey with the same id exists a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) AddAPIKey(key *types.APIKey) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.apiKeys[key.ID]; ok {
+		return conditionalCheckFailed("model/MemoryStore.AddAPIKey")
+	}
+
+	av, err := dynamodbattribute.MarshalMap(key)
+	if err != nil {
+		return err
+	}
+	s.apiKeys[key.ID] = av
+
+	return nil
+}
+
+// RevokeAPIKey marks the APIKey matching id as revoked, the revocation date of an already revoked APIKey stays untouched.
+// If the APIKey doesn't exist a ConditionalCheckFailedException gets returned. The APIKey struct is overwritten with the stored APIKey (call by reference).
+func (s *MemoryStore) RevokeAPIKey(key *types.APIKey, id string, revocationDate string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.apiKeys[id]
+	if !ok {
+		return conditionalCheckFailed("model/MemoryStore.RevokeAPIKey")
+	}
+
+	item["revoked"] = &dynamodb.AttributeValue{BOOL: aws.Bool(true)}
+	if _, ok := item["revocationDate"]; !ok {
+		item["revocationDate"] = &dynamodb.AttributeValue{S: aws.String(revocationDate)}
+	}
+
+	return dynamodbattribute.UnmarshalMap(item, key)
+}
+
+// GetWebhookDeliveriesPage writes up to limit WebhookDeliveries sorted by id and starting at the cursor to the array of WebhookDelivery structs from the parameters (call by reference).
+func (s *MemoryStore) GetWebhookDeliveriesPage(deliveries *[]types.WebhookDelivery, limit int64, cursor string) (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	items := []memoryItem{}
+	for _, id := range sortedKeys(s.deliveries) {
+		items = append(items, s.deliveries[id])
+	}
+
+	page, next, err := memoryPage(items, []string{"id"}, false, limit, cursor)
+	if err != nil {
+		return "", err
+	}
+
+	return next, dynamodbattribute.UnmarshalListOfMaps(page, deliveries)
+}
+
+// GetSingleWebhookDelivery writes the WebhookDelivery matching id to the WebhookDelivery struct from the parameters (call by reference).
+func (s *MemoryStore) GetSingleWebhookDelivery(delivery *types.WebhookDelivery, id string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return dynamodbattribute.UnmarshalMap(s.deliveries[id], delivery)
+}
+
+// AddWebhookDelivery stores a new WebhookDelivery, if a WebhookDelivery with the same id exists a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) AddWebhookDelivery(delivery *types.WebhookDelivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if _, ok := s.deliveries[delivery.ID]; ok {
+		return conditionalCheckFailed("model/MemoryStore.AddWebhookDelivery")
+	}
+
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return err
+	}
+	s.deliveries[delivery.ID] = av
+
+	return nil
+}
+
+// RetryWebhookDelivery overwrites the WebhookDelivery, if the stored WebhookDelivery doesn't have the receive time previousTime a ConditionalCheckFailedException gets returned.
+func (s *MemoryStore) RetryWebhookDelivery(delivery *types.WebhookDelivery, previousTime string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	item, ok := s.deliveries[delivery.ID]
+	if !ok || item["time"] == nil || item["time"].S == nil || *item["time"].S != previousTime {
+		return conditionalCheckFailed("model/MemoryStore.RetryWebhookDelivery")
+	}
+
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return err
+	}
+	s.deliveries[delivery.ID] = av
+
+	return nil
+}
+
+// PutWebhookDelivery stores the WebhookDelivery, an existing WebhookDelivery with the same id gets overwritten.
+func (s *MemoryStore) PutWebhookDelivery(delivery *types.WebhookDelivery) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	av, err := dynamodbattribute.MarshalMap(delivery)
+	if err != nil {
+		return err
+	}
+	s.deliveries[delivery.ID] = av
+
+	return nil
+}
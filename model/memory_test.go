@@ -0,0 +1,153 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/auto-staging/tower/types"
+)
+
+func TestMemoryStoreEnvironments(t *testing.T) {
+	startup := []types.TimeSchedule{{Cron: "0 7 ? * MON-FRI *"}}
+	environment := types.Environment{Repository: "memory", Branch: "feature", Status: "running", InfrastructureRepoURL: "https://example.com/infrastructure.git", StartupSchedules: startup}
+
+	tests := []struct {
+		name        string
+		stored      []types.Environment
+		run         func(s *MemoryStore) error
+		wantErr     bool
+		wantStored  bool
+		wantRepoURL string
+	}{
+		{"add", nil, func(s *MemoryStore) error { return s.AddEnvironment(&environment) }, false, true, environment.InfrastructureRepoURL},
+		{"add existing branch", []types.Environment{environment}, func(s *MemoryStore) error {
+			duplicate := environment
+			duplicate.InfrastructureRepoURL = "https://example.com/other.git"
+			return s.AddEnvironment(&duplicate)
+		}, true, true, environment.InfrastructureRepoURL},
+		{"update", []types.Environment{environment}, func(s *MemoryStore) error {
+			_, err := s.UpdateEnvironment(&types.EnvironmentPut{InfrastructureRepoURL: "https://example.com/updated.git", StartupSchedules: startup}, "memory", "feature", AnyVersion)
+			return err
+		}, false, true, "https://example.com/updated.git"},
+		{"update missing branch", nil, func(s *MemoryStore) error {
+			_, err := s.UpdateEnvironment(&types.EnvironmentPut{InfrastructureRepoURL: "https://example.com/updated.git"}, "memory", "feature", AnyVersion)
+			return err
+		}, true, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := NewMemoryStore()
+			for i := range test.stored {
+				if err := s.AddEnvironment(&test.stored[i]); err != nil {
+					t.Fatalf("AddEnvironment returned %v", err)
+				}
+			}
+
+			err := test.run(s)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("returned %v, want error %t", err, test.wantErr)
+			}
+			if err != nil && !IsConditionalCheckFailed(err) {
+				t.Errorf("returned %v, want a failed condition", err)
+			}
+
+			stored := types.Environment{}
+			s.GetSingleEnvironmentForRepository(&stored, "memory", "feature")
+			if !test.wantStored {
+				if stored.Repository != "" {
+					t.Errorf("Environment %+v was stored", stored)
+				}
+				return
+			}
+			if stored.InfrastructureRepoURL != test.wantRepoURL || stored.Status != environment.Status || !reflect.DeepEqual(stored.StartupSchedules, startup) {
+				t.Errorf("stored Environment %+v", stored)
+			}
+
+			exists, err := s.CheckIfEnvironmentsForRepositoryExist("memory")
+			if err != nil || !exists {
+				t.Errorf("CheckIfEnvironmentsForRepositoryExist returned %t and %v", exists, err)
+			}
+		})
+	}
+}
+
+func TestMemoryStoreStatusInformation(t *testing.T) {
+	s := NewMemoryStore()
+	for _, environment := range []types.Environment{
+		{Repository: "b", Branch: "main", Status: "stopped"},
+		{Repository: "a", Branch: "feature", Status: "running"},
+		{Repository: "a", Branch: "develop", Status: "initiating"},
+	} {
+		environment := environment
+		s.AddEnvironment(&environment)
+	}
+
+	status := []types.EnvironmentStatus{}
+	if next, err := s.GetEnvironmentsStatusInformationPage(&status, 0, ""); err != nil || next != "" {
+		t.Fatalf("GetEnvironmentsStatusInformationPage returned %q and %v", next, err)
+	}
+	want := []types.EnvironmentStatus{
+		{Repository: "a", Branch: "develop", Status: "initiating"},
+		{Repository: "a", Branch: "feature", Status: "running"},
+		{Repository: "b", Branch: "main", Status: "stopped"},
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("status %+v, want %+v", status, want)
+	}
+
+	single := types.EnvironmentStatus{}
+	s.GetSingleEnvironmentStatusInformation(&single, "unknown", "main")
+	if single.Repository != "" || single.Status != "" {
+		t.Errorf("status %+v of a missing Environment", single)
+	}
+}
+
+func TestMemoryStoreRepositories(t *testing.T) {
+	s := NewMemoryStore()
+	repository := types.Repository{Repository: "memory", Webhook: true, Filters: []string{"feature/*"}}
+	if err := s.AddRepository(&repository); err != nil {
+		t.Fatalf("AddRepository returned %v", err)
+	}
+	if err := s.AddRepository(&repository); err == nil || !IsConditionalCheckFailed(err) {
+		t.Errorf("AddRepository returned %v for an existing Repository", err)
+	}
+
+	update := types.Repository{InfrastructureRepoURL: "https://example.com/updated.git"}
+	if err := s.UpdateSingleRepository(&update, "memory", AnyVersion); err != nil {
+		t.Fatalf("UpdateSingleRepository returned %v", err)
+	}
+	if update.Repository != "memory" || update.Webhook || update.Filters != nil || update.InfrastructureRepoURL != "https://example.com/updated.git" {
+		t.Errorf("updated Repository %+v", update)
+	}
+	if err := s.UpdateSingleRepository(&types.Repository{}, "unknown", AnyVersion); err == nil {
+		t.Error("UpdateSingleRepository returned no error for a missing Repository")
+	}
+
+	deleted := types.Repository{}
+	s.DeleteSingleRepository(&deleted, "memory")
+	repositories := []types.Repository{}
+	s.GetRepositoriesPage(&repositories, 0, "")
+	if deleted.Repository != "memory" || len(repositories) != 0 {
+		t.Errorf("deleted Repository %+v, remaining %+v", deleted, repositories)
+	}
+}
+
+func TestMemoryStoreGlobalConfiguration(t *testing.T) {
+	s := NewMemoryStore()
+	configuration := types.GeneralConfig{StartupSchedules: []types.TimeSchedule{{Cron: "0 7 ? * MON-FRI *"}}}
+	if err := s.UpdateGlobalRepositoryConfiguration(&configuration, "prod"); err != nil {
+		t.Fatalf("UpdateGlobalRepositoryConfiguration returned %v", err)
+	}
+
+	stored := types.GeneralConfig{}
+	s.GetGlobalRepositoryConfiguration(&stored, "prod")
+	if !reflect.DeepEqual(stored, configuration) {
+		t.Errorf("stored configuration %+v, want %+v", stored, configuration)
+	}
+	other := types.GeneralConfig{}
+	s.GetGlobalRepositoryConfiguration(&other, "dev")
+	if !reflect.DeepEqual(other, types.GeneralConfig{}) {
+		t.Errorf("configuration %+v of another stage", other)
+	}
+}
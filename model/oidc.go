@@ -0,0 +1,372 @@
+package model
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	_ "crypto/sha256" // registers SHA-256 for crypto.Hash
+	_ "crypto/sha512" // registers SHA-384 and SHA-512 for crypto.Hash
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// ErrInvalidToken is returned if a JWT is malformed, its signature can't be verified or its claims aren't valid.
+var ErrInvalidToken = errors.New("Invalid token")
+
+// oidcLeeway is the tolerated clock skew for the exp and nbf claims.
+const oidcLeeway = time.Minute
+
+// oidcJWKSMaxAge is the time after which the JWKS document gets fetched again from the JWKS URL.
+const oidcJWKSMaxAge = time.Hour
+
+// OIDCConfig contains the settings for the verification of JWT bearer tokens.
+// The JWKS document is read from JWKSFile if it's set, otherwise it's fetched from JWKSURL or the jwks_uri of the OpenID configuration of the Issuer.
+// GroupRoles maps the values of the GroupsClaim to Roles, if it's empty the Roles are taken from the RoleBindings of the subject.
+type OIDCConfig struct {
+	Issuer      string
+	Audience    string
+	JWKSURL     string
+	JWKSFile    string
+	GroupsClaim string
+	GroupRoles  map[string][]types.RoleBinding
+}
+
+// OIDCIdentity is the verified identity of a JWT bearer token.
+// If the groups of the token are mapped to Roles, Grants contains the permissions of these Roles ("<permission>" or "<permission>@<repository>"), otherwise Grants is nil.
+type OIDCIdentity struct {
+	Subject string
+	Grants  []string
+}
+
+// jwk is a single key of a JWKS document, only RSA and EC keys are supported.
+type jwk struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+var oidc struct {
+	sync.Mutex
+	config    *OIDCConfig
+	keys      map[string]crypto.PublicKey
+	fetchedAt time.Time
+	client    *http.Client
+}
+
+// ParseGroupRoles parses the mapping of groups to Roles in the format "<group>=<role>[@<repository>],...", a group can be listed multiple times.
+// Without repository the Role applies to all Repositories.
+func ParseGroupRoles(mapping string) (map[string][]types.RoleBinding, error) {
+	groupRoles := map[string][]types.RoleBinding{}
+	for _, entry := range strings.Split(mapping, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		parts := strings.SplitN(entry, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, errors.New("Invalid group role mapping " + entry)
+		}
+		binding := types.RoleBinding{Identity: parts[0], Role: types.Role(parts[1]), Repository: types.AllRepositories}
+		if at := strings.Index(parts[1], "@"); at >= 0 {
+			binding.Role = types.Role(parts[1][:at])
+			binding.Repository = parts[1][at+1:]
+		}
+		if !binding.Role.Valid() || binding.Repository == "" {
+			return nil, errors.New("Invalid group role mapping " + entry)
+		}
+		groupRoles[binding.Identity] = append(groupRoles[binding.Identity], binding)
+	}
+	return groupRoles, nil
+}
+
+// InitOIDC enables the verification of JWT bearer tokens with the given OIDCConfig, if the JWKS document is read from a file it gets loaded immediately.
+// If an error occurs the error gets logged and then returned.
+func InitOIDC(oidcConfig OIDCConfig) error {
+	if oidcConfig.Issuer == "" || oidcConfig.Audience == "" {
+		err := errors.New("OIDC issuer and audience must be set")
+		config.Logger.Log(err, map[string]string{"module": "model/InitOIDC", "operation": "validateConfig"}, 0)
+		return err
+	}
+
+	oidc.Lock()
+	defer oidc.Unlock()
+
+	if oidcConfig.GroupsClaim == "" {
+		oidcConfig.GroupsClaim = "groups"
+	}
+	oidc.config = &oidcConfig
+	oidc.keys = nil
+	oidc.client = &http.Client{Timeout: 5 * time.Second}
+
+	if oidcConfig.JWKSFile != "" {
+		body, err := ioutil.ReadFile(oidcConfig.JWKSFile)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/InitOIDC", "operation": "readJWKSFile"}, 0)
+			return err
+		}
+		oidc.keys, err = parseJWKS(body)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/InitOIDC", "operation": "parseJWKS"}, 0)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// OIDCEnabled returns true if InitOIDC was called.
+func OIDCEnabled() bool {
+	oidc.Lock()
+	defer oidc.Unlock()
+
+	return oidc.config != nil
+}
+
+// VerifyToken verifies the signature of the JWT with the JWKS document and checks the issuer, audience, expiry and not before claims.
+// The subject and the Grants of the groups mapped to Roles get returned, if the token isn't valid ErrInvalidToken gets returned.
+// Errors while fetching the JWKS document get logged and then returned.
+func VerifyToken(token string) (OIDCIdentity, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return OIDCIdentity{}, ErrInvalidToken
+	}
+
+	header := struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}{}
+	claims := map[string]interface{}{}
+	if decodeSegment(parts[0], &header) != nil || decodeSegment(parts[1], &claims) != nil {
+		return OIDCIdentity{}, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return OIDCIdentity{}, ErrInvalidToken
+	}
+
+	key, err := oidcKey(header.Kid)
+	if err != nil {
+		return OIDCIdentity{}, err
+	}
+	if !verifySignature(header.Alg, key, parts[0]+"."+parts[1], signature) {
+		return OIDCIdentity{}, ErrInvalidToken
+	}
+
+	oidc.Lock()
+	oidcConfig := *oidc.config
+	oidc.Unlock()
+
+	now := time.Now()
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	expiry, hasExpiry := claims["exp"].(float64)
+	notBefore, hasNotBefore := claims["nbf"].(float64)
+	switch {
+	case issuer != oidcConfig.Issuer || subject == "":
+		return OIDCIdentity{}, ErrInvalidToken
+	case !hasExpiry || now.Add(-oidcLeeway).After(time.Unix(int64(expiry), 0)):
+		return OIDCIdentity{}, ErrInvalidToken
+	case hasNotBefore && now.Add(oidcLeeway).Before(time.Unix(int64(notBefore), 0)):
+		return OIDCIdentity{}, ErrInvalidToken
+	case !claimContains(claims["aud"], oidcConfig.Audience):
+		return OIDCIdentity{}, ErrInvalidToken
+	}
+
+	identity := OIDCIdentity{Subject: subject}
+	if len(oidcConfig.GroupRoles) > 0 {
+		identity.Grants = []string{}
+		for group, bindings := range oidcConfig.GroupRoles {
+			if !claimContains(claims[oidcConfig.GroupsClaim], group) {
+				continue
+			}
+			for _, binding := range bindings {
+				for _, permission := range types.RolePermissions[binding.Role] {
+					identity.Grants = append(identity.Grants, types.FormatGrant(permission, binding.Repository))
+				}
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+	body, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(body, value)
+}
+
+// claimContains returns true if the claim is the value or an array containing the value, like the aud claim or a groups claim.
+func claimContains(claim interface{}, value string) bool {
+	switch claim := claim.(type) {
+	case string:
+		return claim == value
+	case []interface{}:
+		for _, entry := range claim {
+			if entry == value {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed string, signature []byte) bool {
+	hashes := map[string]crypto.Hash{"256": crypto.SHA256, "384": crypto.SHA384, "512": crypto.SHA512}
+	if len(alg) != 5 {
+		return false
+	}
+	hash, ok := hashes[alg[2:]]
+	if !ok {
+		return false
+	}
+	hasher := hash.New()
+	hasher.Write([]byte(signed))
+	digest := hasher.Sum(nil)
+
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return alg[:2] == "RS" && rsa.VerifyPKCS1v15(key, hash, digest, signature) == nil
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || len(signature) != 2*size {
+			return false
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(key, digest, r, s)
+	}
+	return false
+}
+
+// oidcKey returns the key with the kid from the JWKS document, if the JWKS document is fetched from an URL it's fetched again after oidcJWKSMaxAge
+// or if the kid is unknown (e.g. after a key rotation), but at most once a minute.
+func oidcKey(kid string) (crypto.PublicKey, error) {
+	oidc.Lock()
+	defer oidc.Unlock()
+
+	if oidc.config == nil {
+		return nil, ErrInvalidToken
+	}
+
+	key, known := oidc.keys[kid]
+	if known && (oidc.config.JWKSFile != "" || time.Since(oidc.fetchedAt) < oidcJWKSMaxAge) {
+		return key, nil
+	}
+	if oidc.config.JWKSFile != "" || (!known && time.Since(oidc.fetchedAt) < time.Minute) {
+		return nil, ErrInvalidToken
+	}
+
+	keys, err := fetchJWKS(oidc.client, oidc.config)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/oidcKey", "operation": "fetchJWKS"}, 0)
+		// A stale key is still better than rejecting all tokens while the JWKS URL isn't reachable
+		if known {
+			return key, nil
+		}
+		return nil, err
+	}
+	oidc.keys = keys
+	oidc.fetchedAt = time.Now()
+
+	key, known = oidc.keys[kid]
+	if !known {
+		return nil, ErrInvalidToken
+	}
+	return key, nil
+}
+
+func fetchJWKS(client *http.Client, oidcConfig *OIDCConfig) (map[string]crypto.PublicKey, error) {
+	jwksURL := oidcConfig.JWKSURL
+	if jwksURL == "" {
+		discovery := struct {
+			JWKSURI string `json:"jwks_uri"`
+		}{}
+		body, err := httpGet(client, strings.TrimSuffix(oidcConfig.Issuer, "/")+"/.well-known/openid-configuration")
+		if err != nil {
+			return nil, err
+		}
+		err = json.Unmarshal(body, &discovery)
+		if err != nil {
+			return nil, err
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	body, err := httpGet(client, jwksURL)
+	if err != nil {
+		return nil, err
+	}
+	return parseJWKS(body)
+}
+
+func httpGet(client *http.Client, url string) ([]byte, error) {
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, errors.New("GET " + url + " responded with " + response.Status)
+	}
+	return ioutil.ReadAll(response.Body)
+}
+
+// parseJWKS returns the RSA and EC signature keys of the JWKS document by kid, keys of other types are ignored.
+func parseJWKS(body []byte) (map[string]crypto.PublicKey, error) {
+	document := struct {
+		Keys []jwk `json:"keys"`
+	}{}
+	err := json.Unmarshal(body, &document)
+	if err != nil {
+		return nil, err
+	}
+
+	curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+	keys := map[string]crypto.PublicKey{}
+	for _, key := range document.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		switch key.Kty {
+		case "RSA":
+			n, errN := base64.RawURLEncoding.DecodeString(key.N)
+			e, errE := base64.RawURLEncoding.DecodeString(key.E)
+			if errN != nil || errE != nil {
+				return nil, errors.New("Invalid RSA key " + key.Kid)
+			}
+			keys[key.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+		case "EC":
+			x, errX := base64.RawURLEncoding.DecodeString(key.X)
+			y, errY := base64.RawURLEncoding.DecodeString(key.Y)
+			curve, ok := curves[key.Crv]
+			if errX != nil || errY != nil || !ok {
+				return nil, errors.New("Invalid EC key " + key.Kid)
+			}
+			keys[key.Kid] = &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		}
+	}
+
+	return keys, nil
+}
@@ -6,70 +6,50 @@ import (
 
 	"github.com/auto-staging/tower/config"
 	"github.com/auto-staging/tower/types"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
-// GetAllRepositories reads all Repositories from the DynamoDB Table and unmarshals them into the array of Repository structs from the parameters (call by reference).
+// GetAllRepositories reads all Repositories from the Store and writes them into the array of Repository structs from the parameters (call by reference).
+// All pages are read, so the result isn't truncated for big tables.
 // If an error occurs the error gets logged and then returned.
 func GetAllRepositories(repositories *[]types.Repository) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.Scan(&dynamodb.ScanInput{
-		TableName: aws.String("auto-staging-repositories"),
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetAllRepositories", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, repositories)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetAllRepositories", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
-		return err
+	all := []types.Repository{}
+	cursor := ""
+	for {
+		page := []types.Repository{}
+		next, err := store.GetRepositoriesPage(&page, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
 	}
 
+	*repositories = all
 	return nil
 }
 
-// GetSingleRepository reads a single Repository entry from the DynamoDB Table where repository matches the namen given in the parameters.
-// The response gets unmarshaled into the Repository struct from the parameters (call by reference).
+// GetRepositoriesPage reads up to limit Repositories starting at the cursor from the Store and writes them into the array of Repository structs from the parameters (call by reference).
+// The cursor for the next page gets returned, if no more Repositories exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetRepositoriesPage(repositories *[]types.Repository, limit int64, cursor string) (string, error) {
+	return store.GetRepositoriesPage(repositories, limit, cursor)
+}
+
+// GetSingleRepository reads a single Repository entry from the Store where repository matches the namen given in the parameters.
+// The response gets written into the Repository struct from the parameters (call by reference).
 // If an error occurs the error gets logged and then returned.
 func GetSingleRepository(repository *types.Repository, name string) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.GetItem(&dynamodb.GetItemInput{
-		TableName: aws.String("auto-staging-repositories"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"repository": {
-				S: aws.String(name),
-			},
-		},
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetSingleRepository", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalMap(result.Item, repository)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetSingleRepository", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
-		return err
-	}
-
-	return nil
+	return store.GetSingleRepository(repository, name)
 }
 
-// AddRepository adds a new Repository to the DynamoDB Table, the values are received from the Repository struct given in the parameters.
+// AddRepository adds a new Repository to the Store, the values are received from the Repository struct given in the parameters.
 // Some values are overwritten with the global defaults if they were not set, therefore the API Stage is also required since AddRepository calls GetGlobalRepositoryConfiguration
-// internaly. To check the stored values, all values in the Repository struct are overwritten with the response of the AWS SDK command (call by reference).
+// internaly.
 // If an error occurs the error gets logged and then returned.
 func AddRepository(repository *types.Repository, stage string) error {
-	svc := getDynamoDbClient()
-
 	// Overwrite unset values with general config defaults
 	if repository.ShutdownSchedules == nil || repository.StartupSchedules == nil || repository.EnvironmentVariables == nil || repository.CodeBuildRoleARN == "" {
 		config.Logger.Log(errors.New("Overwriting unset variables with global defaults"), map[string]string{"module": "model/AddRepository", "operation": "overwrite"}, 4)
@@ -92,105 +72,30 @@ func AddRepository(repository *types.Repository, stage string) error {
 		}
 	}
 
-	av, err := dynamodbattribute.MarshalMap(repository)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/AddRepository", "operation": "dynamodb/marshalMap"}, 0)
-		return err
-	}
-
-	input := &dynamodb.PutItemInput{
-		TableName:           aws.String("auto-staging-repositories"),
-		Item:                av,
-		ConditionExpression: aws.String("attribute_not_exists(repository)"),
-	}
+	repository.Version = 1
 
-	_, err = svc.PutItem(input)
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/AddRepository", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	return nil
+	return store.AddRepository(repository)
 }
 
-// UpdateSingleRepository updates an existing Repository in DynamoDB where repository matches the given name with the values from the Repository struct
-// in the parameters. To check the updated values, all values in the Repository struct are overwritten with the response of the AWS SDK command (call by reference).
+// UpdateSingleRepository updates an existing Repository in the Store where repository matches the given name with the values from the Repository struct
+// in the parameters. To check the updated values, all values in the Repository struct are overwritten with the stored values (call by reference).
+// If expectedVersion isn't AnyVersion and the stored Repository has another version, ErrVersionMismatch gets returned.
 // If an error occurs the error gets logged and then returned.
-func UpdateSingleRepository(repository *types.Repository, name string) error {
-	svc := getDynamoDbClient()
-
-	updateStruct := types.RepositoryUpdate{
-		Webhook:               repository.Webhook,
-		Filters:               repository.Filters,
-		ShutdownSchedules:     repository.ShutdownSchedules,
-		StartupSchedules:      repository.StartupSchedules,
-		EnvironmentVariables:  repository.EnvironmentVariables,
-		InfrastructureRepoURL: repository.InfrastructureRepoURL,
-		CodeBuildRoleARN:      repository.CodeBuildRoleARN,
-	}
-
-	update, err := dynamodbattribute.MarshalMap(updateStruct)
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/marshalUpdateMap"}, 0)
-		return err
-	}
-
-	input := &dynamodb.UpdateItemInput{
-		TableName: aws.String("auto-staging-repositories"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"repository": {
-				S: aws.String(name),
-			},
-		},
-		UpdateExpression:          aws.String("SET webhook = :webhook, filters = :filters, shutdownSchedules = :shutdownSchedules, startupSchedules = :startupSchedules, environmentVariables = :environmentVariables, infrastructureRepoURL = :infrastructureRepoURL, codeBuildRoleARN = :codeBuildRoleARN"),
-		ExpressionAttributeValues: update,
-		ConditionExpression:       aws.String("attribute_exists(repository)"),
-		ReturnValues:              aws.String("ALL_NEW"),
-	}
-
-	result, err := svc.UpdateItem(input)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalMap(result.Attributes, repository)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/UpdateSingleRepository", "operation": "dynamodb/unmarshalMap"}, 0)
-		return err
+func UpdateSingleRepository(repository *types.Repository, name string, expectedVersion int64) error {
+	err := store.UpdateSingleRepository(repository, name, expectedVersion)
+	if IsConditionalCheckFailed(err) && expectedVersion != AnyVersion {
+		// The condition also fails if the Repository doesn't exist, the version only mismatches if the Repository is still stored
+		stored := types.Repository{}
+		if getErr := store.GetSingleRepository(&stored, name); getErr == nil && stored.Repository != "" {
+			return ErrVersionMismatch
+		}
 	}
-
-	return nil
+	return err
 }
 
-// DeleteSingleRepository deletes an existing Repository in DynamoDB where repository matches the given name from the parameters.
-// To check the deleted repository, all values in the Repository struct are overwritten with the response of the AWS SDK command (call by reference).
+// DeleteSingleRepository deletes an existing Repository in the Store where repository matches the given name from the parameters.
+// To check the deleted repository, all values in the Repository struct are overwritten with the deleted values (call by reference).
 // If an error occurs the error gets logged and then returned.
 func DeleteSingleRepository(repository *types.Repository, name string) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.DeleteItem(&dynamodb.DeleteItemInput{
-		TableName: aws.String("auto-staging-repositories"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"repository": {
-				S: aws.String(name),
-			},
-		},
-		ReturnValues: aws.String("ALL_OLD"),
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/DeleteSingleRepository", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalMap(result.Attributes, repository)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/DeleteSingleRepository", "operation": "dynamodb/unmarshalMap"}, 0)
-		return err
-	}
-
-	return nil
+	return store.DeleteSingleRepository(repository, name)
 }
@@ -0,0 +1,74 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// GetEnvironmentSchedulePreview writes the next count executions of the startup and shutdown schedules of the Environment after the time given in the parameters
+// into the SchedulePreview struct given in the parameters (call by reference).
+// The schedules of the Environment are the effective schedules, since the defaults of the Repository and the global configuration are copied when it's created.
+// Schedules with time zone are evaluated in their time zone, so the times are correct across daylight saving time changes and contain the offset of the zone.
+// If a schedule can't be parsed the error describing the invalid cron expression gets logged and then returned.
+func GetEnvironmentSchedulePreview(preview *types.SchedulePreview, environment types.Environment, after time.Time, count int) error {
+	startups, err := nextScheduleRuns(environment.StartupSchedules, after, count)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentSchedulePreview", "operation": "startupSchedules"}, 1)
+		return err
+	}
+	shutdowns, err := nextScheduleRuns(environment.ShutdownSchedules, after, count)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetEnvironmentSchedulePreview", "operation": "shutdownSchedules"}, 1)
+		return err
+	}
+
+	preview.Startups = startups
+	preview.Shutdowns = shutdowns
+	return nil
+}
+
+// nextScheduleRuns returns the next count executions of all schedules after the time given in the parameters sorted by time.
+// If the time zone of a schedule is unknown an error gets returned.
+func nextScheduleRuns(schedules []types.TimeSchedule, after time.Time, count int) ([]types.ScheduleRun, error) {
+	type run struct {
+		time     time.Time
+		schedule types.TimeSchedule
+	}
+
+	runs := []run{}
+	for _, schedule := range schedules {
+		cron, err := ParseCron(schedule.Cron)
+		if err != nil {
+			return nil, err
+		}
+		location, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time zone %q of cron %q", schedule.TimeZone, schedule.Cron)
+		}
+		next := after
+		for i := 0; i < count; i++ {
+			next = cron.NextIn(next, location)
+			if next.IsZero() {
+				break
+			}
+			runs = append(runs, run{time: next, schedule: schedule})
+		}
+	}
+
+	sort.SliceStable(runs, func(i, j int) bool {
+		return runs[i].time.Before(runs[j].time)
+	})
+	if len(runs) > count {
+		runs = runs[:count]
+	}
+
+	result := []types.ScheduleRun{}
+	for _, run := range runs {
+		result = append(result, types.ScheduleRun{Time: run.time.Format(time.RFC3339), Cron: run.schedule.Cron, TimeZone: run.schedule.TimeZone})
+	}
+	return result, nil
+}
@@ -1,73 +1,51 @@
 package model
 
 import (
-	"github.com/auto-staging/tower/config"
 	"github.com/auto-staging/tower/types"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
 )
 
-// GetAllEnvironmentsStatusInformation reads the repository, branch and status columns from all rows of the environments DynamoDB Table and writes
+// GetAllEnvironmentsStatusInformation reads the repository, branch, status and creationDate values of all Environments from the Store and writes
 // them to the Array of EnvironmentStatus structs given in the parameters (call by reference).
+// All pages are read, so the result isn't truncated for big tables.
 // If an error occurs the error gets logged and then returned.
 func GetAllEnvironmentsStatusInformation(status *[]types.EnvironmentStatus) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.Scan(&dynamodb.ScanInput{
-		TableName: aws.String("auto-staging-environments"),
-		ExpressionAttributeNames: map[string]*string{
-			"#status": aws.String("status"), // Workaround reserved keywoard issue
-		},
-		ProjectionExpression: aws.String("repository, branch, #status"),
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetAllEnvironmentsStatusInformation", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, status)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetAllEnvironmentsStatusInformation", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
-		return err
+	all := []types.EnvironmentStatus{}
+	cursor := ""
+	for {
+		page := []types.EnvironmentStatus{}
+		next, err := store.GetEnvironmentsStatusInformationPage(&page, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
 	}
 
+	setStatusAllowedActions(all)
+	*status = all
 	return nil
 }
 
-// GetSingleEnvironmentStatusInformation reads the repository, branch and status columns from the row of the environments DynamoDB Table where
+// GetEnvironmentsStatusInformationPage reads the repository, branch, status and creationDate values of up to limit Environments starting at the cursor from the Store and writes
+// them to the Array of EnvironmentStatus structs given in the parameters (call by reference).
+// The cursor for the next page gets returned, if no more Environments exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetEnvironmentsStatusInformationPage(status *[]types.EnvironmentStatus, limit int64, cursor string) (string, error) {
+	next, err := store.GetEnvironmentsStatusInformationPage(status, limit, cursor)
+	setStatusAllowedActions(*status)
+	return next, err
+}
+
+// GetSingleEnvironmentStatusInformation reads the repository, branch, status and creationDate values of the Environment where
 // repository and branch match the values given in the parameters. The result is written to the EnvironmentStatus struct from the parameters (call by reference).
 // If an error occurs the error gets logged and then returned.
 func GetSingleEnvironmentStatusInformation(status *types.EnvironmentStatus, name string, branch string) error {
-	svc := getDynamoDbClient()
-
-	result, err := svc.GetItem(&dynamodb.GetItemInput{
-		TableName: aws.String("auto-staging-environments"),
-		Key: map[string]*dynamodb.AttributeValue{
-			"repository": {
-				S: aws.String(name),
-			},
-			"branch": {
-				S: aws.String(branch),
-			},
-		},
-		ExpressionAttributeNames: map[string]*string{
-			"#status": aws.String("status"), // Workaround reserved keywoard issue
-		},
-		ProjectionExpression: aws.String("repository, branch, #status"),
-	})
-
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentStatusInformation", "operation": "dynamodb/exec"}, 0)
-		return err
-	}
-
-	err = dynamodbattribute.UnmarshalMap(result.Item, status)
-	if err != nil {
-		config.Logger.Log(err, map[string]string{"module": "model/GetSingleEnvironmentStatusInformation", "operation": "dynamodb/unmarshalListOfMaps"}, 0)
-		return err
+	err := store.GetSingleEnvironmentStatusInformation(status, name, branch)
+	if status.Repository != "" {
+		status.AllowedActions = status.Status.AllowedActions()
 	}
-
-	return nil
+	return err
 }
@@ -0,0 +1,75 @@
+package model
+
+import (
+	"github.com/auto-staging/tower/types"
+)
+
+// Store is the persistence layer used by the model functions for repositories, environments and the global repository configuration.
+// All read methods follow the call by reference style of the model package, if the requested item doesn't exist the struct stays untouched and no error gets returned.
+// List methods return one page per call together with the cursor for the next page, a limit of 0 leaves the page size to the implementation.
+// Conditional writes (unique constraint on add, exists check and expected version on update) fail with an error containing "ConditionalCheckFailedException" (see IsConditionalCheckFailed),
+// independent of the implementation. Every update increments the version of the item, an expectedVersion of AnyVersion skips the version check.
+// Status transitions are conditional on the current status and don't change the version, since the version only covers the configuration of an Environment.
+type Store interface {
+	GetRepositoriesPage(repositories *[]types.Repository, limit int64, cursor string) (string, error)
+	GetSingleRepository(repository *types.Repository, name string) error
+	AddRepository(repository *types.Repository) error
+	UpdateSingleRepository(repository *types.Repository, name string, expectedVersion int64) error
+	DeleteSingleRepository(repository *types.Repository, name string) error
+	SetRepositoryWebhookSecret(name string, secret string, previousSecret string, previousSecretExpiry string) error
+
+	GetEnvironmentsForRepositoryPage(environments *[]types.Environment, name string, limit int64, cursor string) (string, error)
+	GetSingleEnvironmentForRepository(environment *types.Environment, name string, branch string) error
+	AddEnvironment(environment *types.Environment) error
+	UpdateEnvironment(environment *types.EnvironmentPut, name string, branch string, expectedVersion int64) (types.Environment, error)
+	SetEnvironmentPullRequest(name string, branch string, pullRequest int, author string, expectedVersion int64) (types.Environment, error)
+	SetEnvironmentScheduleOffsets(name string, branch string, offsets string) error
+	CheckIfEnvironmentsForRepositoryExist(name string) (bool, error)
+	TransitionEnvironmentStatus(name string, branch string, transition StatusTransition) (types.EnvironmentStatus, error)
+	DeleteEnvironment(name string, branch string, from []types.EnvironmentState, operationID string) error
+
+	GetEnvironmentsStatusInformationPage(status *[]types.EnvironmentStatus, limit int64, cursor string) (string, error)
+	GetSingleEnvironmentStatusInformation(status *types.EnvironmentStatus, name string, branch string) error
+
+	AddEnvironmentHistoryEntry(entry *types.EnvironmentHistoryEntry) error
+	GetEnvironmentHistoryPage(entries *[]types.EnvironmentHistoryEntry, name string, branch string, limit int64, cursor string) (string, error)
+
+	AddAuditEntry(entry *types.AuditEntry) error
+	GetAuditEntriesPage(entries *[]types.AuditEntry, filter AuditFilter, limit int64, cursor string) (string, error)
+
+	GetRoleBindingsPage(bindings *[]types.RoleBinding, limit int64, cursor string) (string, error)
+	GetRoleBindingsForIdentity(bindings *[]types.RoleBinding, identity string) error
+	PutRoleBinding(binding *types.RoleBinding) error
+	DeleteRoleBinding(binding *types.RoleBinding, identity string, repository string) error
+
+	GetAPIKeysPage(keys *[]types.APIKey, limit int64, cursor string) (string, error)
+	GetSingleAPIKey(key *types.APIKey, id string) error
+	AddAPIKey(key *types.APIKey) error
+	RevokeAPIKey(key *types.APIKey, id string, revocationDate string) error
+
+	GetWebhookDeliveriesPage(deliveries *[]types.WebhookDelivery, limit int64, cursor string) (string, error)
+	GetSingleWebhookDelivery(delivery *types.WebhookDelivery, id string) error
+	AddWebhookDelivery(delivery *types.WebhookDelivery) error
+	RetryWebhookDelivery(delivery *types.WebhookDelivery, previousTime string) error
+	PutWebhookDelivery(delivery *types.WebhookDelivery) error
+
+	GetGlobalRepositoryConfiguration(configuration *types.GeneralConfig, stage string) error
+	UpdateGlobalRepositoryConfiguration(configuration *types.GeneralConfig, stage string) error
+}
+
+var store Store = NewDynamoDBStore()
+
+// SetStore replaces the Store used by all model functions, the default Store is the DynamoDB implementation.
+func SetStore(s Store) {
+	store = s
+}
+
+// InitStore selects the Store implementation by name, "memory" uses the in-memory Store, every other value keeps the DynamoDB Store.
+func InitStore(backend string) {
+	switch backend {
+	case "memory":
+		SetStore(NewMemoryStore())
+	default:
+		SetStore(NewDynamoDBStore())
+	}
+}
@@ -0,0 +1,344 @@
+package model
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// minutesPerDay is the number of minutes of a day, UTC times outside of it are on the previous or next day.
+const minutesPerDay = 24 * 60
+
+// ValidateTimeSchedule returns an error if the cron expression of the TimeSchedule is invalid, the TimeZone isn't a known IANA time zone
+// or the expression can't be translated into UTC for one of the offsets the zone has during the next year.
+func ValidateTimeSchedule(schedule types.TimeSchedule) error {
+	if _, err := ParseCron(schedule.Cron); err != nil {
+		return err
+	}
+	if schedule.TimeZone == "" {
+		return nil
+	}
+
+	location, err := time.LoadLocation(schedule.TimeZone)
+	if err != nil {
+		return fmt.Errorf("Invalid time zone %q of cron %q", schedule.TimeZone, schedule.Cron)
+	}
+
+	// Time zones change their offset at most a few times per year, so sampling every day covers all offsets the schedule gets translated with
+	offsets := map[int]bool{}
+	now := time.Now()
+	for day := 0; day <= 366; day++ {
+		_, offset := now.AddDate(0, 0, day).In(location).Zone()
+		if offsets[offset] {
+			continue
+		}
+		offsets[offset] = true
+		if _, err := translateCron(schedule.Cron, offset/60); err != nil {
+			return fmt.Errorf("Cron %q can't be used with time zone %q: %v", schedule.Cron, schedule.TimeZone, err)
+		}
+	}
+	return nil
+}
+
+// utcSchedules translates the schedules into UTC schedules for the offsets their time zones have at the time given in the parameters.
+// Schedules without TimeZone are UTC already and are returned unchanged, a schedule with TimeZone can result in multiple UTC schedules
+// if the local times are on different UTC days.
+func utcSchedules(schedules []types.TimeSchedule, at time.Time) ([]types.TimeSchedule, error) {
+	if schedules == nil {
+		return nil, nil
+	}
+
+	result := []types.TimeSchedule{}
+	for _, schedule := range schedules {
+		if schedule.TimeZone == "" {
+			result = append(result, schedule)
+			continue
+		}
+
+		location, err := time.LoadLocation(schedule.TimeZone)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid time zone %q of cron %q", schedule.TimeZone, schedule.Cron)
+		}
+		_, offset := at.In(location).Zone()
+		crons, err := translateCron(schedule.Cron, offset/60)
+		if err != nil {
+			return nil, fmt.Errorf("Cron %q can't be used with time zone %q: %v", schedule.Cron, schedule.TimeZone, err)
+		}
+		for _, cron := range crons {
+			result = append(result, types.TimeSchedule{Cron: cron})
+		}
+	}
+	return result, nil
+}
+
+// scheduleOffsets returns the UTC offsets the time zones of the schedules have at the time given in the parameters, e.g. "America/New_York=-04:00,Europe/Berlin=+02:00".
+// The UTC schedules sent to the Builder are valid as long as the offsets don't change, schedules without time zone result in an empty string.
+func scheduleOffsets(at time.Time, schedules ...[]types.TimeSchedule) string {
+	offsets := map[string]string{}
+	for _, list := range schedules {
+		for _, schedule := range list {
+			if schedule.TimeZone == "" {
+				continue
+			}
+			location, err := time.LoadLocation(schedule.TimeZone)
+			if err != nil {
+				offsets[schedule.TimeZone] = "invalid"
+				continue
+			}
+			offsets[schedule.TimeZone] = at.In(location).Format("-07:00")
+		}
+	}
+
+	zones := []string{}
+	for zone, offset := range offsets {
+		zones = append(zones, zone+"="+offset)
+	}
+	sort.Strings(zones)
+	return strings.Join(zones, ",")
+}
+
+// scheduleUpdateEvent returns the UPDATE_SCHEDULE BuilderEvent for the Environment where repository equals name and branch equals branch.
+// The schedules get translated into UTC for the time zone offsets at the time given in the parameters, the offsets are returned to detect when they change.
+// The Builder isn't invoked, so the schedules can be checked before anything gets stored. If an error occurs the error gets logged and then returned.
+func scheduleUpdateEvent(name string, branch string, startupSchedules []types.TimeSchedule, shutdownSchedules []types.TimeSchedule, at time.Time) (types.BuilderEvent, string, error) {
+	startups, err := utcSchedules(startupSchedules, at)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/scheduleUpdateEvent", "operation": "translate/startupSchedules"}, 0)
+		return types.BuilderEvent{}, "", err
+	}
+	shutdowns, err := utcSchedules(shutdownSchedules, at)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/scheduleUpdateEvent", "operation": "translate/shutdownSchedules"}, 0)
+		return types.BuilderEvent{}, "", err
+	}
+
+	event := types.BuilderEvent{
+		Operation:         "UPDATE_SCHEDULE",
+		Branch:            branch,
+		Repository:        name,
+		ShutdownSchedules: shutdowns,
+		StartupSchedules:  startups,
+	}
+	return event, scheduleOffsets(at, startupSchedules, shutdownSchedules), nil
+}
+
+// invokeScheduleUpdate invokes the Builder with the UPDATE_SCHEDULE BuilderEvent, if an error occurs the error gets logged and then returned.
+func invokeScheduleUpdate(event types.BuilderEvent) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/invokeScheduleUpdate", "operation": "builder/marshalSchedule"}, 0)
+		return err
+	}
+
+	_, err = invoker.Invoke(BuilderComponent, InvocationTypeEvent, body)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/invokeScheduleUpdate", "operation": "builder/invokeSchedule"}, 0)
+		return err
+	}
+	return nil
+}
+
+// sendScheduleUpdate translates the schedules like scheduleUpdateEvent and invokes the Builder with the UPDATE_SCHEDULE operation, the offsets of the schedules get returned.
+// If an error occurs the error gets logged and then returned.
+func sendScheduleUpdate(name string, branch string, startupSchedules []types.TimeSchedule, shutdownSchedules []types.TimeSchedule, at time.Time) (string, error) {
+	event, offsets, err := scheduleUpdateEvent(name, branch, startupSchedules, shutdownSchedules, at)
+	if err != nil {
+		return "", err
+	}
+	return offsets, invokeScheduleUpdate(event)
+}
+
+// RefreshScheduleTimeZones invokes the Builder with the UPDATE_SCHEDULE operation for all Environments whose schedules have time zones with another UTC offset
+// than when the schedules were last sent, e.g. because daylight saving time started. The refreshed Environments are written into the array of
+// TimeZoneRefresh structs given in the parameters (call by reference). Environments which are destroyed don't have schedules anymore and are skipped.
+// If an error occurs the error gets logged and then returned, the Environments refreshed before the error stay refreshed.
+func RefreshScheduleTimeZones(refreshed *[]types.TimeZoneRefresh) error {
+	now := time.Now()
+	result := []types.TimeZoneRefresh{}
+
+	repositories := []types.Repository{}
+	err := GetAllRepositories(&repositories)
+	if err != nil {
+		return err
+	}
+
+	for _, repository := range repositories {
+		environments := []types.Environment{}
+		err = GetAllEnvironmentsForRepository(&environments, repository.Repository)
+		if err != nil {
+			return err
+		}
+
+		for _, environment := range environments {
+			if environment.Status == types.EnvironmentStateDestroying || environment.Status == types.EnvironmentStateDestroyingFailed {
+				continue
+			}
+			offsets := scheduleOffsets(now, environment.StartupSchedules, environment.ShutdownSchedules)
+			if offsets == environment.ScheduleOffsets {
+				continue
+			}
+
+			offsets, err = sendScheduleUpdate(environment.Repository, environment.Branch, environment.StartupSchedules, environment.ShutdownSchedules, now)
+			if err != nil {
+				return err
+			}
+			err = store.SetEnvironmentScheduleOffsets(environment.Repository, environment.Branch, offsets)
+			if err != nil && !IsConditionalCheckFailed(err) {
+				return err
+			}
+
+			result = append(result, types.TimeZoneRefresh{Repository: environment.Repository, Branch: environment.Branch, Offsets: offsets})
+		}
+	}
+
+	*refreshed = result
+	return nil
+}
+
+// translateCron translates the local cron expression into UTC cron expressions for the offset of the time zone in minutes (east of UTC is positive).
+// The local times of day are shifted by the offset, the times falling on the previous or next UTC day get their own expressions with shifted days.
+// Days can only be shifted for every day ("*") and weekday lists, other day fields or restricted months and years return an error if a time moves to another day.
+func translateCron(expression string, offset int) ([]string, error) {
+	if _, err := ParseCron(expression); err != nil {
+		return nil, err
+	}
+	if offset == 0 {
+		return []string{expression}, nil
+	}
+
+	fields := strings.Fields(expression)
+	minutes, _ := parseCronField(fields[0], 0, 59, nil, true)
+	hours, _ := parseCronField(fields[1], 0, 23, nil, true)
+	everyDay := fields[3] == "*" && fields[5] == "*" && ((fields[2] == "*" && fields[4] == "?") || (fields[2] == "?" && fields[4] == "*"))
+
+	// UTC minutes of every UTC hour by the shift of the day (-1, 0 or 1)
+	shifted := map[int]map[int][]bool{}
+	for hour := range hours {
+		for minute := range minutes {
+			if !hours[hour] || !minutes[minute] {
+				continue
+			}
+			utc := hour*60 + minute - offset
+			day := 0
+			switch {
+			case utc < 0:
+				utc += minutesPerDay
+				day = -1
+			case utc >= minutesPerDay:
+				utc -= minutesPerDay
+				day = 1
+			}
+			if everyDay {
+				day = 0
+			}
+
+			if shifted[day] == nil {
+				shifted[day] = map[int][]bool{}
+			}
+			if shifted[day][utc/60] == nil {
+				shifted[day][utc/60] = make([]bool, 60)
+			}
+			shifted[day][utc/60][utc%60] = true
+		}
+	}
+
+	crons := []string{}
+	for _, day := range []int{-1, 0, 1} {
+		if shifted[day] == nil {
+			continue
+		}
+		dayOfMonth, dayOfWeek, err := shiftCronDays(fields, day)
+		if err != nil {
+			return nil, err
+		}
+
+		// Hours with the same minutes share one expression
+		order := []string{}
+		groups := map[string][]bool{}
+		for hour := 0; hour < 24; hour++ {
+			if shifted[day][hour] == nil {
+				continue
+			}
+			key := formatCronValues(shifted[day][hour], 0, 59)
+			if groups[key] == nil {
+				order = append(order, key)
+				groups[key] = make([]bool, 24)
+			}
+			groups[key][hour] = true
+		}
+		for _, key := range order {
+			crons = append(crons, strings.Join([]string{key, formatCronValues(groups[key], 0, 23), dayOfMonth, fields[3], dayOfWeek, fields[5]}, " "))
+		}
+	}
+	return crons, nil
+}
+
+// shiftCronDays returns the day-of-month and day-of-week fields of the expression for times moved by day days (-1, 0 or 1).
+func shiftCronDays(fields []string, day int) (string, string, error) {
+	if day == 0 {
+		return fields[2], fields[4], nil
+	}
+	if fields[3] != "*" || fields[5] != "*" {
+		return "", "", errors.New("month and year must be * if the UTC time is on another day")
+	}
+	if fields[4] == "?" {
+		if fields[2] != "*" {
+			return "", "", fmt.Errorf("day-of-month %q can't be moved to another day, use a list of weekdays instead", fields[2])
+		}
+		return fields[2], fields[4], nil
+	}
+
+	dayOfWeek := fields[4]
+	if dayOfWeek == "L" {
+		dayOfWeek = "SAT"
+	}
+	if strings.ContainsAny(dayOfWeek, "#L") {
+		return "", "", fmt.Errorf("day-of-week %q can't be moved to another day, use a list of weekdays instead", fields[4])
+	}
+	weekdays, err := parseCronField(dayOfWeek, 1, 7, cronWeekdayNames, false)
+	if err != nil {
+		return "", "", err
+	}
+
+	moved := make([]bool, 8)
+	for weekday := 1; weekday <= 7; weekday++ {
+		if weekdays[weekday] {
+			moved[(weekday-1+day+7)%7+1] = true
+		}
+	}
+	return "?", formatCronValues(moved, 1, 7), nil
+}
+
+// formatCronValues returns the cron field of the values between min and max, consecutive values are joined to ranges and all values result in "*".
+func formatCronValues(values []bool, min int, max int) string {
+	items := []string{}
+	all := true
+	for value := min; value <= max; value++ {
+		if !values[value] {
+			all = false
+			continue
+		}
+		if value > min && values[value-1] {
+			continue
+		}
+		end := value
+		for end < max && values[end+1] {
+			end++
+		}
+		if end == value {
+			items = append(items, fmt.Sprint(value))
+		} else {
+			items = append(items, fmt.Sprintf("%d-%d", value, end))
+		}
+	}
+	if all {
+		return "*"
+	}
+	return strings.Join(items, ",")
+}
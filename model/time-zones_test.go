@@ -0,0 +1,204 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/auto-staging/tower/types"
+)
+
+func TestTranslateCron(t *testing.T) {
+	tests := []struct {
+		name       string
+		expression string
+		offset     int
+		want       []string
+		wantErr    bool
+	}{
+		{"utc", "0 8 ? * MON-FRI *", 0, []string{"0 8 ? * MON-FRI *"}, false},
+		{"ahead of utc on the same day", "0 8 ? * MON-FRI *", 120, []string{"0 6 ? * MON-FRI *"}, false},
+		{"behind utc on the same day", "0 8 ? * MON-FRI *", -300, []string{"0 13 ? * MON-FRI *"}, false},
+		{"ahead of utc on the previous day", "30 1 ? * MON-FRI *", 120, []string{"30 23 ? * 1-5 *"}, false},
+		{"behind utc on the next day", "0 23 ? * FRI *", -300, []string{"0 4 ? * 7 *"}, false},
+		{"sunday to saturday", "0 1 ? * SUN *", 120, []string{"0 23 ? * 7 *"}, false},
+		{"saturday to sunday", "0 22 ? * SAT *", -180, []string{"0 1 ? * 1 *"}, false},
+		{"times on both days", "0 1,8 ? * MON *", 120, []string{"0 23 ? * 1 *", "0 6 ? * MON *"}, false},
+		{"every day across midnight", "0 0,12 * * ? *", 60, []string{"0 11,23 * * ? *"}, false},
+		{"half hour offset", "15 8 ? * L *", 330, []string{"45 2 ? * L *"}, false},
+		{"minute step with half hour offset", "*/15 * ? * * *", 330, []string{"0,15,30,45 * ? * * *"}, false},
+		{"day of month on the same day", "0 8 1 * ? *", 120, []string{"0 6 1 * ? *"}, false},
+		{"day of month across midnight", "0 1 1 * ? *", 120, nil, true},
+		{"nth weekday across midnight", "0 1 ? * 2#1 *", 120, nil, true},
+		{"invalid expression", "0 25 ? * MON *", 120, nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := translateCron(test.expression, test.offset)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("translateCron(%q, %d) = %q, want error", test.expression, test.offset, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("translateCron(%q, %d) returned %v", test.expression, test.offset, err)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("translateCron(%q, %d) = %q, want %q", test.expression, test.offset, got, test.want)
+			}
+		})
+	}
+}
+
+func TestUTCSchedules(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip("time zone database isn't available")
+	}
+
+	schedules := []types.TimeSchedule{
+		{Cron: "0 8 ? * MON-FRI *", TimeZone: "Europe/Berlin"},
+		{Cron: "0 20 ? * FRI *", TimeZone: "America/New_York"},
+		{Cron: "0 20 * * ? *"},
+	}
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		wantCrons   []string
+		wantOffsets string
+	}{
+		{"summer time", time.Date(2026, time.July, 1, 0, 0, 0, 0, time.UTC), []string{"0 6 ? * MON-FRI *", "0 0 ? * 7 *", "0 20 * * ? *"}, "America/New_York=-04:00,Europe/Berlin=+02:00"},
+		{"winter time", time.Date(2026, time.December, 1, 0, 0, 0, 0, time.UTC), []string{"0 7 ? * MON-FRI *", "0 1 ? * 7 *", "0 20 * * ? *"}, "America/New_York=-05:00,Europe/Berlin=+01:00"},
+		{"only berlin changed", time.Date(2026, time.October, 30, 0, 0, 0, 0, time.UTC), []string{"0 7 ? * MON-FRI *", "0 0 ? * 7 *", "0 20 * * ? *"}, "America/New_York=-04:00,Europe/Berlin=+01:00"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := utcSchedules(schedules, test.at)
+			if err != nil {
+				t.Fatalf("utcSchedules returned %v", err)
+			}
+			crons := []string{}
+			for _, schedule := range got {
+				crons = append(crons, schedule.Cron)
+				if schedule.TimeZone != "" {
+					t.Errorf("UTC schedule %q has time zone %q", schedule.Cron, schedule.TimeZone)
+				}
+			}
+			if !reflect.DeepEqual(crons, test.wantCrons) {
+				t.Errorf("utcSchedules = %q, want %q", crons, test.wantCrons)
+			}
+			if offsets := scheduleOffsets(test.at, schedules); offsets != test.wantOffsets {
+				t.Errorf("scheduleOffsets = %q, want %q", offsets, test.wantOffsets)
+			}
+		})
+	}
+}
+
+func TestValidateTimeSchedule(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip("time zone database isn't available")
+	}
+
+	tests := []struct {
+		name     string
+		schedule types.TimeSchedule
+		wantErr  bool
+	}{
+		{"utc", types.TimeSchedule{Cron: "0 1 1 * ? *"}, false},
+		{"zone ahead of utc", types.TimeSchedule{Cron: "30 1 ? * MON-FRI *", TimeZone: "Europe/Berlin"}, false},
+		{"zone behind utc", types.TimeSchedule{Cron: "0 23 ? * FRI *", TimeZone: "America/New_York"}, false},
+		{"day of month on the same utc day", types.TimeSchedule{Cron: "0 8 1 * ? *", TimeZone: "America/New_York"}, false},
+		{"day of month on another utc day", types.TimeSchedule{Cron: "0 1 1 * ? *", TimeZone: "Europe/Berlin"}, true},
+		{"day of month on another utc day in winter only", types.TimeSchedule{Cron: "0 19 1 * ? *", TimeZone: "America/New_York"}, true},
+		{"unknown zone", types.TimeSchedule{Cron: "0 8 1 * ? *", TimeZone: "Nowhere/Somewhere"}, true},
+		{"invalid cron", types.TimeSchedule{Cron: "0 8 ? * ? *", TimeZone: "Europe/Berlin"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := ValidateTimeSchedule(test.schedule)
+			if (err != nil) != test.wantErr {
+				t.Errorf("ValidateTimeSchedule(%+v) returned %v, want error %t", test.schedule, err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestRefreshScheduleTimeZones(t *testing.T) {
+	if _, err := time.LoadLocation("Europe/Berlin"); err != nil {
+		t.Skip("time zone database isn't available")
+	}
+
+	SetStore(NewMemoryStore())
+	recorder := NewRecordingInvoker()
+	SetInvoker(recorder)
+
+	startups := []types.TimeSchedule{{Cron: "30 1 ? * MON-FRI *", TimeZone: "Europe/Berlin"}}
+	shutdowns := []types.TimeSchedule{{Cron: "0 20 ? * FRI *", TimeZone: "America/New_York"}}
+	store.AddRepository(&types.Repository{Repository: "zones", InfrastructureRepoURL: "https://example.com/infra.git", CodeBuildRoleARN: "arn:aws:iam::123456789012:role/builder"})
+	_, err := AddEnvironmentForRepository(types.EnvironmentPost{Branch: "zoned", StartupSchedules: startups, ShutdownSchedules: shutdowns}, "zones", "tester")
+	if err != nil {
+		t.Fatalf("AddEnvironmentForRepository returned %v", err)
+	}
+	store.AddEnvironment(&types.Environment{Repository: "zones", Branch: "utc", Status: types.EnvironmentStateRunning, StartupSchedules: []types.TimeSchedule{{Cron: "0 8 ? * MON-FRI *"}}})
+
+	tests := []struct {
+		name          string
+		storedOffsets string
+		wantRefreshed int
+	}{
+		{"offsets unchanged", "", 0},
+		{"offset of one zone changed", "America/New_York=-05:00,Europe/Berlin=+09:00", 1},
+		{"offsets of all zones changed", "America/New_York=+00:00,Europe/Berlin=+00:00", 1},
+		{"offsets recomputed by the previous refresh", "", 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.storedOffsets != "" {
+				store.SetEnvironmentScheduleOffsets("zones", "zoned", test.storedOffsets)
+			}
+			recorder.Reset()
+
+			refreshed := []types.TimeZoneRefresh{}
+			err := RefreshScheduleTimeZones(&refreshed)
+			if err != nil {
+				t.Fatalf("RefreshScheduleTimeZones returned %v", err)
+			}
+			if len(refreshed) != test.wantRefreshed {
+				t.Fatalf("RefreshScheduleTimeZones refreshed %+v, want %d Environments", refreshed, test.wantRefreshed)
+			}
+
+			events, err := recorder.BuilderEvents()
+			if err != nil {
+				t.Fatalf("BuilderEvents returned %v", err)
+			}
+			if len(events) != test.wantRefreshed {
+				t.Fatalf("Builder was invoked with %+v, want %d events", events, test.wantRefreshed)
+			}
+			if test.wantRefreshed == 0 {
+				return
+			}
+
+			now := time.Now()
+			wantOffsets := scheduleOffsets(now, startups, shutdowns)
+			if refreshed[0].Branch != "zoned" || refreshed[0].Offsets != wantOffsets {
+				t.Errorf("refreshed %+v, want branch zoned with offsets %q", refreshed[0], wantOffsets)
+			}
+			wantStartups, _ := utcSchedules(startups, now)
+			wantShutdowns, _ := utcSchedules(shutdowns, now)
+			if events[0].Operation != "UPDATE_SCHEDULE" || !reflect.DeepEqual(events[0].StartupSchedules, wantStartups) || !reflect.DeepEqual(events[0].ShutdownSchedules, wantShutdowns) {
+				t.Errorf("Builder event %+v, want UPDATE_SCHEDULE with %+v and %+v", events[0], wantStartups, wantShutdowns)
+			}
+
+			environment := types.Environment{}
+			store.GetSingleEnvironmentForRepository(&environment, "zones", "zoned")
+			if environment.ScheduleOffsets != wantOffsets {
+				t.Errorf("stored offsets %q, want %q", environment.ScheduleOffsets, wantOffsets)
+			}
+		})
+	}
+}
@@ -1,37 +1,57 @@
 package model
 
 import (
+	"encoding/json"
 	"strconv"
 
 	"github.com/auto-staging/tower/config"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/lambda"
+	"github.com/auto-staging/tower/types"
 )
 
-// TriggerSchedulerLambdaForEnvironment invokes the Scheduler Lambda Function with the repository, branch and action given in the parameters, action
+// TriggerSchedulerLambdaForEnvironment invokes the Scheduler with the repository, branch and action given in the parameters, action
 // can be start or stop.
-// If invoking the Scheduler fails the error gets logged and then returned. Otherwise the response message of the Scheduler
-// gets unquoted and returned.
-func TriggerSchedulerLambdaForEnvironment(repository, branch, action string) (string, error) {
-	body := []byte("{ \"repository\": \"" + repository + "\", \"branch\": \"" + branch + "\", \"action\": \"" + action + "\" }")
-
-	client := getLambdaClient()
+// Before invoking the Scheduler the status gets changed to "starting" or "stopping" atomically, if the status doesn't allow the action anymore ErrStatusConflict gets returned.
+// The Scheduler sets the final status of the Environment itself, so the status isn't changed after a successful invocation. If the Scheduler reports a failure
+// and hasn't changed the status, the status gets set to the failure state of the action (conditional on the operation ID of the claim).
+// If invoking the Scheduler fails the status gets restored and the error gets logged and then returned. Otherwise the response message of the Scheduler
+// gets unquoted and returned, a response which isn't a quoted string is returned unchanged.
+func TriggerSchedulerLambdaForEnvironment(repository, branch, action, actor string) (string, error) {
+	previous, operationID, err := claimEnvironmentTransition(repository, branch, types.EnvironmentAction(action), actor)
+	if err != nil {
+		return "", err
+	}
 
-	response, err := client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String("auto-staging-scheduler"),
-		InvocationType: aws.String("RequestResponse"),
-		Payload:        body,
+	body, err := json.Marshal(types.SchedulerEvent{
+		Repository: repository,
+		Branch:     branch,
+		Action:     action,
 	})
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/TriggerSchedulerLambdaForEnvironment", "operation": "scheduler/marshal"}, 0)
+		rollbackEnvironmentTransition(repository, branch, types.EnvironmentAction(action), operationID, previous, actor)
+		return "", err
+	}
+
+	response, err := invoker.Invoke(SchedulerComponent, InvocationTypeRequestResponse, body)
 
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/TriggerSchedulerLambdaForEnvironment", "operation": "scheduler/invoke"}, 0)
+		rollbackEnvironmentTransition(repository, branch, types.EnvironmentAction(action), operationID, previous, actor)
 		return "", err
 	}
 
-	output, err := strconv.Unquote(string(response.Payload))
-	config.Logger.Log(err, map[string]string{"module": "model/TriggerSchedulerLambdaForEnvironment", "operation": "strconv/unquote"}, 0)
+	output, err := strconv.Unquote(string(response))
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/TriggerSchedulerLambdaForEnvironment", "operation": "strconv/unquote"}, 1)
+		output = string(response)
+	}
 
 	if output == "" {
+		// The condition on the operation ID fails if the Scheduler already set a status, so its status is never overwritten
+		err = completeEnvironmentTransition(repository, branch, types.EnvironmentAction(action), operationID, false, "Scheduler failed", SchedulerComponent)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "model/TriggerSchedulerLambdaForEnvironment", "operation": "transition/" + action}, 1)
+		}
 		return "{ \"message\": \"scheduler failed, check the scheduler logs for more information\" }", nil
 	}
 
@@ -6,19 +6,17 @@ import (
 
 	"github.com/auto-staging/tower/config"
 	"github.com/auto-staging/tower/types"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/lambda"
 )
 
 func GetBuilderVersion(componentVersion *types.SingleComponentVersion) error {
-	return getVersionInformationFromAutoStagingLambda(componentVersion, "auto-staging-builder")
+	return getVersionInformationFromAutoStagingLambda(componentVersion, BuilderComponent)
 }
 
 func GetSchedulerVersion(componentVersion *types.SingleComponentVersion) error {
-	return getVersionInformationFromAutoStagingLambda(componentVersion, "auto-staging-scheduler")
+	return getVersionInformationFromAutoStagingLambda(componentVersion, SchedulerComponent)
 }
 
-func getVersionInformationFromAutoStagingLambda(componentVersion *types.SingleComponentVersion, lambdaName string) error {
+func getVersionInformationFromAutoStagingLambda(componentVersion *types.SingleComponentVersion, component string) error {
 	event := types.BuilderEvent{
 		Operation: "VERSION",
 	}
@@ -28,18 +26,13 @@ func getVersionInformationFromAutoStagingLambda(componentVersion *types.SingleCo
 		return err
 	}
 
-	client := getLambdaClient()
-	result, err := client.Invoke(&lambda.InvokeInput{
-		FunctionName:   aws.String(lambdaName),
-		InvocationType: aws.String("RequestResponse"),
-		Payload:        body,
-	})
+	result, err := invoker.Invoke(component, InvocationTypeRequestResponse, body)
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/getVersionInformationFromAutoStagingLambda", "operation": "lambda/invoke"}, 0)
 		return err
 	}
 
-	unquoted, err := strconv.Unquote(string(result.Payload))
+	unquoted, err := strconv.Unquote(string(result))
 	if err != nil {
 		config.Logger.Log(err, map[string]string{"module": "model/getVersionInformationFromAutoStagingLambda", "operation": "unquote"}, 0)
 		return err
@@ -0,0 +1,163 @@
+package model
+
+import (
+	"errors"
+	"time"
+
+	"github.com/auto-staging/tower/types"
+)
+
+// WebhookDeliveryRetention is the time a WebhookDelivery is kept, afterwards DynamoDB removes it through the TTL of the "expires" attribute.
+const WebhookDeliveryRetention = 30 * 24 * time.Hour
+
+// WebhookDeliveryPayloadLimit is the maximum size of a stored payload in bytes, larger payloads aren't stored since DynamoDB items are limited to 400 KB.
+const WebhookDeliveryPayloadLimit = 256 * 1024
+
+// WebhookDeliveryProcessingTimeout is the time after which a WebhookDelivery which is still processing is considered crashed, so a redelivery processes it again.
+// It's the maximum timeout of a Lambda function, so a delivery which is still processed is never processed twice.
+const WebhookDeliveryProcessingTimeout = 15 * time.Minute
+
+// ErrDuplicateWebhookDelivery is returned if a WebhookDelivery with the same id was already processed successfully.
+var ErrDuplicateWebhookDelivery = errors.New("Duplicate webhook delivery")
+
+// ErrWebhookDeliveryInProgress is returned if a WebhookDelivery with the same id is currently processed.
+var ErrWebhookDeliveryInProgress = errors.New("Webhook delivery is in progress")
+
+// RecordWebhookDelivery records the WebhookDelivery from the parameters as processing, the receive time and the expiry get set (call by reference).
+// The payload is dropped if it's larger than WebhookDeliveryPayloadLimit, so the delivery is still recorded but can't be replayed.
+// A redelivery of a failed WebhookDelivery or of a WebhookDelivery processing longer than WebhookDeliveryProcessingTimeout is recorded again as new attempt.
+// If the WebhookDelivery with the same id succeeded, the stored WebhookDelivery gets written into the struct and ErrDuplicateWebhookDelivery gets returned.
+// If it's still processing ErrWebhookDeliveryInProgress gets returned, other errors get logged and then returned.
+func RecordWebhookDelivery(delivery *types.WebhookDelivery) error {
+	now := time.Now().UTC()
+	delivery.Time = now.Format(historyTimeLayout)
+	delivery.Expires = now.Add(WebhookDeliveryRetention).Unix()
+	delivery.Outcome = types.WebhookDeliveryProcessing
+	delivery.Attempts = 1
+	if len(delivery.Payload) > WebhookDeliveryPayloadLimit {
+		delivery.Payload = ""
+	}
+
+	err := store.AddWebhookDelivery(delivery)
+	if !IsConditionalCheckFailed(err) {
+		return err
+	}
+
+	stored := types.WebhookDelivery{}
+	err = store.GetSingleWebhookDelivery(&stored, delivery.ID)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case stored.Outcome == types.WebhookDeliverySucceeded:
+		*delivery = stored
+		return ErrDuplicateWebhookDelivery
+	case stored.Outcome == types.WebhookDeliveryProcessing && !webhookDeliveryStale(stored, now):
+		return ErrWebhookDeliveryInProgress
+	}
+
+	// Failed and crashed deliveries are processed again, the condition on the receive time lets only one of concurrent redeliveries win
+	delivery.Attempts = stored.Attempts + 1
+	delivery.Replays = stored.Replays
+	delivery.LastReplayTime = stored.LastReplayTime
+	err = store.RetryWebhookDelivery(delivery, stored.Time)
+	if IsConditionalCheckFailed(err) {
+		return ErrWebhookDeliveryInProgress
+	}
+	return err
+}
+
+// webhookDeliveryStale returns true if the WebhookDelivery was received more than WebhookDeliveryProcessingTimeout before now.
+func webhookDeliveryStale(delivery types.WebhookDelivery, now time.Time) bool {
+	received, err := time.Parse(historyTimeLayout, delivery.Time)
+	return err != nil || now.Sub(received) > WebhookDeliveryProcessingTimeout
+}
+
+// CompleteWebhookDelivery stores the response of the event controller in the WebhookDelivery from the parameters (call by reference).
+// Responses with a status code of 400 or above mark the delivery as failed, so it can be replayed.
+// If an error occurs the error gets logged and then returned.
+func CompleteWebhookDelivery(delivery *types.WebhookDelivery, statusCode int, response string) error {
+	delivery.StatusCode = statusCode
+	delivery.Response = response
+	delivery.Outcome = types.WebhookDeliverySucceeded
+	if statusCode >= 400 {
+		delivery.Outcome = types.WebhookDeliveryFailed
+	}
+
+	return store.PutWebhookDelivery(delivery)
+}
+
+// ClaimWebhookDeliveryReplay records the stored WebhookDelivery from the parameters as processing again before it gets replayed, the receive time is set to the time of the claim (call by reference).
+// Only failed deliveries and deliveries processing longer than WebhookDeliveryProcessingTimeout are claimed, the condition on the previous receive time lets only one of
+// concurrent replays and redeliveries win. If the WebhookDelivery succeeded ErrDuplicateWebhookDelivery gets returned, if it's processing or another replay or redelivery won
+// ErrWebhookDeliveryInProgress gets returned. Other errors get logged and then returned.
+func ClaimWebhookDeliveryReplay(delivery *types.WebhookDelivery) error {
+	now := time.Now().UTC()
+	switch {
+	case delivery.Outcome == types.WebhookDeliverySucceeded:
+		return ErrDuplicateWebhookDelivery
+	case delivery.Outcome != types.WebhookDeliveryFailed && !webhookDeliveryStale(*delivery, now):
+		return ErrWebhookDeliveryInProgress
+	}
+
+	claimed := *delivery
+	claimed.Time = now.Format(historyTimeLayout)
+	claimed.Outcome = types.WebhookDeliveryProcessing
+	err := store.RetryWebhookDelivery(&claimed, delivery.Time)
+	if IsConditionalCheckFailed(err) {
+		return ErrWebhookDeliveryInProgress
+	}
+	if err != nil {
+		return err
+	}
+
+	*delivery = claimed
+	return nil
+}
+
+// CompleteWebhookDeliveryReplay stores the response of a replay of the WebhookDelivery from the parameters like CompleteWebhookDelivery,
+// in addition the number of replays gets incremented and the time of the replay is set (call by reference).
+// If an error occurs the error gets logged and then returned.
+func CompleteWebhookDeliveryReplay(delivery *types.WebhookDelivery, statusCode int, response string) error {
+	delivery.Replays++
+	delivery.LastReplayTime = time.Now().UTC().Format(historyTimeLayout)
+
+	return CompleteWebhookDelivery(delivery, statusCode, response)
+}
+
+// GetAllWebhookDeliveries reads all WebhookDeliveries from the Store and writes them into the array of WebhookDelivery structs given in the parameters (call by reference).
+// If an error occurs the error gets logged and then returned.
+func GetAllWebhookDeliveries(deliveries *[]types.WebhookDelivery) error {
+	all := []types.WebhookDelivery{}
+	cursor := ""
+	for {
+		page := []types.WebhookDelivery{}
+		next, err := store.GetWebhookDeliveriesPage(&page, 0, cursor)
+		if err != nil {
+			return err
+		}
+		all = append(all, page...)
+		if next == "" {
+			break
+		}
+		cursor = next
+	}
+
+	*deliveries = all
+	return nil
+}
+
+// GetWebhookDeliveriesPage reads up to limit WebhookDeliveries starting at the cursor from the Store and writes them into the array of WebhookDelivery structs given in the parameters (call by reference).
+// The cursor for the next page gets returned, if no more WebhookDeliveries exist the cursor is empty.
+// If the cursor is invalid ErrInvalidCursor gets returned, other errors get logged and then returned.
+func GetWebhookDeliveriesPage(deliveries *[]types.WebhookDelivery, limit int64, cursor string) (string, error) {
+	return store.GetWebhookDeliveriesPage(deliveries, limit, cursor)
+}
+
+// GetSingleWebhookDelivery reads the WebhookDelivery where id matches the id given in the parameters from the Store and writes it into the WebhookDelivery struct given in the parameters (call by reference).
+// If the WebhookDelivery doesn't exist the struct stays untouched.
+// If an error occurs the error gets logged and then returned.
+func GetSingleWebhookDelivery(delivery *types.WebhookDelivery, id string) error {
+	return store.GetSingleWebhookDelivery(delivery, id)
+}
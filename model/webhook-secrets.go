@@ -0,0 +1,91 @@
+package model
+
+import (
+	"os"
+	"time"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/auto-staging/tower/types"
+)
+
+// WebhookSecretRotationWindow is the time the previous webhook secret of a Repository stays valid after the secret was changed,
+// so webhooks can be updated to the new secret without rejected events.
+const WebhookSecretRotationWindow = 24 * time.Hour
+
+// GetWebhookSecrets returns the secrets accepted for webhooks of the Repository where repository matches the name given in the parameters.
+// If the Repository has its own webhook secret, its secret and its previous secret (until the rotation window ended) are returned.
+// Otherwise the global secrets from the environment variables WEBHOOK_SECRET_TOKEN and WEBHOOK_SECRET_TOKEN_PREVIOUS are returned,
+// the previous secret only until the WEBHOOK_SECRET_TOKEN_PREVIOUS_EXPIRY passed.
+// If an error occurs the error gets logged and then returned.
+func GetWebhookSecrets(name string) ([]string, error) {
+	repository := types.Repository{}
+	if name != "" {
+		err := store.GetSingleRepository(&repository, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	secrets := []string{}
+	if repository.WebhookSecret != "" {
+		secrets = append(secrets, repository.WebhookSecret)
+		expiry, err := time.Parse(time.RFC3339, repository.PreviousSecretExpiry)
+		if repository.PreviousWebhookSecret != "" && err == nil && time.Now().Before(expiry) {
+			secrets = append(secrets, repository.PreviousWebhookSecret)
+		}
+		return secrets, nil
+	}
+
+	if secret := os.Getenv("WEBHOOK_SECRET_TOKEN"); secret != "" {
+		secrets = append(secrets, secret)
+	}
+	if secret := os.Getenv("WEBHOOK_SECRET_TOKEN_PREVIOUS"); secret != "" && globalPreviousSecretValid(os.Getenv("WEBHOOK_SECRET_TOKEN_PREVIOUS_EXPIRY")) {
+		secrets = append(secrets, secret)
+	}
+	return secrets, nil
+}
+
+// globalPreviousSecretValid returns true if the global previous secret is still valid at its expiry (RFC 3339 time), without expiry it's valid until it gets removed.
+// An expiry which can't be parsed gets logged and the previous secret is ignored, like an expired one.
+func globalPreviousSecretValid(expiry string) bool {
+	if expiry == "" {
+		return true
+	}
+
+	expiryTime, err := time.Parse(time.RFC3339, expiry)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "model/GetWebhookSecrets", "operation": "parseExpiry"}, 0)
+		return false
+	}
+	return time.Now().Before(expiryTime)
+}
+
+// SetRepositoryWebhookSecret sets the webhook secret of the Repository where repository matches the name given in the parameters.
+// The replaced secret stays valid as previous secret for the WebhookSecretRotationWindow.
+// If the Repository doesn't exist the condition fails with a ConditionalCheckFailedException, errors get logged and then returned.
+func SetRepositoryWebhookSecret(name string, secret string) error {
+	repository := types.Repository{}
+	err := store.GetSingleRepository(&repository, name)
+	if err != nil {
+		return err
+	}
+
+	previousSecret := repository.WebhookSecret
+	previousSecretExpiry := ""
+	if previousSecret == secret {
+		// Setting the same secret again keeps the running rotation window
+		previousSecret = repository.PreviousWebhookSecret
+		previousSecretExpiry = repository.PreviousSecretExpiry
+	} else if previousSecret != "" {
+		previousSecretExpiry = time.Now().UTC().Add(WebhookSecretRotationWindow).Format(time.RFC3339)
+	}
+
+	return store.SetRepositoryWebhookSecret(name, secret, previousSecret, previousSecretExpiry)
+}
+
+// DeleteRepositoryWebhookSecret removes the webhook secrets of the Repository where repository matches the name given in the parameters,
+// webhooks of the Repository are verified with the global secrets again.
+// If the Repository doesn't exist the condition fails with a ConditionalCheckFailedException, errors get logged and then returned.
+func DeleteRepositoryWebhookSecret(name string) error {
+	return store.SetRepositoryWebhookSecret(name, "", "", "")
+}
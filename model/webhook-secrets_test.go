@@ -0,0 +1,42 @@
+package model
+
+import (
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetWebhookSecretsGlobalPreviousExpiry(t *testing.T) {
+	os.Setenv("WEBHOOK_SECRET_TOKEN", "current")
+	os.Setenv("WEBHOOK_SECRET_TOKEN_PREVIOUS", "previous")
+	defer os.Unsetenv("WEBHOOK_SECRET_TOKEN")
+	defer os.Unsetenv("WEBHOOK_SECRET_TOKEN_PREVIOUS")
+	defer os.Unsetenv("WEBHOOK_SECRET_TOKEN_PREVIOUS_EXPIRY")
+	SetStore(NewMemoryStore())
+
+	tests := []struct {
+		name   string
+		expiry string
+		want   []string
+	}{
+		{"without expiry", "", []string{"current", "previous"}},
+		{"before expiry", time.Now().Add(time.Hour).Format(time.RFC3339), []string{"current", "previous"}},
+		{"after expiry", time.Now().Add(-time.Hour).Format(time.RFC3339), []string{"current"}},
+		{"invalid expiry", "tomorrow", []string{"current"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			os.Setenv("WEBHOOK_SECRET_TOKEN_PREVIOUS_EXPIRY", test.expiry)
+
+			secrets, err := GetWebhookSecrets("")
+			if err != nil {
+				t.Fatalf("GetWebhookSecrets returned %v", err)
+			}
+			if !reflect.DeepEqual(secrets, test.want) {
+				t.Errorf("secrets %v, want %v", secrets, test.want)
+			}
+		})
+	}
+}
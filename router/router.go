@@ -0,0 +1,122 @@
+// Package router dispatches API Gateway requests to the controllers by a declarative route table.
+// The route table is also the single source for the route documentation.
+package router
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/auto-staging/tower/types"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Controller is the signature of all controller functions.
+type Controller func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps the Controller of a matched Route, it's called with the matched Route so it can use the route information
+// (e.g. the resource) before calling next.
+type Middleware func(route Route, next Controller) Controller
+
+// Route maps the combination of an API Gateway resource and an HTTP method to a Controller.
+// Permission is required from the caller by the access control Middleware, routes without Permission are secured by the Controller itself.
+type Route struct {
+	Method      string
+	Resource    string
+	Controller  Controller
+	Permission  types.Permission
+	Description string
+}
+
+// Router contains the route table and the registered Middleware.
+type Router struct {
+	routes     []Route
+	middleware []Middleware
+}
+
+// New returns a Router for the given route table.
+func New(routes []Route) *Router {
+	return &Router{
+		routes: routes,
+	}
+}
+
+// Use registers Middleware, the Middleware registered first is the outermost one.
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Routes returns the route table.
+func (r *Router) Routes() []Route {
+	return r.routes
+}
+
+// Resources returns all resources from the route table, every resource is only returned once.
+func (r *Router) Resources() []string {
+	resources := []string{}
+	seen := map[string]bool{}
+	for _, route := range r.routes {
+		if !seen[route.Resource] {
+			seen[route.Resource] = true
+			resources = append(resources, route.Resource)
+		}
+	}
+	return resources
+}
+
+// Handle dispatches the request to the Controller matching resource and method.
+// Unknown resources are answered with 404, known resources with an unsupported method with 405 and an Allow header.
+// OPTIONS requests are answered automatically with the Allow header for the resource.
+func (r *Router) Handle(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+	allowed := []string{}
+	for _, route := range r.routes {
+		if route.Resource != request.Resource {
+			continue
+		}
+		if route.Method == request.HTTPMethod {
+			return r.wrap(route)(request)
+		}
+		allowed = append(allowed, route.Method)
+	}
+
+	if len(allowed) == 0 {
+		return types.NotFoundErrorResponse, nil
+	}
+
+	allowed = append(allowed, "OPTIONS")
+	sort.Strings(allowed)
+	allowHeader := strings.Join(allowed, ", ")
+
+	if request.HTTPMethod == "OPTIONS" {
+		return events.APIGatewayProxyResponse{
+			StatusCode: 204,
+			Headers:    map[string]string{"Allow": allowHeader},
+		}, nil
+	}
+
+	response := types.MethodNotAllowedResponse
+	response.Headers = map[string]string{"Allow": allowHeader}
+	return response, nil
+}
+
+func (r *Router) wrap(route Route) Controller {
+	controller := route.Controller
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		controller = r.middleware[i](route, controller)
+	}
+	return controller
+}
+
+// Documentation returns the route table as Markdown table.
+func (r *Router) Documentation() string {
+	builder := strings.Builder{}
+	builder.WriteString("| Method | Resource | Permission | Description |\n")
+	builder.WriteString("| ------ | -------- | ---------- | ----------- |\n")
+	for _, route := range r.routes {
+		permission := "-"
+		if route.Permission != "" {
+			permission = "`" + string(route.Permission) + "`"
+		}
+		builder.WriteString("| " + route.Method + " | `" + route.Resource + "` | " + permission + " | " + route.Description + " |\n")
+	}
+	return builder.String()
+}
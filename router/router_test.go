@@ -0,0 +1,101 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func respond(body string) Controller {
+	return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return events.APIGatewayProxyResponse{Body: body, StatusCode: 200}, nil
+	}
+}
+
+func testRouter() *Router {
+	return New([]Route{
+		{Method: http.MethodGet, Resource: "/repositories", Controller: respond("list")},
+		{Method: http.MethodPost, Resource: "/repositories", Controller: respond("add")},
+		{Method: http.MethodGet, Resource: "/repositories/{name}", Controller: respond("get")},
+		{Method: http.MethodPut, Resource: "/repositories/{name}", Controller: respond("put")},
+		{Method: http.MethodDelete, Resource: "/repositories/{name}", Controller: respond("delete")},
+	})
+}
+
+func TestHandle(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		resource   string
+		wantStatus int
+		wantBody   string
+		wantAllow  string
+	}{
+		{"get", http.MethodGet, "/repositories", 200, "list", ""},
+		{"post on the same resource", http.MethodPost, "/repositories", 200, "add", ""},
+		{"path parameter resource", http.MethodPut, "/repositories/{name}", 200, "put", ""},
+		{"unknown resource", http.MethodGet, "/unknown", 404, "", ""},
+		{"unknown resource with options", http.MethodOptions, "/unknown", 404, "", ""},
+		{"method not allowed", http.MethodDelete, "/repositories", 405, "", "GET, OPTIONS, POST"},
+		{"method not allowed with path parameter", http.MethodPost, "/repositories/{name}", 405, "", "DELETE, GET, OPTIONS, PUT"},
+		{"options", http.MethodOptions, "/repositories", 204, "", "GET, OPTIONS, POST"},
+		{"options with path parameter", http.MethodOptions, "/repositories/{name}", 204, "", "DELETE, GET, OPTIONS, PUT"},
+	}
+
+	router := testRouter()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			response, err := router.Handle(events.APIGatewayProxyRequest{HTTPMethod: test.method, Resource: test.resource})
+			if err != nil {
+				t.Fatalf("Handle returned %v", err)
+			}
+			if response.StatusCode != test.wantStatus {
+				t.Errorf("status code %d, want %d", response.StatusCode, test.wantStatus)
+			}
+			if test.wantBody != "" && response.Body != test.wantBody {
+				t.Errorf("body %q, want %q", response.Body, test.wantBody)
+			}
+			if allow := response.Headers["Allow"]; allow != test.wantAllow {
+				t.Errorf("Allow header %q, want %q", allow, test.wantAllow)
+			}
+		})
+	}
+}
+
+func TestUseOrder(t *testing.T) {
+	calls := []string{}
+	record := func(name string) Middleware {
+		return func(route Route, next Controller) Controller {
+			return func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls = append(calls, name+" "+route.Resource)
+				return next(request)
+			}
+		}
+	}
+
+	router := testRouter()
+	router.Use(record("outer"), record("inner"))
+
+	response, _ := router.Handle(events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/repositories/{name}"})
+	if response.Body != "get" {
+		t.Errorf("body %q, want %q", response.Body, "get")
+	}
+	if got := strings.Join(calls, ", "); got != "outer /repositories/{name}, inner /repositories/{name}" {
+		t.Errorf("middleware calls %q", got)
+	}
+
+	calls = nil
+	router.Handle(events.APIGatewayProxyRequest{HTTPMethod: http.MethodPatch, Resource: "/repositories/{name}"})
+	if len(calls) != 0 {
+		t.Errorf("middleware called for unmatched route: %q", calls)
+	}
+}
+
+func TestResources(t *testing.T) {
+	got := strings.Join(testRouter().Resources(), ",")
+	if got != "/repositories,/repositories/{name}" {
+		t.Errorf("Resources() = %q", got)
+	}
+}
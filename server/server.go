@@ -0,0 +1,229 @@
+// Package server exposes the Tower API over net/http, it translates HTTP requests into APIGatewayProxyRequest structs
+// so the same Handler can be used in the Lambda function and in a self-hosted Tower.
+package server
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/auto-staging/tower/config"
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// HandlerFunc is the signature of the Lambda Handler which processes the translated requests.
+type HandlerFunc func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// headerNames contains the original spelling of headers which are read with their exact name from the Headers map,
+// net/http canonicalizes all header names so e.g. X-GitHub-Event would otherwise only be available as X-Github-Event.
+var headerNames = map[string]string{
+	"X-Github-Event":    "X-GitHub-Event",
+	"X-Github-Delivery": "X-GitHub-Delivery",
+}
+
+// Server is an http.Handler which matches the request path against the API Gateway resources, builds the APIGatewayProxyRequest
+// with path parameters and stage and writes the APIGatewayProxyResponse of the Handler back to the client.
+type Server struct {
+	Stage     string
+	Resources []string
+	Handler   HandlerFunc
+
+	requestCounter uint64
+}
+
+// New returns a Server for the given API stage, API Gateway resources (e.g. /repositories/{name}) and Handler.
+func New(stage string, resources []string, handler HandlerFunc) *Server {
+	return &Server{
+		Stage:     stage,
+		Resources: resources,
+		Handler:   handler,
+	}
+}
+
+// ListenAndServe starts the HTTP server on the given address, it only returns if the server fails.
+func ListenAndServe(address string, stage string, resources []string, handler HandlerFunc) error {
+	log.Printf("listening on %s | stage - %s \n", address, stage)
+	return http.ListenAndServe(address, New(stage, resources, handler))
+}
+
+// ServeHTTP translates the HTTP request, calls the Handler and writes its response.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	request, err := s.translateRequest(r)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "server/ServeHTTP", "operation": "translateRequest"}, 1)
+		http.Error(w, "{\"message\": \"Invalid request\"}", http.StatusBadRequest)
+		return
+	}
+
+	response, err := s.Handler(request)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "server/ServeHTTP", "operation": "handler"}, 0)
+		http.Error(w, "{\"message\": \"Internal server error\"}", http.StatusInternalServerError)
+		return
+	}
+
+	writeResponse(w, response)
+}
+
+func (s *Server) translateRequest(r *http.Request) (events.APIGatewayProxyRequest, error) {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	// The stage prefix is optional, so the same URLs as with API Gateway (/{stage}/repositories) and plain paths (/repositories) work
+	path := r.URL.EscapedPath()
+	if s.Stage != "" && (path == "/"+s.Stage || strings.HasPrefix(path, "/"+s.Stage+"/")) {
+		path = strings.TrimPrefix(path, "/"+s.Stage)
+	}
+	if path == "" {
+		path = "/"
+	}
+
+	resource, pathParameters := matchResource(s.Resources, path)
+
+	headers := map[string]string{}
+	multiValueHeaders := map[string][]string{}
+	for name, values := range r.Header {
+		if original, ok := headerNames[name]; ok {
+			name = original
+		}
+		headers[name] = values[0]
+		multiValueHeaders[name] = values
+	}
+
+	var queryStringParameters map[string]string
+	var multiValueQueryStringParameters map[string][]string
+	query := r.URL.Query()
+	if len(query) > 0 {
+		queryStringParameters = map[string]string{}
+		multiValueQueryStringParameters = map[string][]string{}
+		for name, values := range query {
+			queryStringParameters[name] = values[0]
+			multiValueQueryStringParameters[name] = values
+		}
+	}
+
+	unescapedPath, err := url.PathUnescape(path)
+	if err != nil {
+		return events.APIGatewayProxyRequest{}, err
+	}
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            unescapedPath,
+		HTTPMethod:                      r.Method,
+		Headers:                         headers,
+		MultiValueHeaders:               multiValueHeaders,
+		QueryStringParameters:           queryStringParameters,
+		MultiValueQueryStringParameters: multiValueQueryStringParameters,
+		PathParameters:                  pathParameters,
+		Body:                            string(body),
+		RequestContext: events.APIGatewayProxyRequestContext{
+			Stage:        s.Stage,
+			RequestID:    strconv.FormatUint(atomic.AddUint64(&s.requestCounter, 1), 10),
+			ResourcePath: resource,
+			HTTPMethod:   r.Method,
+			Identity: events.APIGatewayRequestIdentity{
+				SourceIP:  sourceIP(r.RemoteAddr),
+				UserAgent: r.UserAgent(),
+			},
+		},
+	}, nil
+}
+
+// matchResource returns the resource matching the escaped path and the path parameters, path parameters stay escaped like with API Gateway.
+// If multiple resources match, the one with the most static segments wins (e.g. /repositories/environments over /repositories/{name}).
+// If no resource matches, the path itself gets returned as resource.
+func matchResource(resources []string, path string) (string, map[string]string) {
+	pathSegments := strings.Split(strings.Trim(path, "/"), "/")
+
+	bestResource := ""
+	bestStatic := -1
+	var bestParameters map[string]string
+
+	for _, resource := range resources {
+		resourceSegments := strings.Split(strings.Trim(resource, "/"), "/")
+		if len(resourceSegments) != len(pathSegments) {
+			continue
+		}
+
+		static := 0
+		parameters := map[string]string{}
+		match := true
+		for i, segment := range resourceSegments {
+			if strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}") {
+				if pathSegments[i] == "" {
+					match = false
+					break
+				}
+				parameters[strings.Trim(segment, "{}")] = pathSegments[i]
+				continue
+			}
+			if segment != pathSegments[i] {
+				match = false
+				break
+			}
+			static++
+		}
+
+		if match && static > bestStatic {
+			bestResource = resource
+			bestStatic = static
+			bestParameters = parameters
+		}
+	}
+
+	if bestStatic < 0 {
+		return path, nil
+	}
+	if len(bestParameters) == 0 {
+		bestParameters = nil
+	}
+
+	return bestResource, bestParameters
+}
+
+func writeResponse(w http.ResponseWriter, response events.APIGatewayProxyResponse) {
+	for name, value := range response.Headers {
+		w.Header().Set(name, value)
+	}
+	for name, values := range response.MultiValueHeaders {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	if w.Header().Get("Content-Type") == "" && response.Body != "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
+
+	body := []byte(response.Body)
+	if response.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(response.Body)
+		if err != nil {
+			config.Logger.Log(err, map[string]string{"module": "server/writeResponse", "operation": "base64Decode"}, 0)
+			http.Error(w, "{\"message\": \"Internal server error\"}", http.StatusInternalServerError)
+			return
+		}
+		body = decoded
+	}
+
+	w.WriteHeader(response.StatusCode)
+	_, err := w.Write(body)
+	if err != nil {
+		config.Logger.Log(err, map[string]string{"module": "server/writeResponse", "operation": "write"}, 1)
+	}
+}
+
+func sourceIP(remoteAddr string) string {
+	index := strings.LastIndex(remoteAddr, ":")
+	if index < 0 {
+		return remoteAddr
+	}
+	return remoteAddr[:index]
+}
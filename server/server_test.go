@@ -0,0 +1,137 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+var testResources = []string{
+	"/repositories",
+	"/repositories/{name}",
+	"/repositories/environments",
+	"/repositories/environments/status",
+	"/repositories/{name}/environments",
+	"/repositories/{name}/environments/{branch}",
+	"/repositories/{name}/environments/{branch}/status",
+	"/webhooks/deliveries/{id}/replay",
+}
+
+func TestMatchResource(t *testing.T) {
+	tests := []struct {
+		name           string
+		path           string
+		wantResource   string
+		wantParameters map[string]string
+	}{
+		{"static", "/repositories", "/repositories", nil},
+		{"trailing slash", "/repositories/", "/repositories", nil},
+		{"path parameter", "/repositories/tower", "/repositories/{name}", map[string]string{"name": "tower"}},
+		{"static over path parameter", "/repositories/environments", "/repositories/environments", nil},
+		{"more static segments win", "/repositories/environments/status", "/repositories/environments/status", nil},
+		{"path parameter named like a static segment", "/repositories/environments/environments", "/repositories/{name}/environments", map[string]string{"name": "environments"}},
+		{"two path parameters", "/repositories/tower/environments/main/status", "/repositories/{name}/environments/{branch}/status", map[string]string{"name": "tower", "branch": "main"}},
+		{"escaped path parameter", "/repositories/tower/environments/feature%2Flogin", "/repositories/{name}/environments/{branch}", map[string]string{"name": "tower", "branch": "feature%2Flogin"}},
+		{"path parameter in the middle", "/webhooks/deliveries/abc-123/replay", "/webhooks/deliveries/{id}/replay", map[string]string{"id": "abc-123"}},
+		{"empty path parameter", "/repositories//environments", "/repositories//environments", nil},
+		{"too many segments", "/repositories/tower/environments/main/status/extra", "/repositories/tower/environments/main/status/extra", nil},
+		{"unknown", "/unknown", "/unknown", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resource, parameters := matchResource(testResources, test.path)
+			if resource != test.wantResource {
+				t.Errorf("matchResource(%q) resource %q, want %q", test.path, resource, test.wantResource)
+			}
+			if !reflect.DeepEqual(parameters, test.wantParameters) {
+				t.Errorf("matchResource(%q) parameters %v, want %v", test.path, parameters, test.wantParameters)
+			}
+		})
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	var received events.APIGatewayProxyRequest
+	handler := func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received = request
+		switch request.Resource {
+		case "/repositories/{name}":
+			if request.HTTPMethod != http.MethodGet {
+				return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Method not allowed\" }", StatusCode: 405, Headers: map[string]string{"Allow": "GET, OPTIONS"}}, nil
+			}
+			return events.APIGatewayProxyResponse{Body: "{}", StatusCode: 200}, nil
+		case "/repositories":
+			return events.APIGatewayProxyResponse{StatusCode: 204, Headers: map[string]string{"Allow": "GET, OPTIONS"}}, nil
+		}
+		return events.APIGatewayProxyResponse{Body: "{ \"message\" : \"Not found\" }", StatusCode: 404}, nil
+	}
+	server := New("prod", testResources, handler)
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		wantStatus     int
+		wantResource   string
+		wantParameters map[string]string
+		wantAllow      string
+	}{
+		{"with stage", http.MethodGet, "/prod/repositories/tower", 200, "/repositories/{name}", map[string]string{"name": "tower"}, ""},
+		{"without stage", http.MethodGet, "/repositories/tower", 200, "/repositories/{name}", map[string]string{"name": "tower"}, ""},
+		{"stage named like a repository", http.MethodGet, "/prod/repositories/prod", 200, "/repositories/{name}", map[string]string{"name": "prod"}, ""},
+		{"not found", http.MethodGet, "/prod/unknown", 404, "/unknown", nil, ""},
+		{"method not allowed", http.MethodDelete, "/prod/repositories/tower", 405, "/repositories/{name}", map[string]string{"name": "tower"}, "GET, OPTIONS"},
+		{"options", http.MethodOptions, "/prod/repositories", 204, "/repositories", nil, "GET, OPTIONS"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			server.ServeHTTP(recorder, httptest.NewRequest(test.method, test.target, strings.NewReader("")))
+
+			if recorder.Code != test.wantStatus {
+				t.Errorf("status code %d, want %d", recorder.Code, test.wantStatus)
+			}
+			if received.Resource != test.wantResource || received.HTTPMethod != test.method || received.RequestContext.Stage != "prod" {
+				t.Errorf("request resource %q, method %q and stage %q", received.Resource, received.HTTPMethod, received.RequestContext.Stage)
+			}
+			if !reflect.DeepEqual(received.PathParameters, test.wantParameters) {
+				t.Errorf("path parameters %v, want %v", received.PathParameters, test.wantParameters)
+			}
+			if allow := recorder.Header().Get("Allow"); allow != test.wantAllow {
+				t.Errorf("Allow header %q, want %q", allow, test.wantAllow)
+			}
+		})
+	}
+}
+
+func TestServeHTTPHeadersAndQuery(t *testing.T) {
+	var received events.APIGatewayProxyRequest
+	server := New("", testResources, func(request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		received = request
+		return events.APIGatewayProxyResponse{Body: "{}", StatusCode: 200}, nil
+	})
+
+	request := httptest.NewRequest(http.MethodPost, "/repositories?limit=5&status=running&status=stopped", strings.NewReader("{\"repository\":\"tower\"}"))
+	request.Header.Set("X-GitHub-Event", "push")
+	recorder := httptest.NewRecorder()
+	server.ServeHTTP(recorder, request)
+
+	if received.Headers["X-GitHub-Event"] != "push" {
+		t.Errorf("headers %v don't contain X-GitHub-Event", received.Headers)
+	}
+	if received.QueryStringParameters["limit"] != "5" || len(received.MultiValueQueryStringParameters["status"]) != 2 {
+		t.Errorf("query parameters %v and %v", received.QueryStringParameters, received.MultiValueQueryStringParameters)
+	}
+	if received.Body != "{\"repository\":\"tower\"}" {
+		t.Errorf("body %q", received.Body)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/json" {
+		t.Errorf("Content-Type %q, want application/json", contentType)
+	}
+}
@@ -0,0 +1,126 @@
+package types
+
+// Permission is required by a route of the Tower API, it's granted to a caller by the Role bound to the caller identity.
+type Permission string
+
+// All permissions of the Tower API, routes without permission are secured by the controller itself (e.g. through HMAC).
+const (
+	// PermissionRead allows reading Repositories, Environments and their status
+	PermissionRead Permission = "read"
+	// PermissionTrigger allows starting and stopping Environments
+	PermissionTrigger Permission = "trigger"
+	// PermissionWrite allows changing Repositories and Environments
+	PermissionWrite Permission = "write"
+	// PermissionAdmin allows changing the Tower configuration, the global repository configuration and the role bindings and reading the audit log
+	PermissionAdmin Permission = "admin"
+)
+
+// Role is bound to a caller identity for a single Repository or all Repositories, it grants a fixed set of permissions.
+type Role string
+
+// All roles of the Tower API, every role grants the permissions of the roles listed before it.
+const (
+	RoleViewer     Role = "viewer"
+	RoleMaintainer Role = "repo-maintainer"
+	RoleAdmin      Role = "admin"
+)
+
+// RolePermissions contains the permissions granted by every Role.
+var RolePermissions = map[Role][]Permission{
+	RoleViewer:     {PermissionRead},
+	RoleMaintainer: {PermissionRead, PermissionTrigger, PermissionWrite},
+	RoleAdmin:      {PermissionRead, PermissionTrigger, PermissionWrite, PermissionAdmin},
+}
+
+// AllRepositories is the repository of a RoleBinding which applies to all Repositories.
+const AllRepositories = "*"
+
+// Valid returns true if the Role exists.
+func (r Role) Valid() bool {
+	_, ok := RolePermissions[r]
+	return ok
+}
+
+// Grants returns true if the Role grants the permission.
+func (r Role) Grants(permission Permission) bool {
+	for _, granted := range RolePermissions[r] {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleBinding is the implementation of the TowerAPI RoleBinding schema, it binds a Role to a caller identity for a single Repository or all Repositories ("*").
+// The identity is the caller identity recorded in the audit log, e.g. the IAM user ARN.
+type RoleBinding struct {
+	Identity   string `json:"identity"`
+	Repository string `json:"repository"`
+	Role       Role   `json:"role"`
+}
+
+// RoleBindingList is the implementation of the TowerAPI RoleBindingList schema, it's returned if the role bindings are requested page by page
+type RoleBindingList struct {
+	RoleBindings []RoleBinding `json:"roleBindings"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
+}
+
+// APIKeyScope limits the permissions of an APIKey.
+type APIKeyScope string
+
+// All scopes of APIKeys, no scope grants the admin permission.
+const (
+	APIKeyScopeReadOnly    APIKeyScope = "read-only"
+	APIKeyScopeTriggerOnly APIKeyScope = "trigger-only"
+	APIKeyScopeFull        APIKeyScope = "full"
+)
+
+// APIKeyScopePermissions contains the permissions granted by every APIKeyScope.
+var APIKeyScopePermissions = map[APIKeyScope][]Permission{
+	APIKeyScopeReadOnly:    {PermissionRead},
+	APIKeyScopeTriggerOnly: {PermissionTrigger},
+	APIKeyScopeFull:        {PermissionRead, PermissionTrigger, PermissionWrite},
+}
+
+// APIKey is the implementation of the TowerAPI APIKey schema, it's a machine credential sent as "Authorization: Bearer" header.
+// Only the SHA-256 hash of the secret part of the key is stored, the key itself is returned once when the APIKey gets created.
+// If Repository is set the APIKey is only valid for this Repository.
+type APIKey struct {
+	ID             string      `json:"id"`
+	Name           string      `json:"name"`
+	Scope          APIKeyScope `json:"scope"`
+	Repository     string      `json:"repository,omitempty"`
+	Hash           string      `json:"-" dynamodbav:"hash"`
+	CreationDate   string      `json:"creationDate"`
+	CreatedBy      string      `json:"createdBy,omitempty"`
+	Revoked        bool        `json:"revoked"`
+	RevocationDate string      `json:"revocationDate,omitempty"`
+}
+
+// APIKeyPost is the implementation of the TowerAPI APIKeyPost schema
+type APIKeyPost struct {
+	Name       string      `json:"name"`
+	Scope      APIKeyScope `json:"scope"`
+	Repository string      `json:"repository"`
+}
+
+// APIKeyCreated is the implementation of the TowerAPI APIKeyCreated schema, it's the only response containing the key.
+type APIKeyCreated struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// APIKeyList is the implementation of the TowerAPI APIKeyList schema, it's returned if the APIKeys are requested page by page
+type APIKeyList struct {
+	APIKeys    []APIKey `json:"apiKeys"`
+	NextCursor string   `json:"nextCursor,omitempty"`
+}
+
+// FormatGrant returns the permission for the repository in the format of the permissions of authenticated callers,
+// "<permission>" for all Repositories or "<permission>@<repository>" for a single Repository.
+func FormatGrant(permission Permission, repository string) string {
+	if repository == "" || repository == AllRepositories {
+		return string(permission)
+	}
+	return string(permission) + "@" + repository
+}
@@ -11,4 +11,24 @@ type BuilderEvent struct {
 	Success               int                   `json:"success"`
 	ShutdownSchedules     []TimeSchedule        `json:"shutdownSchedules"`
 	StartupSchedules      []TimeSchedule        `json:"startupSchedules"`
+	OperationID           string                `json:"operationId,omitempty"`
+	CommitSHA             string                `json:"commitSha,omitempty"`
+}
+
+// SchedulerEvent struct contains the values of the Scheduler invoke body, Action is start or stop.
+type SchedulerEvent struct {
+	Repository string `json:"repository"`
+	Branch     string `json:"branch"`
+	Action     string `json:"action"`
+}
+
+// BuilderCallback struct contains the result of a CREATE, UPDATE or DELETE operation reported by the Builder.
+// OperationID is the ID sent with the BuilderEvent, Success is 1 if the operation succeeded and Reason describes why the operation failed.
+type BuilderCallback struct {
+	Repository  string `json:"repository"`
+	Branch      string `json:"branch"`
+	Operation   string `json:"operation"`
+	OperationID string `json:"operationId"`
+	Success     int    `json:"success"`
+	Reason      string `json:"reason"`
 }
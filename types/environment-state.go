@@ -0,0 +1,120 @@
+package types
+
+// EnvironmentState is the lifecycle status of an Environment, it's stored as string in the status attribute of the environments Table.
+type EnvironmentState string
+
+// All states of the Environment lifecycle, the "ing" states are set while the Builder or Scheduler executes an operation,
+// the "failed" states are set if the operation failed.
+const (
+	EnvironmentStatePending          EnvironmentState = "pending"
+	EnvironmentStateInitiating       EnvironmentState = "initiating"
+	EnvironmentStateInitiatingFailed EnvironmentState = "initiating failed"
+	EnvironmentStateRunning          EnvironmentState = "running"
+	EnvironmentStateUpdating         EnvironmentState = "updating"
+	EnvironmentStateUpdatingFailed   EnvironmentState = "updating failed"
+	EnvironmentStateStopping         EnvironmentState = "stopping"
+	EnvironmentStateStoppingFailed   EnvironmentState = "stopping failed"
+	EnvironmentStateStopped          EnvironmentState = "stopped"
+	EnvironmentStateStarting         EnvironmentState = "starting"
+	EnvironmentStateStartingFailed   EnvironmentState = "starting failed"
+	EnvironmentStateDestroying       EnvironmentState = "destroying"
+	EnvironmentStateDestroyingFailed EnvironmentState = "destroying failed"
+)
+
+// EnvironmentAction is an operation which changes the state of an Environment.
+type EnvironmentAction string
+
+// All actions of the Environment lifecycle, create is executed by the Tower when the Environment gets added, all other actions can be requested through the API.
+const (
+	EnvironmentActionCreate  EnvironmentAction = "create"
+	EnvironmentActionUpdate  EnvironmentAction = "update"
+	EnvironmentActionStart   EnvironmentAction = "start"
+	EnvironmentActionStop    EnvironmentAction = "stop"
+	EnvironmentActionDestroy EnvironmentAction = "destroy"
+)
+
+// EnvironmentTransition describes an action in the Environment lifecycle. From contains the states the action can be executed in,
+// To is the state while the action is executed. Success and Failure are the states after the action finished, an empty Success state means the Environment gets removed.
+type EnvironmentTransition struct {
+	From    []EnvironmentState
+	To      EnvironmentState
+	Success EnvironmentState
+	Failure EnvironmentState
+}
+
+// EnvironmentTransitions is the transition table of the Environment lifecycle, every status check must be based on this table.
+var EnvironmentTransitions = map[EnvironmentAction]EnvironmentTransition{
+	EnvironmentActionCreate: {
+		From:    []EnvironmentState{EnvironmentStatePending},
+		To:      EnvironmentStateInitiating,
+		Success: EnvironmentStateRunning,
+		Failure: EnvironmentStateInitiatingFailed,
+	},
+	EnvironmentActionUpdate: {
+		From:    []EnvironmentState{EnvironmentStateRunning, EnvironmentStateUpdatingFailed},
+		To:      EnvironmentStateUpdating,
+		Success: EnvironmentStateRunning,
+		Failure: EnvironmentStateUpdatingFailed,
+	},
+	EnvironmentActionStart: {
+		From:    []EnvironmentState{EnvironmentStateStopped, EnvironmentStateStartingFailed},
+		To:      EnvironmentStateStarting,
+		Success: EnvironmentStateRunning,
+		Failure: EnvironmentStateStartingFailed,
+	},
+	EnvironmentActionStop: {
+		From:    []EnvironmentState{EnvironmentStateRunning, EnvironmentStateStoppingFailed},
+		To:      EnvironmentStateStopping,
+		Success: EnvironmentStateStopped,
+		Failure: EnvironmentStateStoppingFailed,
+	},
+	EnvironmentActionDestroy: {
+		From: []EnvironmentState{
+			EnvironmentStateRunning,
+			EnvironmentStateStopped,
+			EnvironmentStateInitiatingFailed,
+			EnvironmentStateUpdatingFailed,
+			EnvironmentStateStoppingFailed,
+			EnvironmentStateStartingFailed,
+			EnvironmentStateDestroyingFailed,
+		},
+		To:      EnvironmentStateDestroying,
+		Success: "",
+		Failure: EnvironmentStateDestroyingFailed,
+	},
+}
+
+// apiActions contains the actions which can be requested through the API in the order they are exposed as allowed actions.
+var apiActions = []EnvironmentAction{
+	EnvironmentActionUpdate,
+	EnvironmentActionStart,
+	EnvironmentActionStop,
+	EnvironmentActionDestroy,
+}
+
+// Allows returns true if the action can be executed in the state according to the EnvironmentTransitions table.
+func (s EnvironmentState) Allows(action EnvironmentAction) bool {
+	for _, from := range EnvironmentTransitions[action].From {
+		if from == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Reached returns true if the state is already the Success state of the action (e.g. running for start), so executing the action wouldn't change anything.
+func (s EnvironmentState) Reached(action EnvironmentAction) bool {
+	success := EnvironmentTransitions[action].Success
+	return success != "" && s == success
+}
+
+// AllowedActions returns the actions which can be requested through the API in the state, the result is never nil.
+func (s EnvironmentState) AllowedActions() []EnvironmentAction {
+	actions := []EnvironmentAction{}
+	for _, action := range apiActions {
+		if s.Allows(action) {
+			actions = append(actions, action)
+		}
+	}
+	return actions
+}
@@ -17,16 +17,33 @@ type EnvironmentVariable struct {
 	Value string `json:"value"`
 }
 
-// Repository is the implementation of the TowerAPI Repository schema
+// Repository is the implementation of the TowerAPI Repository schema.
+// The webhook secrets of the Repository are only stored, they are never returned by the API.
 type Repository struct {
 	Repository            string                `json:"repository,omitempty"`
 	InfrastructureRepoURL string                `json:"infrastructureRepoURL,omitempty"`
 	Webhook               bool                  `json:"webhook,omitempty"`
 	Filters               []string              `json:"filters,omitempty"`
+	PullRequestActions    []EnvironmentAction   `json:"pullRequestActions,omitempty"`
 	ShutdownSchedules     []TimeSchedule        `json:"shutdownSchedules,omitempty"`
 	StartupSchedules      []TimeSchedule        `json:"startupSchedules,omitempty"`
 	CodeBuildRoleARN      string                `json:"codeBuildRoleARN,omitempty"`
 	EnvironmentVariables  []EnvironmentVariable `json:"environmentVariables,omitempty"`
+	Version               int64                 `json:"version,omitempty"`
+	WebhookSecret         string                `json:"-" dynamodbav:"webhookSecret"`
+	PreviousWebhookSecret string                `json:"-" dynamodbav:"previousWebhookSecret"`
+	PreviousSecretExpiry  string                `json:"-" dynamodbav:"previousSecretExpiry"`
+}
+
+// WebhookSecretPut is the implementation of the TowerAPI WebhookSecretPut schema, it sets the webhook secret of a Repository.
+type WebhookSecretPut struct {
+	Secret string `json:"secret"`
+}
+
+// RepositoryList is the implementation of the TowerAPI RepositoryList schema, it's returned if the Repositories are requested page by page
+type RepositoryList struct {
+	Repositories []Repository `json:"repositories"`
+	NextCursor   string       `json:"nextCursor,omitempty"`
 }
 
 // RepositoryUpdate struct is used for DynamoDB updates, because the update command requires all json keys to start with ":"
@@ -34,6 +51,7 @@ type RepositoryUpdate struct {
 	InfrastructureRepoURL string                `json:":infrastructureRepoURL"`
 	Webhook               bool                  `json:":webhook"`
 	Filters               []string              `json:":filters"`
+	PullRequestActions    []EnvironmentAction   `json:":pullRequestActions"`
 	ShutdownSchedules     []TimeSchedule        `json:":shutdownSchedules"`
 	StartupSchedules      []TimeSchedule        `json:":startupSchedules"`
 	CodeBuildRoleARN      string                `json:":codeBuildRoleARN"`
@@ -59,12 +77,26 @@ type Environment struct {
 	Repository            string                `json:"repository,omitempty"`
 	Branch                string                `json:"branch,omitempty"`
 	CreationDate          string                `json:"creationDate,omitempty"`
-	Status                string                `json:"status,omitempty"`
+	Status                EnvironmentState      `json:"status,omitempty"`
 	InfrastructureRepoURL string                `json:"infrastructureRepoURL,omitempty"`
 	ShutdownSchedules     []TimeSchedule        `json:"shutdownSchedules,omitempty"`
 	StartupSchedules      []TimeSchedule        `json:"startupSchedules,omitempty"`
 	CodeBuildRoleARN      string                `json:"codeBuildRoleARN,omitempty"`
 	EnvironmentVariables  []EnvironmentVariable `json:"environmentVariables,omitempty"`
+	Version               int64                 `json:"version,omitempty"`
+	OperationID           string                `json:"operationId,omitempty"`
+	FailureReason         string                `json:"failureReason,omitempty"`
+	CommitSHA             string                `json:"commitSha,omitempty"`
+	PullRequest           int                   `json:"pullRequest,omitempty"`
+	PullRequestAuthor     string                `json:"pullRequestAuthor,omitempty"`
+	ScheduleOffsets       string                `json:"-" dynamodbav:"scheduleOffsets"`
+	AllowedActions        []EnvironmentAction   `json:"allowedActions" dynamodbav:"-"`
+}
+
+// EnvironmentList is the implementation of the TowerAPI EnvironmentList schema, it's returned if the Environments are requested page by page
+type EnvironmentList struct {
+	Environments []Environment `json:"environments"`
+	NextCursor   string        `json:"nextCursor,omitempty"`
 }
 
 // EnvironmentUpdate struct is used for DynamoDB updates, because the update command requires all json keys to start with ":"
@@ -93,13 +125,81 @@ type EnvironmentPost struct {
 	StartupSchedules      []TimeSchedule        `json:"startupSchedules,omitempty"`
 	CodeBuildRoleARN      string                `json:"codeBuildRoleARN,omitempty"`
 	EnvironmentVariables  []EnvironmentVariable `json:"environmentVariables,omitempty"`
+	PullRequest           int                   `json:"-"`
+	PullRequestAuthor     string                `json:"-"`
 }
 
 // EnvironmentStatus is the implementation of the TowerAPI EnvironmentStatus schema
 type EnvironmentStatus struct {
-	Repository string `json:"repository,omitempty"`
-	Branch     string `json:"branch,omitempty"`
-	Status     string `json:"status,omitempty"`
+	Repository     string              `json:"repository,omitempty"`
+	Branch         string              `json:"branch,omitempty"`
+	Status         EnvironmentState    `json:"status,omitempty"`
+	CreationDate   string              `json:"creationDate,omitempty"`
+	OperationID    string              `json:"operationId,omitempty"`
+	FailureReason  string              `json:"failureReason,omitempty"`
+	CommitSHA      string              `json:"commitSha,omitempty"`
+	AllowedActions []EnvironmentAction `json:"allowedActions" dynamodbav:"-"`
+}
+
+// EnvironmentStatusList is the implementation of the TowerAPI EnvironmentStatusList schema, it's returned if the EnvironmentStatus information is requested page by page
+type EnvironmentStatusList struct {
+	Environments []EnvironmentStatus `json:"environments"`
+	NextCursor   string              `json:"nextCursor,omitempty"`
+}
+
+// EnvironmentHistoryEntry is the implementation of the TowerAPI EnvironmentHistoryEntry schema, it describes a single status change of an Environment.
+// Environment is the key of the history Table, it combines repository and branch and isn't part of the API.
+// An entry without To status means the Environment was removed.
+type EnvironmentHistoryEntry struct {
+	Environment string            `json:"-" dynamodbav:"environment"`
+	Time        string            `json:"time"`
+	Repository  string            `json:"repository"`
+	Branch      string            `json:"branch"`
+	Action      EnvironmentAction `json:"action,omitempty"`
+	From        EnvironmentState  `json:"from,omitempty"`
+	To          EnvironmentState  `json:"to,omitempty"`
+	OperationID string            `json:"operationId,omitempty"`
+	Reason      string            `json:"reason,omitempty"`
+	CommitSHA   string            `json:"commitSha,omitempty"`
+	Actor       string            `json:"actor,omitempty"`
+}
+
+// EnvironmentHistoryList is the implementation of the TowerAPI EnvironmentHistoryList schema, it's returned if the history is requested page by page
+type EnvironmentHistoryList struct {
+	Entries    []EnvironmentHistoryEntry `json:"entries"`
+	NextCursor string                    `json:"nextCursor,omitempty"`
+}
+
+// AuditEntry is the implementation of the TowerAPI AuditEntry schema, it describes a single mutating API call.
+// Scope is the key of the audit Table, it's "repository/<name>" for Repositories and their Environments and the Target type for all other targets.
+// TargetID identifies targets which aren't Repositories or Environments, e.g. the id of an APIKey or the identity of a RoleBinding.
+// Changes contains the fields of the target which differ before and after the call, failed calls are recorded without changes.
+type AuditEntry struct {
+	Scope      string        `json:"-" dynamodbav:"scope"`
+	Time       string        `json:"time"`
+	Actor      string        `json:"actor"`
+	Method     string        `json:"method"`
+	Route      string        `json:"route"`
+	Target     string        `json:"target"`
+	TargetID   string        `json:"targetId,omitempty"`
+	Repository string        `json:"repository,omitempty"`
+	Branch     string        `json:"branch,omitempty"`
+	StatusCode int           `json:"statusCode"`
+	Changes    []AuditChange `json:"changes,omitempty"`
+}
+
+// AuditChange is the implementation of the TowerAPI AuditChange schema, it contains the value of a single field before and after a call.
+// A missing Before or After value means the field didn't exist, secrets are replaced by a placeholder.
+type AuditChange struct {
+	Field  string      `json:"field"`
+	Before interface{} `json:"before,omitempty"`
+	After  interface{} `json:"after,omitempty"`
+}
+
+// AuditList is the implementation of the TowerAPI AuditList schema, it's returned if the audit log is requested page by page
+type AuditList struct {
+	Entries    []AuditEntry `json:"entries"`
+	NextCursor string       `json:"nextCursor,omitempty"`
 }
 
 // ComponentVersions is the implementation of the TowerAPI ComponentVersions schema
@@ -116,19 +216,157 @@ type SingleComponentVersion struct {
 	BuildTime  string `json:"buildTime"`
 }
 
-// GitHubWebhook struct contains the three important values for auto-staging from the GitHub Webhook.
+// GitHubWebhook struct contains the important values for auto-staging from the GitHub Webhook.
+//
+// ref_type must be branch for auto-staging
+//
+// ref is the name of the Git Branch, for push events it's the full Git reference (refs/heads/<branch>)
+//
+// after is the commit SHA of the branch after a push, deleted is true if the push deleted the branch
+//
+// action is the action of a pull_request event, pull_request contains the number, the author and the head branch of the pull request
+//
+// repository/name is the name of the repository, repository/full_name includes the owner
+//
+// sender/login is the GitHub user who triggered the event
+type GitHubWebhook struct {
+	Ref         string `json:"ref"`
+	RefType     string `json:"ref_type"`
+	After       string `json:"after"`
+	Deleted     bool   `json:"deleted"`
+	Action      string `json:"action"`
+	PullRequest struct {
+		Number int `json:"number"`
+		User   struct {
+			Login string `json:"login"`
+		} `json:"user"`
+		Head struct {
+			Ref  string `json:"ref"`
+			SHA  string `json:"sha"`
+			Repo struct {
+				FullName string `json:"full_name"`
+			} `json:"repo"`
+		} `json:"head"`
+	} `json:"pull_request"`
+	Repository struct {
+		Name     string `json:"name"`
+		FullName string `json:"full_name"`
+	}
+	Sender struct {
+		Login string `json:"login"`
+	}
+}
+
+// GitLabWebhook struct contains the important values for auto-staging from the GitLab Push Hook.
+//
+// before and after are the commit SHAs of the branch before and after the push, they are all zeros if the branch was created or deleted
+//
+// ref is the full Git reference of the branch (refs/heads/<branch>)
+//
+// project/path_with_namespace is the path of the project, the last segment is the name of the repository
+//
+// user_username is the GitLab user who triggered the event
+type GitLabWebhook struct {
+	ObjectKind   string `json:"object_kind"`
+	Before       string `json:"before"`
+	After        string `json:"after"`
+	Ref          string `json:"ref"`
+	UserUsername string `json:"user_username"`
+	Project      struct {
+		PathWithNamespace string `json:"path_with_namespace"`
+	} `json:"project"`
+}
+
+// BitbucketWebhook struct contains the important values for auto-staging from the Bitbucket Cloud repo:push event.
+//
+// push/changes contains a change per pushed reference, new is null if the branch was deleted and old is null if the branch was created
+//
+// repository/name is the name of the repository
+//
+// actor/nickname is the Bitbucket user who triggered the event
+type BitbucketWebhook struct {
+	Push struct {
+		Changes []struct {
+			New *BitbucketReference `json:"new"`
+			Old *BitbucketReference `json:"old"`
+		} `json:"changes"`
+	} `json:"push"`
+	Repository struct {
+		Name string `json:"name"`
+	} `json:"repository"`
+	Actor struct {
+		Nickname string `json:"nickname"`
+	} `json:"actor"`
+}
+
+// BitbucketReference is a branch or tag of a Bitbucket Cloud repo:push change.
+type BitbucketReference struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// GiteaWebhook struct contains the important values for auto-staging from the Gitea or Forgejo create and delete events.
 //
 // ref_type must be branch for auto-staging
 //
 // ref is the name of the Git Branch
 //
 // repository/name is the name of the repository
-type GitHubWebhook struct {
+//
+// sender/login is the Gitea user who triggered the event
+type GiteaWebhook struct {
 	Ref        string `json:"ref"`
 	RefType    string `json:"ref_type"`
 	Repository struct {
 		Name string `json:"name"`
-	}
+	} `json:"repository"`
+	Sender struct {
+		Login string `json:"login"`
+	} `json:"sender"`
+}
+
+// WebhookEventResult is the result of a single branch event, it's returned if a webhook contains multiple branch events.
+type WebhookEventResult struct {
+	Branch     string            `json:"branch"`
+	Action     EnvironmentAction `json:"action"`
+	StatusCode int               `json:"statusCode"`
+}
+
+// WebhookDeliveryOutcome is the result of processing a WebhookDelivery.
+type WebhookDeliveryOutcome string
+
+// All outcomes of WebhookDeliveries, a delivery is processing until the response of the event controller is known.
+const (
+	WebhookDeliveryProcessing WebhookDeliveryOutcome = "processing"
+	WebhookDeliverySucceeded  WebhookDeliveryOutcome = "succeeded"
+	WebhookDeliveryFailed     WebhookDeliveryOutcome = "failed"
+)
+
+// WebhookDelivery is the implementation of the TowerAPI WebhookDelivery schema, it records a single verified webhook request by the delivery id of the provider.
+// StatusCode and Response are the response of the last processing of the delivery, deliveries with a status code of 400 or above failed.
+// Attempts counts how often the provider sent the delivery and it was processed, redeliveries of succeeded deliveries aren't processed again.
+// Payload is the request body, it's only returned for single deliveries and is empty if it was too large to be stored.
+// Expires is the time (unix seconds) after which DynamoDB removes the delivery (TTL attribute).
+type WebhookDelivery struct {
+	ID             string                 `json:"id"`
+	Provider       string                 `json:"provider"`
+	Event          string                 `json:"event"`
+	Repository     string                 `json:"repository,omitempty"`
+	Time           string                 `json:"time"`
+	Outcome        WebhookDeliveryOutcome `json:"outcome"`
+	Attempts       int                    `json:"attempts,omitempty"`
+	StatusCode     int                    `json:"statusCode,omitempty"`
+	Response       string                 `json:"response,omitempty"`
+	Payload        string                 `json:"payload,omitempty"`
+	Replays        int                    `json:"replays,omitempty"`
+	LastReplayTime string                 `json:"lastReplayTime,omitempty"`
+	Expires        int64                  `json:"-" dynamodbav:"expires,omitempty"`
+}
+
+// WebhookDeliveryList is the implementation of the TowerAPI WebhookDeliveryList schema, it's returned if the WebhookDeliveries are requested page by page
+type WebhookDeliveryList struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+	NextCursor string            `json:"nextCursor,omitempty"`
 }
 
 // TriggerSchedulePost is the implementation of the TowerAPI EnvironmentStatus schema
@@ -138,9 +376,33 @@ type TriggerSchedulePost struct {
 	Action     string `json:"action"`
 }
 
-// TimeSchedule is the implementation of the TowerAPI TimeSchedule schema
+// TimeSchedule is the implementation of the TowerAPI TimeSchedule schema.
+// The cron expression is interpreted in the IANA TimeZone (e.g. "Europe/Berlin"), without TimeZone it's UTC.
+// The Builder only receives UTC schedules, schedules with TimeZone get translated for the current offset of the zone before they are sent.
 type TimeSchedule struct {
-	Cron string `json:"cron"`
+	Cron     string `json:"cron"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// TimeZoneRefresh is the implementation of the TowerAPI TimeZoneRefresh schema, it's an Environment whose schedules were sent to the Builder again
+// because the UTC offset of one of their time zones changed. Offsets contains the new offsets by time zone.
+type TimeZoneRefresh struct {
+	Repository string `json:"repository"`
+	Branch     string `json:"branch"`
+	Offsets    string `json:"offsets"`
+}
+
+// ScheduleRun is the implementation of the TowerAPI ScheduleRun schema, it's a single future execution of a TimeSchedule.
+type ScheduleRun struct {
+	Time     string `json:"time"`
+	Cron     string `json:"cron"`
+	TimeZone string `json:"timeZone,omitempty"`
+}
+
+// SchedulePreview is the implementation of the TowerAPI SchedulePreview schema, it contains the next executions of the startup and shutdown schedules of an Environment sorted by time.
+type SchedulePreview struct {
+	Startups  []ScheduleRun `json:"startups"`
+	Shutdowns []ScheduleRun `json:"shutdowns"`
 }
 
 // InternalServerErrorResponse contains a APIGatewayProxyResponse struct preset with "Internal server error" it's used as return value in controllers.
@@ -167,8 +429,38 @@ var InvalidEnvironmentStatusResponse = events.APIGatewayProxyResponse{
 	StatusCode: 400,
 }
 
+// EnvironmentStatusConflictResponse contains a APIGatewayProxyResponse struct preset with "Environment status changed concurrently" it's used as return value in controllers.
+var EnvironmentStatusConflictResponse = events.APIGatewayProxyResponse{
+	Body:       "{\"message\": \"Environment status changed concurrently\"}",
+	StatusCode: 409,
+}
+
 // NotFoundErrorResponse contains a APIGatewayProxyResponse struct preset with "Not found" it's used as return value in controllers.
 var NotFoundErrorResponse = events.APIGatewayProxyResponse{
 	Body:       "{\"message\": \"Not found\"}",
 	StatusCode: 404,
 }
+
+// PreconditionFailedResponse contains a APIGatewayProxyResponse struct preset with "Version doesn't match If-Match header" it's used as return value in controllers.
+var PreconditionFailedResponse = events.APIGatewayProxyResponse{
+	Body:       "{\"message\": \"Version doesn't match If-Match header\"}",
+	StatusCode: 412,
+}
+
+// MethodNotAllowedResponse contains a APIGatewayProxyResponse struct preset with "Method not allowed" it's used as return value in the router.
+var MethodNotAllowedResponse = events.APIGatewayProxyResponse{
+	Body:       "{\"message\": \"Method not allowed\"}",
+	StatusCode: 405,
+}
+
+// ForbiddenResponse contains a APIGatewayProxyResponse struct preset with "Forbidden" it's used as return value in the access control middleware.
+var ForbiddenResponse = events.APIGatewayProxyResponse{
+	Body:       "{\"message\": \"Forbidden\"}",
+	StatusCode: 403,
+}
+
+// UnauthorizedResponse contains a APIGatewayProxyResponse struct preset with "Unauthorized" it's used as return value in the authentication middleware.
+var UnauthorizedResponse = events.APIGatewayProxyResponse{
+	Body:       "{\"message\": \"Unauthorized\"}",
+	StatusCode: 401,
+}